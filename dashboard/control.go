@@ -0,0 +1,162 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Control carries operator commands from the dashboard to a running loop:
+// pause and resume between iterations, stop, and steering messages to add
+// to the conversation. Methods are safe for concurrent use.
+type Control struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	paused  bool
+	stopped bool
+	steer   []string
+}
+
+// ControlState is the externally visible state of a Control.
+type ControlState struct {
+	Paused       bool `json:"paused"`
+	Stopped      bool `json:"stopped"`
+	PendingSteer int  `json:"pending_steer"` // steering messages not yet delivered
+}
+
+// NewControl returns a Control for a running loop.
+func NewControl() *Control {
+	c := &Control{}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+// Pause asks the loop to wait before its next iteration.
+func (c *Control) Pause() {
+	c.set(func() { c.paused = true })
+}
+
+// Resume lets a paused loop continue.
+func (c *Control) Resume() {
+	c.set(func() { c.paused = false })
+}
+
+// Stop asks the loop to end before its next iteration, waking it if paused.
+func (c *Control) Stop() {
+	c.set(func() { c.stopped = true })
+}
+
+// Steer queues a message for the loop to add to the conversation before its
+// next LLM call.
+func (c *Control) Steer(message string) {
+	c.set(func() { c.steer = append(c.steer, message) })
+}
+
+// set applies fn under the lock and wakes waiters.
+func (c *Control) set(fn func()) {
+	c.mu.Lock()
+	fn()
+	c.mu.Unlock()
+	c.cond.Broadcast()
+}
+
+// State returns the current state.
+func (c *Control) State() ControlState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return ControlState{Paused: c.paused, Stopped: c.stopped, PendingSteer: len(c.steer)}
+}
+
+// TakeSteer returns and clears the queued steering messages.
+func (c *Control) TakeSteer() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	msgs := c.steer
+	c.steer = nil
+	return msgs
+}
+
+// WaitWhilePaused blocks while the loop is paused and reports whether it was
+// stopped.
+func (c *Control) WaitWhilePaused() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for c.paused && !c.stopped {
+		c.cond.Wait()
+	}
+	return c.stopped
+}
+
+// Control returns the broker's run Control, creating it on first use. It
+// returns nil on a nil broker, i.e. when the dashboard is off.
+func (b *SSEBroker) Control() *Control {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.control == nil {
+		b.control = NewControl()
+	}
+	return b.control
+}
+
+// controlRequest is the JSON body of POST /control/steer.
+type controlRequest struct {
+	Message string `json:"message"`
+}
+
+// handleControl serves GET /control (the current state) and POST
+// /control/{pause,resume,stop,steer} for the run selected with ?run=.
+// POSTs must be JSON: browsers cannot send that cross-origin without a CORS
+// preflight, which this server never grants, so other sites cannot drive
+// the loop.
+func handleControl(lookup func(run string) *SSEBroker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		broker := lookup(r.URL.Query().Get("run"))
+		if broker == nil {
+			http.Error(w, "unknown run", http.StatusNotFound)
+			return
+		}
+		ctl := broker.Control()
+		action := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/control"), "/")
+
+		if action != "" {
+			if r.Method != http.MethodPost {
+				w.Header().Set("Allow", http.MethodPost)
+				http.Error(w, "use POST", http.StatusMethodNotAllowed)
+				return
+			}
+			if mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mt != "application/json" {
+				http.Error(w, "Content-Type must be application/json", http.StatusUnsupportedMediaType)
+				return
+			}
+			switch action {
+			case "pause":
+				ctl.Pause()
+			case "resume":
+				ctl.Resume()
+			case "stop":
+				ctl.Stop()
+			case "steer":
+				var req controlRequest
+				if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64*1024)).Decode(&req); err != nil {
+					http.Error(w, "invalid JSON body", http.StatusBadRequest)
+					return
+				}
+				if strings.TrimSpace(req.Message) == "" {
+					http.Error(w, "message is empty", http.StatusBadRequest)
+					return
+				}
+				ctl.Steer(strings.TrimSpace(req.Message))
+			default:
+				http.Error(w, "unknown control action", http.StatusNotFound)
+				return
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(ctl.State())
+	}
+}
@@ -0,0 +1,112 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// A paused loop waits until resumed; stopping wakes it and reports the stop.
+func TestControl_PauseResumeStop(t *testing.T) {
+	c := NewControl()
+	if c.WaitWhilePaused() {
+		t.Fatal("a running loop should not wait or stop")
+	}
+
+	for _, release := range []func(){c.Resume, c.Stop} {
+		c.Pause()
+		done := make(chan bool)
+		go func() { done <- c.WaitWhilePaused() }()
+		select {
+		case <-done:
+			t.Fatal("WaitWhilePaused returned while paused")
+		case <-time.After(20 * time.Millisecond):
+		}
+		release()
+		select {
+		case stopped := <-done:
+			if stopped != c.State().Stopped {
+				t.Fatalf("WaitWhilePaused = %v, state %+v", stopped, c.State())
+			}
+		case <-time.After(time.Second):
+			t.Fatal("WaitWhilePaused did not return")
+		}
+	}
+	if !c.State().Stopped {
+		t.Fatal("Stop should be recorded")
+	}
+}
+
+// Steering messages queue up until the loop takes them.
+func TestControl_Steer(t *testing.T) {
+	c := NewControl()
+	c.Steer("use the v2 API")
+	c.Steer("skip the docs")
+	if n := c.State().PendingSteer; n != 2 {
+		t.Fatalf("PendingSteer = %d", n)
+	}
+	if got := c.TakeSteer(); len(got) != 2 || got[0] != "use the v2 API" {
+		t.Fatalf("TakeSteer = %q", got)
+	}
+	if got := c.TakeSteer(); got != nil {
+		t.Fatalf("second TakeSteer = %q", got)
+	}
+}
+
+// The control endpoints drive the run's Control and only accept JSON POSTs.
+func TestStartDashboard_Control(t *testing.T) {
+	b := NewSSEBroker()
+	addr, err := StartDashboard(b, 0)
+	if err != nil {
+		t.Fatalf("StartDashboard: %v", err)
+	}
+	post := func(action, contentType, body string) (int, ControlState) {
+		resp, err := http.Post("http://"+addr+"/control/"+action, contentType, strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("POST /control/%s: %v", action, err)
+		}
+		defer resp.Body.Close()
+		var st ControlState
+		json.NewDecoder(resp.Body).Decode(&st)
+		return resp.StatusCode, st
+	}
+
+	if code, st := post("pause", "application/json", "{}"); code != http.StatusOK || !st.Paused {
+		t.Fatalf("pause: %d %+v", code, st)
+	}
+	if code, st := post("steer", "application/json", `{"message":"try again"}`); code != http.StatusOK || st.PendingSteer != 1 {
+		t.Fatalf("steer: %d %+v", code, st)
+	}
+	if code, _ := post("steer", "application/json", `{"message":"  "}`); code != http.StatusBadRequest {
+		t.Fatalf("empty steer: %d", code)
+	}
+	if code, _ := post("stop", "application/x-www-form-urlencoded", ""); code != http.StatusUnsupportedMediaType {
+		t.Fatalf("form POST: %d", code)
+	}
+	if code, _ := post("explode", "application/json", "{}"); code != http.StatusNotFound {
+		t.Fatalf("unknown action: %d", code)
+	}
+	resp, err := http.Get("http://" + addr + "/control/stop")
+	if err != nil {
+		t.Fatalf("GET /control/stop: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("GET /control/stop: %d", resp.StatusCode)
+	}
+
+	resp, err = http.Get("http://" + addr + "/control")
+	if err != nil {
+		t.Fatalf("GET /control: %v", err)
+	}
+	defer resp.Body.Close()
+	var st ControlState
+	if json.NewDecoder(resp.Body).Decode(&st); !st.Paused || st.Stopped || st.PendingSteer != 1 {
+		t.Fatalf("GET /control = %+v", st)
+	}
+	if st := b.Control().State(); !st.Paused || st.PendingSteer != 1 {
+		t.Fatalf("broker control = %+v", st)
+	}
+}
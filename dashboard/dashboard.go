@@ -8,7 +8,11 @@ import (
 	"net"
 	"net/http"
 	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 )
 
 //go:embed web/*
@@ -18,18 +22,27 @@ var webContent embed.FS
 
 // IterationEvent represents an SSE event payload for the web dashboard.
 type IterationEvent struct {
-	Type         string      `json:"type"` // "task_info", "iteration_start", "iteration_end", "error", "complete"
+	Type         string      `json:"type"` // "task_info", "iteration_start", "orchestrator_delta", "pane_update", "iteration_end", "error", "control", "complete", "dropped"
 	Iteration    int         `json:"iteration"`
 	MaxIter      int         `json:"max_iter"`
 	Timestamp    string      `json:"timestamp"`
 	DurationMs   int64       `json:"duration_ms,omitempty"`
 	Tokens       *TokenUsage `json:"tokens,omitempty"`
 	Orchestrator string      `json:"orchestrator,omitempty"`
-	ClaudeOutput string      `json:"claude_output,omitempty"`
+	Delta        string      `json:"delta,omitempty"`         // partial orchestrator reply (orchestrator_delta only)
+	Pane         string      `json:"pane,omitempty"`          // live agent pane with ANSI escapes (pane_update only)
+	ClaudeOutput string      `json:"claude_output,omitempty"` // Deprecated: no longer set; read AgentOutput
 	AgentOutput  string      `json:"agent_output,omitempty"`
+	AgentANSI    string      `json:"agent_ansi,omitempty"` // visible screen of the agent's pane, with ANSI escapes (iteration_end only)
 	Error        string      `json:"error,omitempty"`
 	Task         string      `json:"task,omitempty"`
 	Model        string      `json:"model,omitempty"`
+	Checkpoint   string      `json:"checkpoint,omitempty"` // git checkpoint commit (iteration_end only)
+	Cost         float64     `json:"cost,omitempty"`       // cumulative run cost in USD
+	Control      string      `json:"control,omitempty"`    // paused, resumed, steered or stopped (control only)
+	Message      string      `json:"message,omitempty"`    // operator message (control "steered" only)
+	MaxCost      float64     `json:"max_cost,omitempty"`   // cost budget in USD (task_info only)
+	Dropped      int         `json:"dropped,omitempty"`    // events the client will never receive (dropped only)
 }
 
 // TokenUsage tracks prompt, completion, and total token counts.
@@ -39,13 +52,56 @@ type TokenUsage struct {
 	Total      int `json:"total"`
 }
 
+// ReplayBufferEvents and ReplayBufferBytes bound each broker's replay buffer:
+// the most recent events that are resent to clients that connect or
+// reconnect mid-run. The oldest events are evicted first.
+var (
+	ReplayBufferEvents = 2000
+	ReplayBufferBytes  = 16 << 20 // 16 MiB
+)
+
+// clientBuffer is the number of events a client may fall behind before
+// events are dropped for it.
+const clientBuffer = 64
+
 // SSEBroker manages fan-out of SSE events to multiple connected clients.
-// It retains the last task_info payload so late-connecting clients (or
-// reconnects) immediately receive the current task metadata.
+// Every event gets a sequential ID and is kept in a bounded replay buffer, so
+// clients that connect mid-run or reconnect with Last-Event-ID catch up. Only
+// the latest pane_update is buffered, since each one supersedes the last. The
+// last task_info payload is retained even after it leaves the buffer.
 type SSEBroker struct {
 	mu           sync.Mutex
-	clients      []chan string
+	clients      []*client
+	lastID       uint64
+	buffer       []bufferedEvent // oldest first
+	bufferBytes  int
 	lastTaskInfo string // SSE payload for the most recent task_info event
+	taskInfoID   uint64 // its event ID
+	dropped      int    // events dropped for slow clients, over all clients
+	paneID       uint64 // ID of the buffered pane_update, if any
+	control      *Control
+}
+
+// bufferedEvent is one published event in the replay buffer.
+type bufferedEvent struct {
+	id   uint64
+	data string // the "data: ...\n\n" payload
+}
+
+// frame returns the event as an SSE message with its ID. The ID comes after
+// the data line so messages still start with "data: ".
+func (e bufferedEvent) frame() string {
+	return fmt.Sprintf("%sid: %d\n\n", strings.TrimSuffix(e.data, "\n"), e.id)
+}
+
+// client is one subscriber. Clients from SubscribeSince get messages with
+// event IDs and are disconnected when they fall behind, so they can resume
+// from the replay buffer; Subscribe clients get bare payloads and miss
+// whatever does not fit their channel.
+type client struct {
+	ch      chan string
+	ids     bool
+	dropped int // events that did not fit ch, not yet reported to the client
 }
 
 // NewSSEBroker creates a new SSEBroker instance.
@@ -56,32 +112,99 @@ func NewSSEBroker() *SSEBroker {
 // Subscribe adds a new client and returns its event channel and an unsubscribe function.
 // If a task_info event was previously published, it is replayed to the new client immediately.
 func (b *SSEBroker) Subscribe() (<-chan string, func()) {
-	ch := make(chan string, 64) // buffer channel of 64
+	c := &client{ch: make(chan string, clientBuffer)}
 	b.mu.Lock()
-	b.clients = append(b.clients, ch)
+	b.clients = append(b.clients, c)
 	// Replay the last task_info so late joiners see the task metadata.
 	if b.lastTaskInfo != "" {
 		select {
-		case ch <- b.lastTaskInfo: // non-blocking, may drop if not available
+		case c.ch <- b.lastTaskInfo: // non-blocking, may drop if not available
 		default:
 		}
 	}
 	b.mu.Unlock()
-	return ch, func() {
-		b.mu.Lock()
-		defer b.mu.Unlock()
-		for i, c := range b.clients {
-			if c == ch {
-				b.clients = append(b.clients[:i], b.clients[i+1:]...) // skip the ith
-				close(ch)
-				return
-			}
+	return c.ch, func() { b.unsubscribe(c) }
+}
+
+// SubscribeSince adds a client that resumes after event lastID, or receives
+// everything still buffered when lastID is 0 or unknown (e.g. from before a
+// restart). It returns the backlog of SSE messages to send first, the channel
+// of later messages and an unsubscribe function. When events the client needs
+// have left the buffer, the backlog starts with a "dropped" event counting
+// them. The channel is closed if the client falls behind; it should then
+// reconnect with the ID of the last event it received.
+func (b *SSEBroker) SubscribeSince(lastID uint64) ([]string, <-chan string, func()) {
+	c := &client{ch: make(chan string, clientBuffer), ids: true}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if lastID > b.lastID {
+		lastID = 0
+	}
+	oldest := b.lastID + 1 // ID of the oldest buffered event
+	if len(b.buffer) > 0 {
+		oldest = b.buffer[0].id
+	}
+	var backlog []string
+	if lastID == 0 && b.lastTaskInfo != "" && b.taskInfoID < oldest {
+		backlog = append(backlog, b.lastTaskInfo) // evicted, but still needed
+	}
+	if oldest > lastID+1 {
+		backlog = append(backlog, droppedPayload(int(oldest-1-lastID)))
+	}
+	for _, e := range b.buffer {
+		if e.id > lastID {
+			backlog = append(backlog, e.frame())
 		}
 	}
+	b.clients = append(b.clients, c)
+	return backlog, c.ch, func() { b.unsubscribe(c) }
+}
+
+// droppedPayload returns a "dropped" event telling a client that n events
+// it should have received are gone.
+func droppedPayload(n int) string {
+	data, _ := json.Marshal(IterationEvent{
+		Type:      "dropped",
+		Timestamp: time.Now().Format(time.RFC3339),
+		Dropped:   n,
+	})
+	return fmt.Sprintf("data: %s\n\n", data)
 }
 
-// Publish sends an event to all connected clients (non-blocking).
-// task_info events are retained so they can be replayed to late subscribers.
+// unsubscribe removes c and closes its channel, if that has not happened yet.
+func (b *SSEBroker) unsubscribe(c *client) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i, other := range b.clients {
+		if other == c {
+			b.clients = append(b.clients[:i], b.clients[i+1:]...) // skip the ith
+			close(c.ch)
+			return
+		}
+	}
+}
+
+// Clients returns the number of currently subscribed clients.
+func (b *SSEBroker) Clients() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.clients)
+}
+
+// Dropped returns how many events did not fit a slow client's channel,
+// summed over all clients. Clients with event IDs recover these from the
+// replay buffer when they reconnect.
+func (b *SSEBroker) Dropped() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.dropped
+}
+
+// Publish sends an event to all connected clients (non-blocking) and adds it
+// to the replay buffer, replacing the previous pane_update. task_info events
+// are also retained on their own so they can be replayed to late subscribers.
+// A Subscribe client that missed events is sent a "dropped" event with their
+// count once its channel has room again.
 // Safe to call on a nil receiver (no-op).
 func (b *SSEBroker) Publish(event IterationEvent) {
 	if b == nil {
@@ -94,20 +217,130 @@ func (b *SSEBroker) Publish(event IterationEvent) {
 	payload := fmt.Sprintf("data: %s\n\n", data)
 	b.mu.Lock()
 	defer b.mu.Unlock()
+	b.lastID++
 	if event.Type == "task_info" {
-		b.lastTaskInfo = payload
+		b.lastTaskInfo, b.taskInfoID = payload, b.lastID
+	}
+	if event.Type == "pane_update" {
+		b.removeBuffered(b.paneID)
+		b.paneID = b.lastID
 	}
-	for _, ch := range b.clients {
+	e := bufferedEvent{id: b.lastID, data: payload}
+	b.buffer = append(b.buffer, e)
+	b.bufferBytes += len(payload)
+	for len(b.buffer) > 1 && (len(b.buffer) > ReplayBufferEvents || b.bufferBytes > ReplayBufferBytes) {
+		b.bufferBytes -= len(b.buffer[0].data)
+		b.buffer = b.buffer[1:]
+	}
+
+	frame := e.frame()
+	kept := b.clients[:0]
+	for _, c := range b.clients {
+		msg := payload
+		if c.ids {
+			msg = frame
+		}
+		if c.dropped > 0 {
+			select {
+			case c.ch <- droppedPayload(c.dropped):
+				c.dropped = 0
+			default:
+			}
+		}
 		select {
-		case ch <- payload:
+		case c.ch <- msg:
 		default:
+			b.dropped++
+			if c.ids {
+				// Disconnect it; it resumes from the buffer with Last-Event-ID.
+				close(c.ch)
+				continue
+			}
+			c.dropped++
 		}
+		kept = append(kept, c)
 	}
+	b.clients = kept
+}
+
+// removeBuffered drops the event with the given ID from the replay buffer.
+func (b *SSEBroker) removeBuffered(id uint64) {
+	for i, e := range b.buffer {
+		if e.id == id {
+			b.bufferBytes -= len(e.data)
+			b.buffer = append(b.buffer[:i], b.buffer[i+1:]...)
+			return
+		}
+	}
+}
+
+// CheckpointDiff, if set, renders a run's git checkpoint commit for the
+// /checkpoint endpoint that iteration cards link to.
+var CheckpointDiff func(run, commit string) (string, error)
+
+// commitPattern matches abbreviated or full git object names.
+var commitPattern = regexp.MustCompile(`^[0-9a-f]{4,64}$`)
+
+// Registry holds one SSEBroker per run so a single dashboard can serve
+// several concurrent runs. Clients pick a run with /events?run=<id>.
+type Registry struct {
+	mu      sync.Mutex
+	brokers map[string]*SSEBroker
+	ids     []string // registration order
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{brokers: map[string]*SSEBroker{}}
+}
+
+// Broker returns the broker for run id, creating it on first use.
+func (g *Registry) Broker(id string) *SSEBroker {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	b, ok := g.brokers[id]
+	if !ok {
+		b = NewSSEBroker()
+		g.brokers[id] = b
+		g.ids = append(g.ids, id)
+	}
+	return b
+}
+
+// Lookup returns the broker for run id, or the first registered run's broker
+// when id is empty. It returns nil if there is no such run.
+func (g *Registry) Lookup(id string) *SSEBroker {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if id == "" && len(g.ids) > 0 {
+		id = g.ids[0]
+	}
+	return g.brokers[id]
+}
+
+// Runs returns the registered run IDs in registration order.
+func (g *Registry) Runs() []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]string(nil), g.ids...)
 }
 
-// StartDashboard starts the web dashboard HTTP server.
+// StartDashboard starts the web dashboard HTTP server for a single run.
 // It returns the address the server is listening on.
 func StartDashboard(broker *SSEBroker, port int) (string, error) {
+	return serve(port, func(string) *SSEBroker { return broker }, nil)
+}
+
+// StartRegistryDashboard starts the web dashboard HTTP server for every run
+// in reg. /runs lists the run IDs and /events?run=<id> streams one run.
+// It returns the address the server is listening on.
+func StartRegistryDashboard(reg *Registry, port int) (string, error) {
+	return serve(port, reg.Lookup, reg.Runs)
+}
+
+// serve starts the dashboard HTTP server. lookup resolves the ?run= query
+// parameter of /events to a broker; runs, if set, backs the /runs endpoint.
+func serve(port int, lookup func(run string) *SSEBroker, runs func() []string) (string, error) {
 	mux := http.NewServeMux()
 
 	webFS, err := fs.Sub(webContent, "web")
@@ -116,7 +349,44 @@ func StartDashboard(broker *SSEBroker, port int) (string, error) {
 	}
 	mux.Handle("/", http.FileServer(http.FS(webFS)))
 
+	if runs != nil {
+		mux.HandleFunc("/runs", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(runs())
+		})
+	}
+
+	mux.HandleFunc("/checkpoint", func(w http.ResponseWriter, r *http.Request) {
+		commit := r.URL.Query().Get("commit")
+		if CheckpointDiff == nil {
+			http.Error(w, "git checkpoints are not available", http.StatusNotFound)
+			return
+		}
+		if !commitPattern.MatchString(commit) {
+			http.Error(w, "invalid commit", http.StatusBadRequest)
+			return
+		}
+		text, err := CheckpointDiff(r.URL.Query().Get("run"), commit)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprint(w, text)
+	})
+
+	mux.HandleFunc("/history", handleHistory)
+	mux.HandleFunc("/history/events", handleHistoryEvents)
+
+	mux.HandleFunc("/control", handleControl(lookup))
+	mux.HandleFunc("/control/", handleControl(lookup))
+
 	mux.HandleFunc("/events", func(w http.ResponseWriter, r *http.Request) {
+		broker := lookup(r.URL.Query().Get("run"))
+		if broker == nil {
+			http.Error(w, "unknown run", http.StatusNotFound)
+			return
+		}
 		flusher, ok := w.(http.Flusher)
 		if !ok {
 			http.Error(w, "streaming unsupported", http.StatusInternalServerError)
@@ -128,10 +398,20 @@ func StartDashboard(broker *SSEBroker, port int) (string, error) {
 		w.Header().Set("Connection", "keep-alive")
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 
-		ch, unsubscribe := broker.Subscribe()
+		// Browsers send Last-Event-ID when EventSource reconnects by itself;
+		// app.js reconnects with a new EventSource and passes ?last_event_id=.
+		lastID := r.Header.Get("Last-Event-ID")
+		if lastID == "" {
+			lastID = r.URL.Query().Get("last_event_id")
+		}
+		since, _ := strconv.ParseUint(lastID, 10, 64)
+		backlog, ch, unsubscribe := broker.SubscribeSince(since)
 		defer unsubscribe()
 
 		fmt.Fprintf(w, "data: {\"type\":\"connected\"}\n\n")
+		for _, msg := range backlog {
+			fmt.Fprint(w, msg)
+		}
 		flusher.Flush()
 
 		for {
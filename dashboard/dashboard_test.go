@@ -3,6 +3,7 @@ package dashboard
 import (
 	"bufio"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"strings"
@@ -114,6 +115,21 @@ func TestSSEBroker_Unsubscribe(t *testing.T) {
 	b.Publish(IterationEvent{Type: "complete"})
 }
 
+// Clients counts current subscribers.
+func TestSSEBroker_Clients(t *testing.T) {
+	b := NewSSEBroker()
+	_, unsub1 := b.Subscribe()
+	_, unsub2 := b.Subscribe()
+	if n := b.Clients(); n != 2 {
+		t.Fatalf("expected 2 clients, got %d", n)
+	}
+	unsub1()
+	unsub2()
+	if n := b.Clients(); n != 0 {
+		t.Fatalf("expected 0 clients, got %d", n)
+	}
+}
+
 // A full buffer does not block the publisher.
 func TestSSEBroker_SlowClientDrop(t *testing.T) {
 	b := NewSSEBroker()
@@ -141,6 +157,184 @@ done:
 	}
 }
 
+// A client that missed events is told how many once it catches up.
+func TestSSEBroker_SlowClientToldDropped(t *testing.T) {
+	b := NewSSEBroker()
+	ch, unsub := b.Subscribe()
+	defer unsub()
+	for i := 0; i < clientBuffer+6; i++ {
+		b.Publish(IterationEvent{Type: "iteration_end", Iteration: i})
+	}
+	for i := 0; i < clientBuffer; i++ {
+		<-ch
+	}
+	b.Publish(IterationEvent{Type: "complete"})
+	if msg := <-ch; !strings.Contains(msg, `"type":"dropped"`) || !strings.Contains(msg, `"dropped":6`) {
+		t.Fatalf("expected a dropped event, got %q", msg)
+	}
+	if msg := <-ch; !strings.Contains(msg, `"type":"complete"`) {
+		t.Fatalf("expected the complete event, got %q", msg)
+	}
+}
+
+// Only the latest pane_update is buffered, so pane frames cannot evict
+// iteration events.
+func TestSSEBroker_BuffersLatestPaneOnly(t *testing.T) {
+	old := ReplayBufferEvents
+	ReplayBufferEvents = 3
+	defer func() { ReplayBufferEvents = old }()
+
+	b := NewSSEBroker()
+	b.Publish(IterationEvent{Type: "iteration_start", Iteration: 1})
+	for i := 0; i < 10; i++ {
+		b.Publish(IterationEvent{Type: "pane_update", Pane: fmt.Sprint("frame ", i)})
+	}
+	b.Publish(IterationEvent{Type: "iteration_end", Iteration: 1})
+
+	backlog, _, unsub := b.SubscribeSince(0)
+	unsub()
+	if got := fmt.Sprint(eventIDs(backlog)); got != "[1 11 12]" || !strings.Contains(backlog[1], "frame 9") {
+		t.Fatalf("backlog: %q", backlog)
+	}
+}
+
+// eventIDs returns the "id:" values of SSE messages, or 0 for messages without one.
+func eventIDs(msgs []string) []int {
+	ids := make([]int, len(msgs))
+	for i, msg := range msgs {
+		if _, after, ok := strings.Cut(msg, "\nid: "); ok {
+			fmt.Sscanf(after, "%d", &ids[i])
+		}
+	}
+	return ids
+}
+
+// SubscribeSince returns the buffered events after the given ID, or all of
+// them for a new client or an ID from before a restart.
+func TestSSEBroker_SubscribeSince(t *testing.T) {
+	for _, tc := range []struct {
+		since uint64
+		want  string
+	}{
+		{0, "[1 2 3 4]"},
+		{2, "[3 4]"},
+		{4, "[]"},
+		{99, "[1 2 3 4]"},
+	} {
+		b := NewSSEBroker()
+		b.Publish(IterationEvent{Type: "task_info", Task: "t"})
+		for i := 1; i <= 3; i++ {
+			b.Publish(IterationEvent{Type: "iteration_end", Iteration: i})
+		}
+		backlog, ch, unsub := b.SubscribeSince(tc.since)
+		if got := fmt.Sprint(eventIDs(backlog)); got != tc.want {
+			t.Errorf("since %d: backlog IDs %s, want %s", tc.since, got, tc.want)
+		}
+		b.Publish(IterationEvent{Type: "iteration_start", Iteration: 4})
+		if msg := <-ch; !strings.HasPrefix(msg, "data: ") || eventIDs([]string{msg})[0] != 5 {
+			t.Errorf("since %d: live message %q should carry ID 5", tc.since, msg)
+		}
+		unsub()
+	}
+}
+
+// Evicted events are counted in a "dropped" event, and the last task_info
+// survives eviction.
+func TestSSEBroker_ReplayBufferEviction(t *testing.T) {
+	old := ReplayBufferEvents
+	ReplayBufferEvents = 3
+	defer func() { ReplayBufferEvents = old }()
+
+	b := NewSSEBroker()
+	b.Publish(IterationEvent{Type: "task_info", Task: "t"})
+	for i := 1; i <= 5; i++ {
+		b.Publish(IterationEvent{Type: "iteration_end", Iteration: i})
+	}
+
+	backlog, _, unsub := b.SubscribeSince(0)
+	unsub()
+	if len(backlog) != 5 || !strings.Contains(backlog[0], `"task":"t"`) ||
+		!strings.Contains(backlog[1], `"type":"dropped"`) || !strings.Contains(backlog[1], `"dropped":3`) {
+		t.Fatalf("new client backlog: %q", backlog)
+	}
+	if got := fmt.Sprint(eventIDs(backlog)); got != "[0 0 4 5 6]" {
+		t.Fatalf("new client backlog IDs: %s", got)
+	}
+
+	backlog, _, unsub = b.SubscribeSince(1)
+	unsub()
+	if len(backlog) != 4 || !strings.Contains(backlog[0], `"dropped":2`) {
+		t.Fatalf("resumed backlog: %q", backlog)
+	}
+
+	ReplayBufferEvents, ReplayBufferBytes = 100, 1
+	defer func() { ReplayBufferBytes = 16 << 20 }()
+	b.Publish(IterationEvent{Type: "complete"})
+	backlog, _, unsub = b.SubscribeSince(6)
+	unsub()
+	if got := fmt.Sprint(eventIDs(backlog)); got != "[7]" {
+		t.Fatalf("the newest event must stay buffered: %s", got)
+	}
+}
+
+// A client with event IDs that falls behind is disconnected, its drops are
+// counted, and it resumes from the buffer where it left off.
+func TestSSEBroker_SlowClientResumes(t *testing.T) {
+	b := NewSSEBroker()
+	_, ch, unsub := b.SubscribeSince(0)
+	defer unsub()
+	for i := 1; i <= 70; i++ {
+		b.Publish(IterationEvent{Type: "iteration_end", Iteration: i})
+	}
+	var received []string
+	for msg := range ch {
+		received = append(received, msg)
+	}
+	if len(received) != clientBuffer || b.Dropped() != 1 || b.Clients() != 0 {
+		t.Fatalf("received %d, dropped %d, clients %d", len(received), b.Dropped(), b.Clients())
+	}
+	last := eventIDs(received)[len(received)-1]
+	backlog, _, unsub2 := b.SubscribeSince(uint64(last))
+	defer unsub2()
+	if got := eventIDs(backlog); len(got) != 70-clientBuffer || got[0] != clientBuffer+1 {
+		t.Fatalf("resumed backlog IDs: %v", got)
+	}
+}
+
+// /events resumes after the Last-Event-ID header.
+func TestStartDashboard_LastEventID(t *testing.T) {
+	b := NewSSEBroker()
+	for i := 1; i <= 3; i++ {
+		b.Publish(IterationEvent{Type: "iteration_end", Iteration: i})
+	}
+	addr, err := StartDashboard(b, 0)
+	if err != nil {
+		t.Fatalf("StartDashboard: %v", err)
+	}
+	req, _ := http.NewRequest("GET", "http://"+addr+"/events", nil)
+	req.Header.Set("Last-Event-ID", "2")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET /events: %v", err)
+	}
+	defer resp.Body.Close()
+
+	reader := bufio.NewReader(resp.Body)
+	var lines []string
+	for len(lines) < 3 {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if !strings.Contains(lines[0], "connected") || !strings.Contains(lines[1], `"iteration":3`) || lines[2] != "id: 3" {
+		t.Fatalf("unexpected stream: %q", lines)
+	}
+}
+
 // Publish on a nil broker does not panic.
 func TestPublish_NilBroker(t *testing.T) {
 	var b *SSEBroker
@@ -286,3 +480,87 @@ func TestStartDashboard_SSEStream(t *testing.T) {
 		t.Fatalf("expected task_info event with test-task, got: %s", line)
 	}
 }
+
+// A registry dashboard lists its runs and streams each run's events separately.
+func TestStartRegistryDashboard(t *testing.T) {
+	reg := NewRegistry()
+	reg.Broker("run-a").Publish(IterationEvent{Type: "task_info", Task: "task-a"})
+	reg.Broker("run-b").Publish(IterationEvent{Type: "task_info", Task: "task-b"})
+	if reg.Broker("run-a") != reg.Lookup("") {
+		t.Fatal("empty run should resolve to the first registered run")
+	}
+	addr, err := StartRegistryDashboard(reg, 0)
+	if err != nil {
+		t.Fatalf("StartRegistryDashboard: %v", err)
+	}
+	base := "http://" + addr
+
+	resp, err := http.Get(base + "/runs")
+	if err != nil {
+		t.Fatalf("GET /runs: %v", err)
+	}
+	var runs []string
+	json.NewDecoder(resp.Body).Decode(&runs)
+	resp.Body.Close()
+	if strings.Join(runs, ",") != "run-a,run-b" {
+		t.Fatalf("runs = %v", runs)
+	}
+
+	resp, err = http.Get(base + "/events?run=missing")
+	if err != nil {
+		t.Fatalf("GET /events: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("unknown run: status %d", resp.StatusCode)
+	}
+
+	resp, err = http.Get(base + "/events?run=run-b")
+	if err != nil {
+		t.Fatalf("GET /events: %v", err)
+	}
+	defer resp.Body.Close()
+	reader := bufio.NewReader(resp.Body)
+	var got []string
+	for len(got) < 2 {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		if line = strings.TrimSpace(line); line != "" {
+			got = append(got, line)
+		}
+	}
+	if !strings.Contains(got[1], "task-b") {
+		t.Fatalf("expected run-b's task_info, got %v", got)
+	}
+}
+
+// /checkpoint renders a commit through CheckpointDiff and rejects anything that is not a hash.
+func TestStartDashboard_Checkpoint(t *testing.T) {
+	addr, err := StartDashboard(NewSSEBroker(), 0)
+	if err != nil {
+		t.Fatalf("StartDashboard: %v", err)
+	}
+	get := func(query string) (int, string) {
+		resp, err := http.Get("http://" + addr + "/checkpoint?" + query)
+		if err != nil {
+			t.Fatalf("GET /checkpoint: %v", err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+	if code, _ := get("commit=abc123"); code != http.StatusNotFound {
+		t.Fatalf("without CheckpointDiff: status %d", code)
+	}
+
+	CheckpointDiff = func(run, commit string) (string, error) { return run + ":" + commit, nil }
+	defer func() { CheckpointDiff = nil }()
+	if code, body := get("run=r1&commit=abc123"); code != http.StatusOK || body != "r1:abc123" {
+		t.Fatalf("got %d %q", code, body)
+	}
+	if code, _ := get("commit=--output=/tmp/x"); code != http.StatusBadRequest {
+		t.Fatalf("option-like commit: status %d", code)
+	}
+}
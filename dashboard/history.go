@@ -0,0 +1,77 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+// RunSummary describes one run on disk for the history page.
+type RunSummary struct {
+	RunID      string    `json:"run_id"`
+	Task       string    `json:"task"`
+	Agent      string    `json:"agent,omitempty"`
+	Provider   string    `json:"provider,omitempty"`
+	Model      string    `json:"model"`
+	Status     string    `json:"status"`
+	Iterations int       `json:"iterations"`
+	Tokens     int       `json:"tokens"`
+	Cost       float64   `json:"cost,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	DurationMs int64     `json:"duration_ms"`      // from start to the last update
+	Events     bool      `json:"events,omitempty"` // the run's events were recorded
+}
+
+// RunHistory, if set, lists the runs on disk, newest first, for /history.
+var RunHistory func() ([]RunSummary, error)
+
+// RunEvents, if set, loads the recorded events of a run for
+// /history/events, from which the dashboard rebuilds its iteration cards.
+var RunEvents func(run string) ([]IterationEvent, error)
+
+// runIDPattern matches run IDs; it keeps /history/events inside the runs
+// directory.
+var runIDPattern = regexp.MustCompile(`^[0-9A-Za-z][0-9A-Za-z._-]*$`)
+
+// handleHistory serves GET /history.
+func handleHistory(w http.ResponseWriter, r *http.Request) {
+	if RunHistory == nil {
+		http.Error(w, "run history is not available", http.StatusNotFound)
+		return
+	}
+	runs, err := RunHistory()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if runs == nil {
+		runs = []RunSummary{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(runs)
+}
+
+// handleHistoryEvents serves GET /history/events?run=<id>.
+func handleHistoryEvents(w http.ResponseWriter, r *http.Request) {
+	run := r.URL.Query().Get("run")
+	if RunEvents == nil {
+		http.Error(w, "run history is not available", http.StatusNotFound)
+		return
+	}
+	if !runIDPattern.MatchString(run) {
+		http.Error(w, "invalid run", http.StatusBadRequest)
+		return
+	}
+	events, err := RunEvents(run)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if events == nil {
+		events = []IterationEvent{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(events)
+}
@@ -0,0 +1,61 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+// /history lists runs through RunHistory and /history/events loads a run's
+// events through RunEvents, rejecting run IDs that could escape the runs directory.
+func TestStartDashboard_History(t *testing.T) {
+	addr, err := StartDashboard(NewSSEBroker(), 0)
+	if err != nil {
+		t.Fatalf("StartDashboard: %v", err)
+	}
+	get := func(path string, v any) int {
+		resp, err := http.Get("http://" + addr + path)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		defer resp.Body.Close()
+		if v != nil && resp.StatusCode == http.StatusOK {
+			if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+				t.Fatalf("GET %s: decode: %v", path, err)
+			}
+		}
+		return resp.StatusCode
+	}
+	if code := get("/history", nil); code != http.StatusNotFound {
+		t.Fatalf("without RunHistory: status %d", code)
+	}
+
+	RunHistory = func() ([]RunSummary, error) {
+		return []RunSummary{{RunID: "r2", Task: "second", Status: "complete", Iterations: 3}, {RunID: "r1"}}, nil
+	}
+	RunEvents = func(run string) ([]IterationEvent, error) {
+		if run != "r2" {
+			return nil, errors.New("no transcript")
+		}
+		return []IterationEvent{{Type: "task_info", Task: "second"}, {Type: "iteration_end", Iteration: 1}}, nil
+	}
+	defer func() { RunHistory, RunEvents = nil, nil }()
+
+	var runs []RunSummary
+	if code := get("/history", &runs); code != http.StatusOK || len(runs) != 2 || runs[0].Task != "second" || runs[0].Iterations != 3 {
+		t.Fatalf("GET /history: %d %+v", code, runs)
+	}
+	var events []IterationEvent
+	if code := get("/history/events?run=r2", &events); code != http.StatusOK || len(events) != 2 || events[1].Type != "iteration_end" {
+		t.Fatalf("GET /history/events: %d %+v", code, events)
+	}
+	if code := get("/history/events?run=r1", nil); code != http.StatusNotFound {
+		t.Fatalf("run without events: status %d", code)
+	}
+	for _, run := range []string{"", "..", "../etc", "a/b"} {
+		if code := get("/history/events?run="+run, nil); code != http.StatusBadRequest {
+			t.Fatalf("run %q: status %d", run, code)
+		}
+	}
+}
@@ -7,6 +7,10 @@
     var totalDurationMs = 0;
     var totalErrors = 0;
     var maxIter = 0;
+    var totalCost = 0;
+    var maxCost = 0;
+    var droppedEvents = 0;
+    var lastEventId = ""; // ID of the last event received, to resume after a reconnect
 
     // DOM references
     var els = {
@@ -18,6 +22,7 @@
         summary: document.getElementById("summary"),
         totalIterations: document.getElementById("total-iterations"),
         totalTokens: document.getElementById("total-tokens"),
+        totalCost: document.getElementById("total-cost"),
         totalDuration: document.getElementById("total-duration"),
         totalErrors: document.getElementById("total-errors"),
         progressBarContainer: document.getElementById("progress-bar-container"),
@@ -27,7 +32,21 @@
         spinner: document.getElementById("spinner"),
         completionBanner: document.getElementById("completion-banner"),
         completionTitle: document.getElementById("completion-title"),
-        completionMessage: document.getElementById("completion-message")
+        completionMessage: document.getElementById("completion-message"),
+        controls: document.getElementById("controls"),
+        pauseButton: document.getElementById("pause-button"),
+        resumeButton: document.getElementById("resume-button"),
+        stopButton: document.getElementById("stop-button"),
+        controlStatus: document.getElementById("control-status"),
+        steerInput: document.getElementById("steer-input"),
+        steerButton: document.getElementById("steer-button"),
+        livePaneCard: document.getElementById("live-pane-card"),
+        livePane: document.getElementById("live-pane"),
+        livePaneMeta: document.getElementById("live-pane-meta"),
+        history: document.getElementById("history"),
+        historyMessage: document.getElementById("history-message"),
+        historyList: document.getElementById("history-list"),
+        streamNotice: document.getElementById("stream-notice")
     };
 
     function formatDuration(ms) {
@@ -42,9 +61,14 @@
         return n.toLocaleString();
     }
 
+    function formatCost(usd) {
+        return "$" + usd.toFixed(usd > 0 && usd < 1 ? 4 : 2);
+    }
+
     function updateSummary() {
         els.totalIterations.textContent = totalIterations;
         els.totalTokens.textContent = formatNumber(totalTokens);
+        els.totalCost.textContent = formatCost(totalCost) + (maxCost > 0 ? " / " + formatCost(maxCost) : "");
         els.totalDuration.textContent = formatDuration(totalDurationMs);
         els.totalErrors.textContent = totalErrors;
 
@@ -56,6 +80,121 @@
         }
     }
 
+    // The 16 standard terminal colors, tuned for the dark theme.
+    var ANSI_COLORS = [
+        "#3b3b3b", "#f14c4c", "#23d18b", "#f5f543", "#3b8eea", "#d670d6", "#29b8db", "#cccccc",
+        "#666666", "#ff6b6b", "#3fe0a0", "#ffff7a", "#6cb2ff", "#f08ef0", "#4fd6f0", "#ffffff"
+    ];
+
+    // Color n of the xterm 256-color palette.
+    function ansi256(n) {
+        if (n < 16) return ANSI_COLORS[n];
+        if (n < 232) {
+            var steps = [0, 95, 135, 175, 215, 255];
+            n -= 16;
+            return "rgb(" + steps[Math.floor(n / 36)] + "," + steps[Math.floor(n / 6) % 6] + "," + steps[n % 6] + ")";
+        }
+        var g = 8 + (n - 232) * 10;
+        return "rgb(" + g + "," + g + "," + g + ")";
+    }
+
+    // Extended color from SGR 38/48 arguments ("5;n" or "2;r;g;b"). It returns
+    // the color and how many arguments were used.
+    function extendedColor(args) {
+        if (args[0] === 5 && args.length >= 2) return { color: ansi256(args[1] & 255), used: 2 };
+        if (args[0] === 2 && args.length >= 4) {
+            return { color: "rgb(" + (args[1] & 255) + "," + (args[2] & 255) + "," + (args[3] & 255) + ")", used: 4 };
+        }
+        return { color: null, used: args.length };
+    }
+
+    // Apply the parameters of one SGR ("ESC [ ... m") sequence to style.
+    function applySGR(style, params) {
+        var parts = params === "" ? ["0"] : params.split(";");
+        for (var i = 0; i < parts.length; i++) {
+            // Colon-separated sub-parameters, e.g. 38:2::255:0:0 or 4:3.
+            if (parts[i].indexOf(":") >= 0) {
+                var sub = parts[i].split(":").map(Number);
+                if (sub[0] === 38 || sub[0] === 48) {
+                    var rest = sub.slice(1);
+                    if (rest[0] === 2 && rest.length >= 5) rest.splice(1, 1); // drop the color space ID
+                    var c = extendedColor(rest).color;
+                    if (sub[0] === 38) style.fg = c; else style.bg = c;
+                } else if (sub[0] === 4) {
+                    style.underline = sub[1] !== 0;
+                }
+                continue;
+            }
+            var code = Number(parts[i]);
+            if (code === 38 || code === 48) {
+                var ext = extendedColor(parts.slice(i + 1).map(Number));
+                if (code === 38) style.fg = ext.color; else style.bg = ext.color;
+                i += ext.used;
+            } else if (code === 0) {
+                for (var key in style) delete style[key];
+            } else if (code === 1) style.bold = true;
+            else if (code === 2) style.dim = true;
+            else if (code === 3) style.italic = true;
+            else if (code === 4) style.underline = true;
+            else if (code === 7) style.inverse = true;
+            else if (code === 9) style.strike = true;
+            else if (code === 22) style.bold = style.dim = false;
+            else if (code === 23) style.italic = false;
+            else if (code === 24) style.underline = false;
+            else if (code === 27) style.inverse = false;
+            else if (code === 29) style.strike = false;
+            else if (code >= 30 && code <= 37) style.fg = ANSI_COLORS[code - 30];
+            else if (code === 39) style.fg = null;
+            else if (code >= 40 && code <= 47) style.bg = ANSI_COLORS[code - 40];
+            else if (code === 49) style.bg = null;
+            else if (code >= 90 && code <= 97) style.fg = ANSI_COLORS[code - 90 + 8];
+            else if (code >= 100 && code <= 107) style.bg = ANSI_COLORS[code - 100 + 8];
+        }
+    }
+
+    // Inline CSS for style, or "" for the default look.
+    function styleCSS(style) {
+        var fg = style.fg, bg = style.bg, css = [];
+        if (style.inverse) {
+            fg = style.bg || "var(--code-bg)";
+            bg = style.fg || "var(--text)";
+        }
+        if (fg) css.push("color:" + fg);
+        if (bg) css.push("background:" + bg);
+        if (style.bold) css.push("font-weight:bold");
+        if (style.dim) css.push("opacity:0.6");
+        if (style.italic) css.push("font-style:italic");
+        var deco = (style.underline ? " underline" : "") + (style.strike ? " line-through" : "");
+        if (deco) css.push("text-decoration:" + deco.trim());
+        return css.join(";");
+    }
+
+    function escapeHTML(s) {
+        return s.replace(/&/g, "&amp;").replace(/</g, "&lt;").replace(/>/g, "&gt;").replace(/"/g, "&quot;");
+    }
+
+    // Render terminal text with ANSI escapes as HTML. Colors and attributes
+    // become styled spans; other escape sequences are dropped, and all text is
+    // HTML-escaped. Trailing blank lines of the scrollback are trimmed.
+    function ansiToHTML(text) {
+        text = text.replace(/\s+$/, "");
+        var re = /\x1b\[([0-9;:?<=>]*)[ -\/]*([@-~])|\x1b\][^\x07\x1b]*(?:\x07|\x1b\\)/g;
+        var out = "", style = {}, open = false, last = 0, m;
+        while ((m = re.exec(text)) !== null) {
+            out += escapeHTML(text.slice(last, m.index));
+            last = re.lastIndex;
+            if (m[2] !== "m") continue;
+            applySGR(style, m[1]);
+            if (open) out += "</span>";
+            var css = styleCSS(style);
+            open = css !== "";
+            if (open) out += "<span style=\"" + css + "\">";
+        }
+        out += escapeHTML(text.slice(last));
+        if (open) out += "</span>";
+        return out;
+    }
+
     function createIterationCard(data) {
         var card = document.createElement("div");
         card.className = "iteration-card";
@@ -81,12 +220,34 @@
             metaDiv.appendChild(tokSpan);
         }
 
+        if (data.model) {
+            var modelSpan = document.createElement("span");
+            modelSpan.className = "iteration-model";
+            modelSpan.title = "Model that answered this iteration";
+            modelSpan.textContent = data.model;
+            metaDiv.appendChild(modelSpan);
+        }
+
         if (data.duration_ms) {
             var durSpan = document.createElement("span");
             durSpan.textContent = formatDuration(data.duration_ms);
             metaDiv.appendChild(durSpan);
         }
 
+        if (data.checkpoint) {
+            var cpLink = document.createElement("a");
+            cpLink.className = "checkpoint-link";
+            var run = currentRun();
+            cpLink.href = "/checkpoint?commit=" + encodeURIComponent(data.checkpoint) +
+                (run ? "&run=" + encodeURIComponent(run) : "");
+            cpLink.target = "_blank";
+            cpLink.title = "Git checkpoint after this iteration";
+            cpLink.textContent = "checkpoint " + data.checkpoint.slice(0, 7);
+            // Follow the link without collapsing the card.
+            cpLink.addEventListener("click", function(e) { e.stopPropagation(); });
+            metaDiv.appendChild(cpLink);
+        }
+
         if (data.error) {
             var errSpan = document.createElement("span");
             errSpan.className = "error";
@@ -122,7 +283,11 @@
             ccH3.textContent = "Agent Output";
             var ccCode = document.createElement("pre");
             ccCode.className = "code-block";
-            ccCode.textContent = agentOutputText;
+            if (data.agent_ansi) {
+                ccCode.innerHTML = ansiToHTML(data.agent_ansi);
+            } else {
+                ccCode.textContent = agentOutputText;
+            }
             ccSection.appendChild(ccH3);
             ccSection.appendChild(ccCode);
             body.appendChild(ccSection);
@@ -179,6 +344,32 @@
         els.spinner.classList.remove("hidden");
     }
 
+    // Append streamed orchestrator tokens to the in-progress card for the iteration.
+    function appendOrchestratorDelta(iteration, delta) {
+        var card = document.getElementById("iter-" + iteration);
+        if (!card) {
+            addIterationStartPlaceholder(iteration);
+            card = document.getElementById("iter-" + iteration);
+        }
+        var live = document.getElementById("iter-" + iteration + "-live");
+        if (!live) {
+            live = document.createElement("pre");
+            live.className = "code-block live-reply";
+            live.id = "iter-" + iteration + "-live";
+            card.appendChild(live);
+        }
+        live.textContent += delta;
+    }
+
+    // Show the agent's pane as it changes, scrolled to the newest output.
+    function updateLivePane(data) {
+        els.livePaneCard.classList.remove("hidden");
+        els.livePane.innerHTML = ansiToHTML(data.pane || "");
+        els.livePane.scrollTop = els.livePane.scrollHeight;
+        var when = data.timestamp ? new Date(data.timestamp).toLocaleTimeString() : "";
+        els.livePaneMeta.textContent = "iteration " + data.iteration + (when ? " \u00b7 " + when : "");
+    }
+
     function handleEvent(event) {
         var data;
         try {
@@ -186,7 +377,11 @@
         } catch (e) {
             return;
         }
+        if (event.lastEventId) lastEventId = event.lastEventId;
+        handleData(data);
+    }
 
+    function handleData(data) {
         switch (data.type) {
             case "connected":
                 els.connectionStatus.textContent = "Connected";
@@ -199,7 +394,10 @@
                 els.taskModel.textContent = data.model || "";
                 maxIter = data.max_iter || 0;
                 els.taskMaxIter.textContent = maxIter > 0 ? maxIter : "Unlimited";
+                maxCost = data.max_cost || 0;
+                totalCost = data.cost || 0;
                 els.summary.classList.remove("hidden");
+                els.controls.classList.remove("hidden");
                 els.spinner.classList.remove("hidden");
                 updateSummary();
                 break;
@@ -208,6 +406,14 @@
                 addIterationStartPlaceholder(data.iteration);
                 break;
 
+            case "orchestrator_delta":
+                appendOrchestratorDelta(data.iteration, data.delta || "");
+                break;
+
+            case "pane_update":
+                updateLivePane(data);
+                break;
+
             case "iteration_end":
                 els.spinner.classList.add("hidden");
                 totalIterations = data.iteration;
@@ -217,6 +423,9 @@
                 if (data.duration_ms) {
                     totalDurationMs += data.duration_ms;
                 }
+                if (data.cost) {
+                    totalCost = data.cost;
+                }
                 if (data.error) {
                     totalErrors++;
                 }
@@ -249,8 +458,26 @@
                 }
                 break;
 
+            case "control":
+                handleControlEvent(data);
+                break;
+
+            case "dropped":
+                // Events that left the server's replay buffer before this
+                // page received them.
+                droppedEvents += data.dropped || 0;
+                els.streamNotice.textContent = formatNumber(droppedEvents) +
+                    " earlier events are no longer available; some iterations may be missing.";
+                els.streamNotice.classList.remove("hidden");
+                break;
+
             case "complete":
                 els.spinner.classList.add("hidden");
+                setControlsEnabled(false, false, false);
+                if (data.cost) {
+                    totalCost = data.cost;
+                    updateSummary();
+                }
                 els.completionBanner.classList.remove("hidden");
                 if (data.error) {
                     els.completionBanner.classList.add("failure");
@@ -266,9 +493,197 @@
         }
     }
 
+    // Operator controls: pause, resume and stop take effect between
+    // iterations; steering messages are added before the next LLM call.
+    function setControlsEnabled(pause, resume, stop) {
+        els.pauseButton.disabled = !pause;
+        els.resumeButton.disabled = !resume;
+        els.stopButton.disabled = !stop;
+        els.steerButton.disabled = !stop;
+    }
+
+    function applyControlState(state) {
+        if (state.stopped) {
+            els.controlStatus.textContent = "Stopping after this iteration\u2026";
+            setControlsEnabled(false, false, false);
+        } else if (state.paused) {
+            els.controlStatus.textContent = "Pausing after this iteration\u2026";
+            setControlsEnabled(false, true, true);
+        } else {
+            els.controlStatus.textContent = state.pending_steer > 0 ? "Message queued" : "";
+            setControlsEnabled(true, false, true);
+        }
+    }
+
+    function handleControlEvent(data) {
+        switch (data.control) {
+            case "paused":
+                els.controlStatus.textContent = "Paused";
+                setControlsEnabled(false, true, true);
+                break;
+            case "resumed":
+                els.controlStatus.textContent = "";
+                setControlsEnabled(true, false, true);
+                break;
+            case "stopped":
+                els.controlStatus.textContent = "Stopped";
+                setControlsEnabled(false, false, false);
+                break;
+            case "steered":
+                els.controlStatus.textContent = "";
+                var note = document.createElement("div");
+                note.className = "operator-note";
+                note.textContent = "Operator: " + (data.message || "");
+                if (els.iterations.firstChild) {
+                    els.iterations.insertBefore(note, els.iterations.firstChild);
+                } else {
+                    els.iterations.appendChild(note);
+                }
+                break;
+        }
+    }
+
+    function controlURL(action) {
+        var run = currentRun();
+        return "/control/" + action + (run ? "?run=" + encodeURIComponent(run) : "");
+    }
+
+    function sendControl(action, body) {
+        return fetch(controlURL(action), {
+            method: "POST",
+            headers: { "Content-Type": "application/json" },
+            body: JSON.stringify(body || {})
+        }).then(function(resp) {
+            if (!resp.ok) {
+                return resp.text().then(function(msg) { throw new Error(msg.trim()); });
+            }
+            return resp.json();
+        }).then(applyControlState).catch(function(err) {
+            els.controlStatus.textContent = "Error: " + err.message;
+        });
+    }
+
+    els.pauseButton.addEventListener("click", function() { sendControl("pause"); });
+    els.resumeButton.addEventListener("click", function() { sendControl("resume"); });
+    els.stopButton.addEventListener("click", function() { sendControl("stop"); });
+    els.steerButton.addEventListener("click", function() {
+        var message = els.steerInput.value.trim();
+        if (!message) return;
+        sendControl("steer", { message: message }).then(function() {
+            els.steerInput.value = "";
+        });
+    });
+
+    // In parallel mode the dashboard serves several runs; ?run=<id> picks one.
+    function currentRun() {
+        return typeof location !== "undefined" ?
+            new URLSearchParams(location.search).get("run") : null;
+    }
+
+    // The server replays buffered events after last_event_id, so a
+    // reconnect picks up exactly where the stream broke off.
+    function eventsURL() {
+        var params = [];
+        var run = currentRun();
+        if (run) params.push("run=" + encodeURIComponent(run));
+        if (lastEventId) params.push("last_event_id=" + encodeURIComponent(lastEventId));
+        return "/events" + (params.length ? "?" + params.join("&") : "");
+    }
+
+    // Run history: /?history lists the runs on disk, /?history=<id> shows one
+    // of them rebuilt from its recorded events.
+    function historyParam() {
+        if (typeof location === "undefined") return null;
+        var params = new URLSearchParams(location.search);
+        return params.has("history") ? params.get("history") : null;
+    }
+
+    function fetchJSON(url) {
+        return fetch(url).then(function(resp) {
+            if (!resp.ok) {
+                return resp.text().then(function(msg) { throw new Error(msg.trim()); });
+            }
+            return resp.json();
+        });
+    }
+
+    function showHistoryMessage(msg) {
+        els.history.classList.remove("hidden");
+        els.historyMessage.classList.remove("hidden");
+        els.historyMessage.textContent = msg;
+    }
+
+    function createHistoryRow(run) {
+        var row = document.createElement("a");
+        row.className = "history-row";
+        row.href = "/?history=" + encodeURIComponent(run.run_id);
+
+        var started = document.createElement("span");
+        started.className = "history-started";
+        started.textContent = run.started_at ? new Date(run.started_at).toLocaleString() : run.run_id;
+        started.title = run.run_id;
+
+        var task = document.createElement("span");
+        task.className = "history-task";
+        task.textContent = run.task;
+        task.title = run.task;
+
+        var status = document.createElement("span");
+        status.className = "history-status status-" + run.status;
+        status.textContent = run.status.replace(/_/g, " ");
+
+        var stats = document.createElement("span");
+        stats.className = "history-stats";
+        var parts = [run.model, run.iterations + " iter", formatNumber(run.tokens) + " tokens"];
+        if (run.cost) parts.push(formatCost(run.cost));
+        parts.push(formatDuration(run.duration_ms));
+        stats.textContent = parts.join(" \u00b7 ");
+
+        row.appendChild(started);
+        row.appendChild(task);
+        row.appendChild(status);
+        row.appendChild(stats);
+        if (!run.events) {
+            row.classList.add("no-events");
+            row.title = "No transcript was recorded for this run";
+        }
+        return row;
+    }
+
+    function loadHistory() {
+        els.connectionStatus.textContent = "History";
+        els.connectionStatus.className = "status-badge history";
+        els.history.classList.remove("hidden");
+        return fetchJSON("/history").then(function(runs) {
+            if (runs.length === 0) {
+                showHistoryMessage("No runs recorded yet.");
+                return;
+            }
+            runs.forEach(function(run) {
+                els.historyList.appendChild(createHistoryRow(run));
+            });
+        }).catch(function(err) {
+            showHistoryMessage("Could not load the run history: " + err.message);
+        });
+    }
+
+    // Rebuild a past run's page from its recorded events. Controls apply to
+    // live runs only.
+    function loadRun(run) {
+        els.connectionStatus.textContent = "Run " + run;
+        els.connectionStatus.className = "status-badge history";
+        return fetchJSON("/history/events?run=" + encodeURIComponent(run)).then(function(events) {
+            events.forEach(handleData);
+            els.controls.classList.add("hidden");
+            els.spinner.classList.add("hidden");
+        }).catch(function(err) {
+            showHistoryMessage("Could not load run " + run + ": " + err.message);
+        });
+    }
+
     // SSE connection with auto-reconnect
     function connect() {
-        var source = new EventSource("/events");
+        var source = new EventSource(eventsURL());
 
         source.onmessage = handleEvent;
 
@@ -280,5 +695,12 @@
         };
     }
 
-    connect();
+    var historyRun = historyParam();
+    if (historyRun === null) {
+        connect();
+    } else if (historyRun === "") {
+        loadHistory();
+    } else {
+        loadRun(historyRun);
+    }
 })();
@@ -109,10 +109,14 @@ function buildFakeDOM() {
     elementRegistry = {};
     const ids = [
         "connection-status", "task-info", "task-description", "task-model",
-        "task-max-iter", "summary", "total-iterations", "total-tokens",
+        "task-max-iter", "summary", "total-iterations", "total-tokens", "total-cost",
         "total-duration", "total-errors", "progress-bar-container",
         "progress-bar", "progress-text", "iterations", "spinner",
         "completion-banner", "completion-title", "completion-message",
+        "controls", "pause-button", "resume-button", "stop-button",
+        "control-status", "steer-input", "steer-button",
+        "live-pane-card", "live-pane", "live-pane-meta",
+        "history", "history-message", "history-list", "stream-notice",
     ];
     for (const id of ids) {
         const el = new MockElement("DIV");
@@ -125,18 +129,22 @@ function buildFakeDOM() {
 // Extract the event handler from app.js by simulating its IIFE environment.
 // ---------------------------------------------------------------------------
 
-function loadApp() {
+function loadApp(search, responses) {
     const elements = buildFakeDOM();
 
     const mockDocument = {
-        getElementById: (id) => elementRegistry[id] || new MockElement("DIV"),
+        getElementById: (id) => elementRegistry[id] || null,
         createElement: (tag) => new MockElement(tag.toUpperCase()),
     };
 
     let capturedOnMessage = null;
+    let capturedURL = null;
+    let currentSource = null;
     class MockEventSource {
         constructor(url) {
             this.url = url;
+            capturedURL = url;
+            currentSource = this;
             this.readyState = 1;
         }
         set onmessage(fn) { capturedOnMessage = fn; }
@@ -149,13 +157,41 @@ function loadApp() {
     const path = require("node:path");
     const code = fs.readFileSync(path.join(__dirname, "app.js"), "utf-8");
 
+    // fetch records requests. It answers from responses (URL to JSON body, or
+    // an Error for a 404) and with a running control state otherwise.
+    const requests = [];
+    const mockFetch = (url, opts) => {
+        requests.push({ url, opts });
+        let body = { paused: url.includes("/pause"), stopped: false, pending_steer: 0 };
+        if (responses && url in responses) body = responses[url];
+        const failed = body instanceof Error;
+        return Promise.resolve({
+            ok: !failed,
+            json: () => Promise.resolve(body),
+            text: () => Promise.resolve(failed ? body.message + "\n" : ""),
+        });
+    };
+
     const fn = new Function(
-        "document", "EventSource", "setTimeout", "console",
+        "document", "EventSource", "setTimeout", "console", "location", "fetch",
         code
     );
-    fn(mockDocument, MockEventSource, () => {}, console);
+    const timers = [];
+    fn(mockDocument, MockEventSource, (cb) => timers.push(cb), console, { search: search || "" }, mockFetch);
+
+    // reconnect fails the current connection and runs the retry timer,
+    // returning the URL of the new connection.
+    const reconnect = () => {
+        currentSource._onerror();
+        timers.shift()();
+        return capturedURL;
+    };
+    return { elements, handleEvent: capturedOnMessage, eventsURL: capturedURL, requests, reconnect };
+}
 
-    return { elements, handleEvent: capturedOnMessage };
+// Wait for pending promise callbacks (mocked fetches) to run.
+function settle() {
+    return new Promise((resolve) => setImmediate(resolve));
 }
 
 // Helper to send an SSE-like event to the handler.
@@ -255,6 +291,28 @@ describe("Dashboard app.js", () => {
             assert.equal(container.children[0].id, "iter-1");
         });
 
+        it("shows the model that answered the iteration", () => {
+            sendEvent(handleEvent, { type: "iteration_end", iteration: 1, model: "backup-model" });
+            const meta = elements["iterations"].children[0].children[0].children[1];
+            const model = meta.children.find((el) => el.className === "iteration-model");
+            assert.ok(model, "expected a model label");
+            assert.equal(model.textContent, "backup-model");
+        });
+
+        it("links the card to its git checkpoint", () => {
+            sendEvent(handleEvent, {
+                type: "iteration_end",
+                iteration: 1,
+                checkpoint: "0123456789abcdef",
+            });
+
+            const meta = elements["iterations"].children[0].children[0].children[1];
+            const link = meta.children.find((el) => el.className === "checkpoint-link");
+            assert.ok(link, "expected a checkpoint link");
+            assert.equal(link.href, "/checkpoint?commit=0123456789abcdef");
+            assert.equal(link.textContent, "checkpoint 0123456");
+        });
+
         it("hides spinner after iteration completes", () => {
             sendEvent(handleEvent, { type: "task_info", task: "t", model: "m", max_iter: 0 });
             sendEvent(handleEvent, { type: "iteration_start", iteration: 1 });
@@ -290,6 +348,15 @@ describe("Dashboard app.js", () => {
             assert.equal(text(elements["total-errors"]), "0");
         });
 
+        it("shows the running cost against the cost budget", () => {
+            sendEvent(handleEvent, { type: "task_info", task: "t", model: "m", max_iter: 0, max_cost: 5 });
+            assert.equal(text(elements["total-cost"]), "$0.00 / $5.00");
+            sendEvent(handleEvent, { type: "iteration_end", iteration: 1, cost: 0.01234 });
+            assert.equal(text(elements["total-cost"]), "$0.0123 / $5.00");
+            sendEvent(handleEvent, { type: "complete", iteration: 1, cost: 2.5, error: "cost budget exhausted" });
+            assert.equal(text(elements["total-cost"]), "$2.50 / $5.00");
+        });
+
         it("counts errors in iteration_end events", () => {
             sendEvent(handleEvent, { type: "task_info", task: "t", model: "m", max_iter: 0 });
             sendEvent(handleEvent, {
@@ -534,6 +601,224 @@ describe("Dashboard app.js", () => {
         });
     });
 
+    describe("orchestrator_delta event", () => {
+        it("appends streamed tokens to the in-progress card", () => {
+            sendEvent(handleEvent, { type: "task_info", task: "t", model: "m", max_iter: 0 });
+            sendEvent(handleEvent, { type: "iteration_start", iteration: 1 });
+            sendEvent(handleEvent, { type: "orchestrator_delta", iteration: 1, delta: "echo " });
+            sendEvent(handleEvent, { type: "orchestrator_delta", iteration: 1, delta: "hello" });
+
+            const live = elements["iter-1-live"];
+            assert.ok(live, "live reply block should exist");
+            assert.equal(live.textContent, "echo hello");
+            assert.ok(elements["iter-1"].classList.contains("in-progress"));
+        });
+
+        it("is replaced by the full card on iteration_end", () => {
+            sendEvent(handleEvent, { type: "task_info", task: "t", model: "m", max_iter: 0 });
+            sendEvent(handleEvent, { type: "iteration_start", iteration: 1 });
+            sendEvent(handleEvent, { type: "orchestrator_delta", iteration: 1, delta: "partial" });
+            sendEvent(handleEvent, {
+                type: "iteration_end",
+                iteration: 1,
+                tokens: { prompt: 10, completion: 5, total: 15 },
+                orchestrator: "partial reply",
+            });
+
+            const container = elements["iterations"];
+            assert.equal(container.children.length, 1);
+            assert.ok(!container.children[0].classList.contains("in-progress"));
+        });
+    });
+
+    describe("event stream URL", () => {
+        it("streams /events by default", () => {
+            assert.equal(loadApp().eventsURL, "/events");
+        });
+
+        it("streams the run named in ?run=", () => {
+            assert.equal(loadApp("?run=20260102-150405-a1b2").eventsURL, "/events?run=20260102-150405-a1b2");
+        });
+    });
+
+    describe("pane_update event", () => {
+        it("shows the latest pane in the live terminal", () => {
+            sendEvent(handleEvent, { type: "pane_update", iteration: 3, pane: "$ go test\nok" });
+            sendEvent(handleEvent, { type: "pane_update", iteration: 3, pane: "$ go test\nok\nPASS" });
+            assert.ok(!elements["live-pane-card"].classList.contains("hidden"));
+            assert.equal(elements["live-pane"].innerHTML, "$ go test\nok\nPASS");
+            assert.ok(text(elements["live-pane-meta"]).startsWith("iteration 3"));
+            // Live panes do not add iteration cards.
+            assert.equal(elements["iterations"].children.length, 0);
+        });
+    });
+
+    describe("ANSI rendering", () => {
+        // Find the Agent Output <pre> of the first iteration card.
+        function agentOutputBlock() {
+            const body = elements["iterations"].children[0].children[1];
+            const section = body.children.find((c) => c.children[0] && c.children[0].textContent === "Agent Output");
+            return section.children[1];
+        }
+
+        it("renders colors and attributes as styled spans", () => {
+            sendEvent(handleEvent, {
+                type: "iteration_end",
+                iteration: 1,
+                agent_output: "-old\n+new",
+                agent_ansi: "\x1b[31m-old\x1b[0m\n\x1b[1;38;5;46m+new\x1b[22;39m done\n\n\n",
+            });
+            assert.equal(agentOutputBlock().innerHTML,
+                '<span style="color:#f14c4c">-old</span>\n' +
+                '<span style="color:rgb(0,255,0);font-weight:bold">+new</span> done');
+        });
+
+        it("supports truecolor, inverse and colon-separated parameters", () => {
+            sendEvent(handleEvent, {
+                type: "pane_update",
+                iteration: 1,
+                pane: "\x1b[38;2;1;2;3ma\x1b[7mb\x1b[0m\x1b[48:2::4:5:6mc\x1b[m",
+            });
+            assert.equal(elements["live-pane"].innerHTML,
+                '<span style="color:rgb(1,2,3)">a</span>' +
+                '<span style="color:var(--code-bg);background:rgb(1,2,3)">b</span>' +
+                '<span style="background:rgb(4,5,6)">c</span>');
+        });
+
+        it("escapes HTML and drops other escape sequences", () => {
+            sendEvent(handleEvent, {
+                type: "pane_update",
+                iteration: 1,
+                pane: "\x1b]8;;https://x.test\x1b\\<script>alert(1)</script>\x1b[2K & done",
+            });
+            assert.equal(elements["live-pane"].innerHTML, "&lt;script&gt;alert(1)&lt;/script&gt; &amp; done");
+        });
+
+        it("falls back to the cleaned output without agent_ansi", () => {
+            sendEvent(handleEvent, { type: "iteration_end", iteration: 1, agent_output: "<b>plain</b>" });
+            assert.equal(agentOutputBlock().textContent, "<b>plain</b>");
+            assert.equal(agentOutputBlock().innerHTML, "");
+        });
+    });
+
+    describe("controls", () => {
+        it("posts JSON to the control endpoint of the selected run", async () => {
+            const app = loadApp("?run=r1");
+            app.elements["pause-button"]._listeners.click[0]();
+            assert.equal(app.requests.length, 1);
+            assert.equal(app.requests[0].url, "/control/pause?run=r1");
+            assert.equal(app.requests[0].opts.method, "POST");
+            assert.equal(app.requests[0].opts.headers["Content-Type"], "application/json");
+            await settle();
+            assert.equal(app.elements["resume-button"].disabled, false);
+            assert.equal(app.elements["pause-button"].disabled, true);
+        });
+
+        it("sends the steering message and clears the box", async () => {
+            const app = loadApp();
+            app.elements["steer-input"].value = "  focus on the tests  ";
+            app.elements["steer-button"]._listeners.click[0]();
+            assert.equal(app.requests[0].url, "/control/steer");
+            assert.deepEqual(JSON.parse(app.requests[0].opts.body), { message: "focus on the tests" });
+            await settle();
+            assert.equal(app.elements["steer-input"].value, "");
+        });
+
+        it("ignores an empty steering message", () => {
+            const app = loadApp();
+            app.elements["steer-input"].value = "   ";
+            app.elements["steer-button"]._listeners.click[0]();
+            assert.equal(app.requests.length, 0);
+        });
+
+        it("shows control events from the loop", () => {
+            sendEvent(handleEvent, { type: "control", iteration: 2, control: "paused" });
+            assert.equal(text(elements["control-status"]), "Paused");
+            assert.equal(elements["resume-button"].disabled, false);
+
+            sendEvent(handleEvent, { type: "control", iteration: 2, control: "steered", message: "use sqlite" });
+            const note = elements["iterations"].children[0];
+            assert.equal(note.className, "operator-note");
+            assert.ok(text(note).includes("use sqlite"));
+
+            sendEvent(handleEvent, { type: "control", iteration: 2, control: "stopped" });
+            assert.equal(text(elements["control-status"]), "Stopped");
+            assert.equal(elements["stop-button"].disabled, true);
+        });
+    });
+
+    describe("run history", () => {
+        it("lists past runs from /history without connecting", async () => {
+            const app = loadApp("?history", {
+                "/history": [
+                    { run_id: "r2", task: "Add tests", model: "m", status: "budget_exceeded", iterations: 4,
+                      tokens: 12000, cost: 0.25, duration_ms: 90000, started_at: "2026-01-02T15:04:05Z", events: true },
+                    { run_id: "r1", task: "Fix bug", model: "m", status: "complete", iterations: 1,
+                      tokens: 10, duration_ms: 500, started_at: "2026-01-01T15:04:05Z" },
+                ],
+            });
+            assert.equal(app.eventsURL, null);
+            await settle();
+            const rows = app.elements["history-list"].children;
+            assert.equal(rows.length, 2);
+            assert.equal(rows[0].href, "/?history=r2");
+            assert.equal(rows[0].children[1].textContent, "Add tests");
+            assert.equal(rows[0].children[2].textContent, "budget exceeded");
+            assert.ok(rows[0].children[3].textContent.includes("$0.2500"));
+            assert.ok(rows[1].classList.contains("no-events"));
+        });
+
+        it("says so when there are no runs", async () => {
+            const app = loadApp("?history", { "/history": [] });
+            await settle();
+            assert.equal(text(app.elements["history-message"]), "No runs recorded yet.");
+        });
+
+        it("rebuilds a past run's iteration cards from its events", async () => {
+            const app = loadApp("?history=r2", {
+                "/history/events?run=r2": [
+                    { type: "task_info", task: "Add tests", model: "m", max_iter: 5 },
+                    { type: "iteration_start", iteration: 1 },
+                    { type: "iteration_end", iteration: 1, orchestrator: "go test", agent_output: "ok" },
+                    { type: "complete", iteration: 1 },
+                ],
+            });
+            await settle();
+            assert.equal(text(app.elements["task-description"]), "Add tests");
+            assert.equal(app.elements["iterations"].children.length, 1);
+            assert.equal(app.elements["iterations"].children[0].id, "iter-1");
+            assert.ok(app.elements["controls"].classList.contains("hidden"));
+        });
+
+        it("reports a run without a transcript", async () => {
+            const app = loadApp("?history=r1", {
+                "/history/events?run=r1": new Error("no transcript was recorded for run r1"),
+            });
+            await settle();
+            assert.equal(text(app.elements["history-message"]), "Could not load run r1: no transcript was recorded for run r1");
+        });
+    });
+
+    describe("stream resume", () => {
+        it("reconnects after the last event ID it received", () => {
+            const app = loadApp("?run=r1");
+            app.handleEvent({ data: JSON.stringify({ type: "iteration_start", iteration: 1 }), lastEventId: "7" });
+            assert.equal(app.reconnect(), "/events?run=r1&last_event_id=7");
+            assert.equal(text(app.elements["connection-status"]), "Disconnected");
+        });
+
+        it("reconnects from scratch before any event arrived", () => {
+            assert.equal(loadApp().reconnect(), "/events");
+        });
+
+        it("warns about events that are no longer available", () => {
+            sendEvent(handleEvent, { type: "dropped", dropped: 12 });
+            sendEvent(handleEvent, { type: "dropped", dropped: 3 });
+            assert.ok(!elements["stream-notice"].classList.contains("hidden"));
+            assert.ok(text(elements["stream-notice"]).startsWith("15 earlier events"));
+        });
+    });
+
     describe("malformed events", () => {
         it("ignores invalid JSON without throwing", () => {
             handleEvent({ data: "not valid json{{{" });
@@ -0,0 +1,144 @@
+// Package git wraps the git CLI to give autonomous runs isolated worktrees and
+// per-iteration snapshots of their working directory.
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Fallback identity for commits made in repositories without a configured user.
+const (
+	fallbackName  = "agent-orchestrator"
+	fallbackEmail = "agent-orchestrator@localhost"
+)
+
+// Worktree is a git worktree created on a fresh branch for one run, so the
+// agent's edits never touch the main checkout or another run's files.
+type Worktree struct {
+	Repo   string `json:"repo"`   // top-level directory of the main checkout
+	Path   string `json:"path"`   // worktree directory the agent works in
+	Branch string `json:"branch"` // branch checked out in the worktree
+	Base   string `json:"base"`   // commit the branch was created from
+}
+
+// run runs git in dir and returns its stdout without the trailing newline.
+func run(dir string, args ...string) (string, error) {
+	return runEnv(dir, nil, args...)
+}
+
+// runEnv is run with extra environment variables.
+func runEnv(dir string, env []string, args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	if env != nil {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimRight(string(out), "\n"), nil
+}
+
+// CurrentBranch returns the short name of the branch checked out in the
+// repository containing dir. It fails on a detached HEAD.
+func CurrentBranch(dir string) (string, error) {
+	branch, err := run(dir, "symbolic-ref", "--quiet", "--short", "HEAD")
+	if err != nil {
+		return "", fmt.Errorf("CurrentBranch: %w", err)
+	}
+	return branch, nil
+}
+
+// CreateWorktree adds a worktree at path on a new branch started from the
+// current HEAD of the repository containing dir.
+func CreateWorktree(dir, path, branch string) (*Worktree, error) {
+	repo, err := run(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, fmt.Errorf("CreateWorktree: %s is not in a git repository: %w", dir, err)
+	}
+	base, err := run(repo, "rev-parse", "HEAD")
+	if err != nil {
+		return nil, fmt.Errorf("CreateWorktree: repository has no commits: %w", err)
+	}
+	if _, err := run(repo, "worktree", "add", "-b", branch, path, base); err != nil {
+		return nil, fmt.Errorf("CreateWorktree: %w", err)
+	}
+	return &Worktree{Repo: repo, Path: path, Branch: branch, Base: base}, nil
+}
+
+// DiffStat summarizes everything changed in the worktree since Base:
+// commits on the branch, uncommitted edits and untracked files.
+func (w *Worktree) DiffStat() (string, error) {
+	stat, err := run(w.Path, "diff", "--stat", w.Base)
+	if err != nil {
+		return "", fmt.Errorf("Worktree.DiffStat: %w", err)
+	}
+	untracked, err := run(w.Path, "ls-files", "--others", "--exclude-standard")
+	if err != nil {
+		return "", fmt.Errorf("Worktree.DiffStat: %w", err)
+	}
+	if untracked != "" {
+		files := strings.Split(untracked, "\n")
+		if stat != "" {
+			stat += "\n"
+		}
+		stat += fmt.Sprintf(" %d untracked file(s): %s", len(files), strings.Join(files, ", "))
+	}
+	if stat == "" {
+		return "no changes", nil
+	}
+	return stat, nil
+}
+
+// CommitAll commits every change in the worktree, including untracked files,
+// to its branch. It reports whether there was anything to commit.
+func (w *Worktree) CommitAll(message string) (bool, error) {
+	status, err := run(w.Path, "status", "--porcelain")
+	if err != nil {
+		return false, fmt.Errorf("Worktree.CommitAll: %w", err)
+	}
+	if status == "" {
+		return false, nil
+	}
+	if _, err := run(w.Path, "add", "-A"); err != nil {
+		return false, fmt.Errorf("Worktree.CommitAll: %w", err)
+	}
+	if _, err := run(w.Path, append(identityArgs(w.Path), "commit", "-q", "-m", message)...); err != nil {
+		return false, fmt.Errorf("Worktree.CommitAll: %w", err)
+	}
+	return true, nil
+}
+
+// Remove deletes the worktree directory. The branch is kept if it has commits
+// beyond Base, so committed work survives; otherwise it is deleted too.
+func (w *Worktree) Remove() error {
+	if _, err := run(w.Repo, "worktree", "remove", "--force", w.Path); err != nil {
+		return fmt.Errorf("Worktree.Remove: %w", err)
+	}
+	ahead, err := run(w.Repo, "rev-list", "--count", w.Base+".."+w.Branch)
+	if err != nil {
+		return fmt.Errorf("Worktree.Remove: %w", err)
+	}
+	if n, _ := strconv.Atoi(ahead); n == 0 {
+		if _, err := run(w.Repo, "branch", "-D", w.Branch); err != nil {
+			return fmt.Errorf("Worktree.Remove: %w", err)
+		}
+	}
+	return nil
+}
+
+// identityArgs returns -c flags supplying a committer identity when dir's
+// repository has none configured, so commits work on fresh machines.
+func identityArgs(dir string) []string {
+	if email, err := run(dir, "config", "user.email"); err == nil && email != "" {
+		return nil
+	}
+	return []string{"-c", "user.name=" + fallbackName, "-c", "user.email=" + fallbackEmail}
+}
@@ -0,0 +1,112 @@
+package git
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// initRepo creates a repository with one commit and returns its directory.
+func initRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	dir := t.TempDir()
+	if _, err := run(dir, "init", "-q"); err != nil {
+		t.Fatalf("init: %v", err)
+	}
+	os.WriteFile(filepath.Join(dir, "README"), []byte("hello\n"), 0o644)
+	if _, err := run(dir, "add", "README"); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	if _, err := run(dir, append(identityArgs(dir), "commit", "-q", "-m", "init")...); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	return dir
+}
+
+// A worktree starts clean on its own branch and leaves the main checkout untouched.
+func TestCreateWorktree(t *testing.T) {
+	repo := initRepo(t)
+	path := filepath.Join(t.TempDir(), "wt")
+	w, err := CreateWorktree(repo, path, "agent/run-1")
+	if err != nil {
+		t.Fatalf("CreateWorktree: %v", err)
+	}
+	if branch, _ := run(path, "rev-parse", "--abbrev-ref", "HEAD"); branch != "agent/run-1" {
+		t.Fatalf("worktree branch = %q", branch)
+	}
+	if stat, err := w.DiffStat(); err != nil || stat != "no changes" {
+		t.Fatalf("DiffStat = %q, %v", stat, err)
+	}
+
+	os.WriteFile(filepath.Join(path, "README"), []byte("changed\n"), 0o644)
+	os.WriteFile(filepath.Join(path, "new.go"), []byte("package x\n"), 0o644)
+	stat, err := w.DiffStat()
+	if err != nil || !strings.Contains(stat, "README") || !strings.Contains(stat, "1 untracked file(s): new.go") {
+		t.Fatalf("DiffStat = %q, %v", stat, err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(repo, "README")); string(data) != "hello\n" {
+		t.Fatal("main checkout was modified")
+	}
+
+	if _, err := CreateWorktree(t.TempDir(), filepath.Join(t.TempDir(), "x"), "b"); err == nil {
+		t.Fatal("expected an error outside a repository")
+	}
+}
+
+// CurrentBranch names the checked-out branch and fails on a detached HEAD.
+func TestCurrentBranch(t *testing.T) {
+	dir := initRepo(t)
+	if _, err := run(dir, "checkout", "-q", "-b", "feature/memory"); err != nil {
+		t.Fatalf("checkout: %v", err)
+	}
+	if branch, err := CurrentBranch(dir); err != nil || branch != "feature/memory" {
+		t.Fatalf("CurrentBranch = %q, %v", branch, err)
+	}
+	if _, err := run(dir, "checkout", "-q", "--detach"); err != nil {
+		t.Fatalf("detach: %v", err)
+	}
+	if branch, err := CurrentBranch(dir); err == nil {
+		t.Fatalf("expected error on detached HEAD, got %q", branch)
+	}
+}
+
+// Remove keeps a branch with committed work and deletes an unused one.
+func TestWorktree_CommitAllAndRemove(t *testing.T) {
+	repo := initRepo(t)
+	used, err := CreateWorktree(repo, filepath.Join(t.TempDir(), "used"), "agent/used")
+	if err != nil {
+		t.Fatalf("CreateWorktree: %v", err)
+	}
+	os.WriteFile(filepath.Join(used.Path, "new.go"), []byte("package x\n"), 0o644)
+	if ok, err := used.CommitAll("agent work"); !ok || err != nil {
+		t.Fatalf("CommitAll = %v, %v", ok, err)
+	}
+	if ok, err := used.CommitAll("nothing"); ok || err != nil {
+		t.Fatalf("second CommitAll = %v, %v", ok, err)
+	}
+	if stat, _ := used.DiffStat(); !strings.Contains(stat, "new.go") {
+		t.Fatalf("committed changes missing from DiffStat: %q", stat)
+	}
+
+	unused, err := CreateWorktree(repo, filepath.Join(t.TempDir(), "unused"), "agent/unused")
+	if err != nil {
+		t.Fatalf("CreateWorktree: %v", err)
+	}
+	for _, w := range []*Worktree{used, unused} {
+		if err := w.Remove(); err != nil {
+			t.Fatalf("Remove: %v", err)
+		}
+		if _, err := os.Stat(w.Path); !os.IsNotExist(err) {
+			t.Fatalf("%s still exists", w.Path)
+		}
+	}
+	branches, _ := run(repo, "branch", "--list", "agent/*")
+	if !strings.Contains(branches, "agent/used") || strings.Contains(branches, "agent/unused") {
+		t.Fatalf("unexpected branches after Remove: %q", branches)
+	}
+}
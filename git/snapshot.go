@@ -0,0 +1,242 @@
+package git
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SnapshotRefPrefix is the namespace of checkpoint refs. Refs outside
+// refs/heads and refs/tags are not branches, so snapshots never show up in
+// `git branch` and never move the user's branch.
+const SnapshotRefPrefix = "refs/checkpoints/"
+
+// Snapshot is a commit recording the working directory after one iteration.
+type Snapshot struct {
+	RunID     string
+	Iteration int
+	Ref       string // refs/checkpoints/<run-id>/<iteration>, or restore-<n> for a restore backup
+	Commit    string
+	Time      time.Time
+	Subject   string
+}
+
+// SnapshotRef returns the ref holding a run's snapshot for iteration i.
+func SnapshotRef(runID string, i int) string {
+	return fmt.Sprintf("%s%s/%d", SnapshotRefPrefix, runID, i)
+}
+
+// RestoreRef returns the ref holding the state saved before a run's nth
+// restore. Restore backups are not iterations, so ListSnapshots skips them.
+func RestoreRef(runID string, n int) string {
+	return fmt.Sprintf("%s%s/restore-%d", SnapshotRefPrefix, runID, n)
+}
+
+// runIndex runs git in dir with a private index file, so staging the
+// working directory for a snapshot never touches the user's index.
+func runIndex(dir, index string, args ...string) (string, error) {
+	return runEnv(dir, []string{"GIT_INDEX_FILE=" + index}, args...)
+}
+
+// writeTree stores the whole working tree of the repository containing dir
+// (tracked and untracked files, honouring .gitignore) as a tree object and
+// returns its hash. The private index starts as a copy of the user's, so only
+// files changed since the last `git add` need hashing.
+func writeTree(dir string) (string, error) {
+	top, err := run(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", err
+	}
+	tmp, err := os.MkdirTemp("", "orchestrator-index-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmp)
+	index := filepath.Join(tmp, "index")
+	if userIndex, err := run(top, "rev-parse", "--path-format=absolute", "--git-path", "index"); err == nil {
+		if data, err := os.ReadFile(userIndex); err == nil {
+			os.WriteFile(index, data, 0o600)
+		}
+	}
+	if _, err := runIndex(top, index, "add", "-A"); err != nil {
+		return "", err
+	}
+	return runIndex(top, index, "write-tree")
+}
+
+// SaveSnapshot commits the working directory of the repository at dir to
+// SnapshotRef(runID, iteration). The commit's parent is the run's previous
+// snapshot (or HEAD for the first), so `git show` of a snapshot displays
+// what that iteration changed. HEAD, the index and branches are untouched.
+func SaveSnapshot(dir, runID string, iteration int, message string) (Snapshot, error) {
+	prev, err := ListSnapshots(dir, runID)
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("SaveSnapshot: %w", err)
+	}
+	parent := ""
+	for _, s := range prev {
+		if s.Iteration < iteration {
+			parent = s.Commit
+		}
+	}
+	snap, err := commitSnapshot(dir, SnapshotRef(runID, iteration), parent, message)
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("SaveSnapshot: %w", err)
+	}
+	snap.RunID, snap.Iteration = runID, iteration
+	return snap, nil
+}
+
+// commitSnapshot commits the working directory of the repository at dir
+// with parent (HEAD if empty) and points ref at the commit.
+func commitSnapshot(dir, ref, parent, message string) (Snapshot, error) {
+	tree, err := writeTree(dir)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	args := append(identityArgs(dir), "commit-tree", tree, "-m", message)
+	if parent == "" {
+		parent, _ = run(dir, "rev-parse", "--verify", "-q", "HEAD")
+	}
+	if parent != "" {
+		args = append(args, "-p", parent)
+	}
+	commit, err := run(dir, args...)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	if _, err := run(dir, "update-ref", ref, commit); err != nil {
+		return Snapshot{}, err
+	}
+	return Snapshot{Ref: ref, Commit: commit, Time: time.Now(), Subject: message}, nil
+}
+
+// ListSnapshots returns the snapshots of runID (every run if empty) in the
+// repository at dir, ordered by run and iteration.
+func ListSnapshots(dir, runID string) ([]Snapshot, error) {
+	pattern := SnapshotRefPrefix
+	if runID != "" {
+		pattern += runID + "/"
+	}
+	out, err := run(dir, "for-each-ref", "--format=%(refname)%00%(objectname)%00%(creatordate:unix)%00%(subject)", pattern)
+	if err != nil {
+		return nil, fmt.Errorf("ListSnapshots: %w", err)
+	}
+	var snaps []Snapshot
+	for _, line := range strings.Split(out, "\n") {
+		f := strings.Split(line, "\x00")
+		if len(f) != 4 {
+			continue
+		}
+		name := strings.TrimPrefix(f[0], SnapshotRefPrefix)
+		slash := strings.LastIndex(name, "/")
+		if slash < 0 {
+			continue
+		}
+		i, err := strconv.Atoi(name[slash+1:])
+		if err != nil {
+			continue
+		}
+		secs, _ := strconv.ParseInt(f[2], 10, 64)
+		snaps = append(snaps, Snapshot{
+			RunID:     name[:slash],
+			Iteration: i,
+			Ref:       f[0],
+			Commit:    f[1],
+			Time:      time.Unix(secs, 0),
+			Subject:   f[3],
+		})
+	}
+	sort.SliceStable(snaps, func(a, b int) bool {
+		if snaps[a].RunID != snaps[b].RunID {
+			return snaps[a].RunID < snaps[b].RunID
+		}
+		return snaps[a].Iteration < snaps[b].Iteration
+	})
+	return snaps, nil
+}
+
+// DiffSnapshots returns the patch from commit-ish from to to. An empty to
+// compares against the current working directory, untracked files included.
+func DiffSnapshots(dir, from, to string) (string, error) {
+	if to == "" {
+		tree, err := writeTree(dir)
+		if err != nil {
+			return "", fmt.Errorf("DiffSnapshots: %w", err)
+		}
+		to = tree
+	}
+	out, err := run(dir, "diff", "--stat", "--patch", from, to)
+	if err != nil {
+		return "", fmt.Errorf("DiffSnapshots: %w", err)
+	}
+	return out, nil
+}
+
+// ShowSnapshot returns the changes recorded by one snapshot commit.
+func ShowSnapshot(dir, commit string) (string, error) {
+	out, err := run(dir, "show", "--stat", "--patch", "--format=commit %H%n%s%n", commit)
+	if err != nil {
+		return "", fmt.Errorf("ShowSnapshot: %w", err)
+	}
+	return out, nil
+}
+
+// RestoreSnapshot makes the working directory at dir match commit-ish
+// target: files are rewritten and files the snapshot does not contain are
+// deleted (ignored files are left alone). HEAD, branches and the user's
+// index are not touched. The state before the restore is first saved to the
+// next free RestoreRef of runID, returned so the restore can be undone.
+func RestoreSnapshot(dir, runID, target string) (Snapshot, error) {
+	targetTree, err := run(dir, "rev-parse", "--verify", target+"^{tree}")
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("RestoreSnapshot: %w", err)
+	}
+	n := 1
+	for {
+		if _, err := run(dir, "rev-parse", "--verify", "-q", RestoreRef(runID, n)); err != nil {
+			break
+		}
+		n++
+	}
+	backup, err := commitSnapshot(dir, RestoreRef(runID, n), "", "State before restoring "+target)
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("RestoreSnapshot: %w", err)
+	}
+	backup.RunID = runID
+	// Files added since the target snapshot are removed first.
+	added, err := run(dir, "diff", "--name-only", "--no-renames", "--diff-filter=A", targetTree, backup.Commit)
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("RestoreSnapshot: %w", err)
+	}
+	top, err := run(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("RestoreSnapshot: %w", err)
+	}
+	for _, name := range strings.Split(added, "\n") {
+		if name == "" {
+			continue
+		}
+		if err := os.Remove(filepath.Join(top, name)); err != nil && !os.IsNotExist(err) {
+			return Snapshot{}, fmt.Errorf("RestoreSnapshot: %w", err)
+		}
+	}
+
+	tmp, err := os.MkdirTemp("", "orchestrator-index-")
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("RestoreSnapshot: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+	index := filepath.Join(tmp, "index")
+	if _, err := runIndex(top, index, "read-tree", targetTree); err != nil {
+		return Snapshot{}, fmt.Errorf("RestoreSnapshot: %w", err)
+	}
+	if _, err := runIndex(top, index, "checkout-index", "-a", "-f"); err != nil {
+		return Snapshot{}, fmt.Errorf("RestoreSnapshot: %w", err)
+	}
+	return backup, nil
+}
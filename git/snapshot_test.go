@@ -0,0 +1,103 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Snapshots record each iteration on hidden refs without touching HEAD or the index.
+func TestSaveSnapshot(t *testing.T) {
+	repo := initRepo(t)
+	head, _ := run(repo, "rev-parse", "HEAD")
+	os.WriteFile(filepath.Join(repo, "a.txt"), []byte("one\n"), 0o644)
+	s1, err := SaveSnapshot(repo, "run-1", 1, "Iteration 1")
+	if err != nil {
+		t.Fatalf("SaveSnapshot: %v", err)
+	}
+	os.WriteFile(filepath.Join(repo, "a.txt"), []byte("two\n"), 0o644)
+	s2, err := SaveSnapshot(repo, "run-1", 2, "Iteration 2")
+	if err != nil {
+		t.Fatalf("SaveSnapshot: %v", err)
+	}
+
+	if now, _ := run(repo, "rev-parse", "HEAD"); now != head {
+		t.Fatal("HEAD moved")
+	}
+	if status, _ := run(repo, "status", "--porcelain"); status != "?? a.txt" {
+		t.Fatalf("index or worktree changed: %q", status)
+	}
+	if branches, _ := run(repo, "branch", "--list"); strings.Count(branches, "\n") != 0 {
+		t.Fatalf("snapshots created branches: %q", branches)
+	}
+	if parent, _ := run(repo, "rev-parse", s2.Commit+"^"); parent != s1.Commit {
+		t.Fatal("a snapshot's parent should be the previous iteration")
+	}
+	if show, err := ShowSnapshot(repo, s2.Commit); err != nil || !strings.Contains(show, "-one") || !strings.Contains(show, "+two") {
+		t.Fatalf("ShowSnapshot = %q, %v", show, err)
+	}
+
+	snaps, err := ListSnapshots(repo, "run-1")
+	if err != nil || len(snaps) != 2 || snaps[1].Iteration != 2 || snaps[1].Commit != s2.Commit || snaps[0].Subject != "Iteration 1" {
+		t.Fatalf("ListSnapshots = %+v, %v", snaps, err)
+	}
+	if other, _ := ListSnapshots(repo, "run-2"); len(other) != 0 {
+		t.Fatalf("snapshots leaked across runs: %+v", other)
+	}
+}
+
+// Restoring rewrites changed files, deletes new ones and saves a backup first.
+func TestRestoreSnapshot(t *testing.T) {
+	repo := initRepo(t)
+	os.WriteFile(filepath.Join(repo, "a.txt"), []byte("good\n"), 0o644)
+	os.WriteFile(filepath.Join(repo, ".gitignore"), []byte("*.log\n"), 0o644)
+	good, err := SaveSnapshot(repo, "run-1", 1, "Iteration 1")
+	if err != nil {
+		t.Fatalf("SaveSnapshot: %v", err)
+	}
+
+	os.WriteFile(filepath.Join(repo, "a.txt"), []byte("wrecked\n"), 0o644)
+	os.Remove(filepath.Join(repo, "README"))
+	os.WriteFile(filepath.Join(repo, "junk.txt"), []byte("junk\n"), 0o644)
+	os.WriteFile(filepath.Join(repo, "build.log"), []byte("ignored\n"), 0o644)
+	if diff, err := DiffSnapshots(repo, good.Commit, ""); err != nil || !strings.Contains(diff, "+wrecked") || !strings.Contains(diff, "junk.txt") {
+		t.Fatalf("DiffSnapshots = %q, %v", diff, err)
+	}
+
+	backup, err := RestoreSnapshot(repo, "run-1", good.Commit)
+	if err != nil {
+		t.Fatalf("RestoreSnapshot: %v", err)
+	}
+	read := func(name string) string {
+		data, _ := os.ReadFile(filepath.Join(repo, name))
+		return string(data)
+	}
+	if read("a.txt") != "good\n" || read("README") != "hello\n" {
+		t.Fatal("files were not restored")
+	}
+	if _, err := os.Stat(filepath.Join(repo, "junk.txt")); !os.IsNotExist(err) {
+		t.Fatal("file added after the snapshot should be deleted")
+	}
+	if read("build.log") != "ignored\n" {
+		t.Fatal("ignored files should be left alone")
+	}
+	if diff, _ := DiffSnapshots(repo, good.Commit, ""); diff != "" {
+		t.Fatalf("working tree differs from the snapshot after restore:\n%s", diff)
+	}
+	if show, _ := run(repo, "show", backup.Commit+":a.txt"); show != "wrecked" {
+		t.Fatalf("backup should hold the pre-restore state, got %q", show)
+	}
+
+	// A second restore keeps the first backup and never touches iteration 0.
+	again, err := RestoreSnapshot(repo, "run-1", backup.Ref)
+	if err != nil {
+		t.Fatalf("RestoreSnapshot: %v", err)
+	}
+	if backup.Ref != RestoreRef("run-1", 1) || again.Ref != RestoreRef("run-1", 2) || read("a.txt") != "wrecked\n" {
+		t.Fatalf("unexpected backups %s and %s", backup.Ref, again.Ref)
+	}
+	if snaps, _ := ListSnapshots(repo, "run-1"); len(snaps) != 1 || snaps[0].Iteration != 1 {
+		t.Fatalf("restore backups should not be listed as iterations: %+v", snaps)
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/dlee6018/agent-orchestrator/dashboard"
+	"github.com/dlee6018/agent-orchestrator/git"
+	"github.com/dlee6018/agent-orchestrator/orchestrator"
+)
+
+// gitCheckpointsUsage documents the git-checkpoints subcommand.
+const gitCheckpointsUsage = `usage:
+  go-orchestrator git-checkpoints list [run]
+  go-orchestrator git-checkpoints diff <run> <from> [to]
+  go-orchestrator git-checkpoints restore <run> <iteration|commit>
+
+<from>, <to> and the restore target are iteration numbers of the run,
+restore-<n> for the state saved before its nth restore, or git commits; diff
+without <to> compares against the current working directory.`
+
+// gitCheckpointsMain implements `git-checkpoints list|diff|restore`, which
+// inspect and roll back the per-iteration snapshots written with
+// GIT_CHECKPOINTS=true. The repository is the run's working directory when
+// the run has a checkpoint under RUNS_DIR, otherwise the current directory.
+func gitCheckpointsMain(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, gitCheckpointsUsage)
+		os.Exit(2)
+	}
+	cmd, args := args[0], args[1:]
+	run := ""
+	if len(args) > 0 {
+		run = args[0]
+	}
+	dir, runID := snapshotRepo(run)
+
+	switch {
+	case cmd == "list" && len(args) <= 1:
+		snaps, err := git.ListSnapshots(dir, runID)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if len(snaps) == 0 {
+			fmt.Println("No git checkpoints found.")
+			return
+		}
+		for _, s := range snaps {
+			fmt.Printf("%-24s %4d  %.12s  %s  %s\n", s.RunID, s.Iteration, s.Commit, s.Time.Format("2006-01-02 15:04:05"), s.Subject)
+		}
+
+	case cmd == "diff" && (len(args) == 2 || len(args) == 3):
+		to := ""
+		if len(args) == 3 {
+			to = snapshotRev(runID, args[2])
+		}
+		diff, err := git.DiffSnapshots(dir, snapshotRev(runID, args[1]), to)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Println(diff)
+
+	case cmd == "restore" && len(args) == 2:
+		target := snapshotRev(runID, args[1])
+		backup, err := git.RestoreSnapshot(dir, runID, target)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Printf("Restored %s to %s.\n", dir, args[1])
+		fmt.Printf("The previous state was saved as %s (%.12s); restore it with:\n", backup.Ref, backup.Commit)
+		fmt.Printf("  go-orchestrator git-checkpoints restore %s %s\n", runID, strings.TrimPrefix(backup.Ref, git.SnapshotRefPrefix+runID+"/"))
+
+	default:
+		fmt.Fprintln(os.Stderr, gitCheckpointsUsage)
+		os.Exit(2)
+	}
+}
+
+// snapshotRepo resolves a run reference to the repository holding its
+// snapshots and the run ID. Runs without a checkpoint are looked up in the
+// current directory; refs are shared by all worktrees of a repository, so a
+// removed worktree falls back to its main checkout.
+func snapshotRepo(ref string) (dir, runID string) {
+	if ref != "" {
+		if runDir, err := orchestrator.FindRunDir(runsDir(), ref); err == nil {
+			if cp, err := orchestrator.LoadCheckpoint(runDir); err == nil {
+				if _, err := os.Stat(cp.WorkDir); err != nil && cp.Worktree != nil {
+					return cp.Worktree.Repo, cp.RunID
+				}
+				return cp.WorkDir, cp.RunID
+			}
+		}
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to resolve working directory: %v\n", err)
+		os.Exit(1)
+	}
+	return wd, ref
+}
+
+// snapshotRev maps an iteration number or restore-<n> to the run's snapshot
+// ref; anything else is passed to git as a revision.
+func snapshotRev(runID, rev string) string {
+	if n, err := strconv.Atoi(rev); err == nil && n >= 0 {
+		return git.SnapshotRef(runID, n)
+	}
+	if after, ok := strings.CutPrefix(rev, "restore-"); ok {
+		if n, err := strconv.Atoi(after); err == nil && n > 0 {
+			return git.RestoreRef(runID, n)
+		}
+	}
+	return rev
+}
+
+// showGitCheckpoints serves git checkpoints to the dashboard's iteration
+// cards; dirOf maps the run in the dashboard URL to its working directory.
+func showGitCheckpoints(dirOf func(run string) string) {
+	dashboard.CheckpointDiff = func(run, commit string) (string, error) {
+		dir := dirOf(run)
+		if dir == "" {
+			return "", fmt.Errorf("unknown run %q", run)
+		}
+		return git.ShowSnapshot(dir, commit)
+	}
+}
@@ -2,10 +2,15 @@ package helpers
 
 import (
 	"bufio"
+	"bytes"
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 // LoadEnvFile reads a .env file and sets any KEY=VALUE pairs as environment
@@ -71,6 +76,49 @@ func EnvBool(key string, fallback bool) bool {
 	}
 }
 
+// EnvInt parses a non-negative integer env var, returning fallback if unset or invalid.
+func EnvInt(key string, fallback int) int {
+	v := strings.TrimSpace(os.Getenv(key))
+	if v == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		fmt.Fprintf(os.Stderr, "warning: invalid non-negative integer %q for %s, using default %d\n", v, key, fallback)
+		return fallback
+	}
+	return n
+}
+
+// EnvFloat parses a non-negative number env var, returning fallback if unset or invalid.
+func EnvFloat(key string, fallback float64) float64 {
+	v := strings.TrimSpace(os.Getenv(key))
+	if v == "" {
+		return fallback
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || f < 0 {
+		fmt.Fprintf(os.Stderr, "warning: invalid non-negative number %q for %s, using default %g\n", v, key, fallback)
+		return fallback
+	}
+	return f
+}
+
+// EnvDuration parses a non-negative Go duration env var such as "1.5s",
+// returning fallback if unset or invalid.
+func EnvDuration(key string, fallback time.Duration) time.Duration {
+	v := strings.TrimSpace(os.Getenv(key))
+	if v == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		fmt.Fprintf(os.Stderr, "warning: invalid duration %q for %s, using default %s\n", v, key, fallback)
+		return fallback
+	}
+	return d
+}
+
 // ResolveAgentConfig maps a DEFAULT_MODEL value to the CLI command and display name.
 // Models starting with "gpt" (case-insensitive) resolve to Codex; all others default to Claude Code.
 func ResolveAgentConfig(defaultModel string) (command, displayName string) {
@@ -92,3 +140,50 @@ func ValidateSessionName(name string) error {
 	}
 	return nil
 }
+
+// PrefixWriter prefixes every line written through it, e.g. with a run ID, so
+// the logs of concurrent runs sharing a terminal stay readable. Partial lines
+// are buffered until their newline and each line reaches the underlying
+// writer in a single Write, so lines from different writers never interleave.
+type PrefixWriter struct {
+	w      io.Writer
+	prefix string
+	mu     sync.Mutex
+	buf    []byte
+}
+
+// NewPrefixWriter returns a PrefixWriter writing to w.
+func NewPrefixWriter(w io.Writer, prefix string) *PrefixWriter {
+	return &PrefixWriter{w: w, prefix: prefix}
+}
+
+// Write implements io.Writer.
+func (p *PrefixWriter) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.buf = append(p.buf, b...)
+	for {
+		i := bytes.IndexByte(p.buf, '\n')
+		if i < 0 {
+			return len(b), nil
+		}
+		line := append([]byte(p.prefix), p.buf[:i+1]...)
+		p.buf = p.buf[i+1:]
+		if _, err := p.w.Write(line); err != nil {
+			return len(b), err
+		}
+	}
+}
+
+// Flush writes any buffered partial line, terminated with a newline.
+func (p *PrefixWriter) Flush() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.buf) == 0 {
+		return nil
+	}
+	line := append(append([]byte(p.prefix), p.buf...), '\n')
+	p.buf = nil
+	_, err := p.w.Write(line)
+	return err
+}
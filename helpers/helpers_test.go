@@ -1,9 +1,12 @@
 package helpers
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 // LoadEnvFile returns nil for a missing file.
@@ -167,6 +170,69 @@ func TestEnvBool_Unset(t *testing.T) {
 	}
 }
 
+// EnvInt parses non-negative integers and falls back for unset or invalid values.
+func TestEnvInt(t *testing.T) {
+	tests := []struct {
+		value string
+		want  int
+	}{
+		{"42", 42},
+		{" 0 ", 0},
+		{"", 7},
+		{"-3", 7},
+		{"abc", 7},
+	}
+	for _, tt := range tests {
+		os.Setenv("TESTENV_INT", tt.value)
+		if got := EnvInt("TESTENV_INT", 7); got != tt.want {
+			t.Errorf("EnvInt(%q, 7) = %d, want %d", tt.value, got, tt.want)
+		}
+	}
+	os.Unsetenv("TESTENV_INT")
+}
+
+// EnvFloat parses non-negative numbers and falls back for unset or invalid values.
+func TestEnvFloat(t *testing.T) {
+	tests := []struct {
+		value string
+		want  float64
+	}{
+		{"2.5", 2.5},
+		{" 10 ", 10},
+		{"", 1.5},
+		{"-1", 1.5},
+		{"abc", 1.5},
+	}
+	for _, tt := range tests {
+		os.Setenv("TESTENV_FLOAT", tt.value)
+		if got := EnvFloat("TESTENV_FLOAT", 1.5); got != tt.want {
+			t.Errorf("EnvFloat(%q, 1.5) = %g, want %g", tt.value, got, tt.want)
+		}
+	}
+	os.Unsetenv("TESTENV_FLOAT")
+}
+
+// EnvDuration parses Go durations and falls back for unset or invalid values.
+func TestEnvDuration(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"1.5s", 1500 * time.Millisecond},
+		{" 2m ", 2 * time.Minute},
+		{"", time.Second},
+		{"-1s", time.Second},
+		{"5", time.Second},
+	}
+	for _, tt := range tests {
+		os.Setenv("TESTENV_DURATION", tt.value)
+		if got := EnvDuration("TESTENV_DURATION", time.Second); got != tt.want {
+			t.Errorf("EnvDuration(%q, 1s) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+	os.Unsetenv("TESTENV_DURATION")
+}
+
 // ValidateSessionName accepts valid names.
 func TestValidateSessionName_Valid(t *testing.T) {
 	valid := []string{"abc", "my-session", "test_123", "A-B-C", "a1b2c3"}
@@ -241,3 +307,18 @@ func TestResolveAgentConfig_CaseInsensitive(t *testing.T) {
 		t.Fatalf("name: got %q, want %q", name, "Codex")
 	}
 }
+
+// PrefixWriter prefixes complete lines and holds partial ones until Flush.
+func TestPrefixWriter(t *testing.T) {
+	var sb strings.Builder
+	w := NewPrefixWriter(&sb, "[a] ")
+	fmt.Fprint(w, "one\ntw")
+	if sb.String() != "[a] one\n" {
+		t.Fatalf("partial line leaked: %q", sb.String())
+	}
+	fmt.Fprint(w, "o\n\nthree")
+	w.Flush()
+	if want := "[a] one\n[a] two\n[a] \n[a] three\n"; sb.String() != want {
+		t.Fatalf("got %q, want %q", sb.String(), want)
+	}
+}
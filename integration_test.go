@@ -4,7 +4,9 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -19,6 +21,7 @@ import (
 	"github.com/dlee6018/agent-orchestrator/memory"
 	"github.com/dlee6018/agent-orchestrator/orchestrator"
 	"github.com/dlee6018/agent-orchestrator/tmux"
+	"github.com/dlee6018/agent-orchestrator/transcript"
 )
 
 // setupIntegration prepares an isolated tmux environment for a single test.
@@ -194,7 +197,7 @@ func TestIntegration_WaitForPaneUpdate(t *testing.T) {
 		t.Fatalf("SendMessage: %v", err)
 	}
 
-	pane, err := tmux.WaitForPaneUpdate(session, initial, 10*time.Second)
+	pane, err := tmux.WaitForPaneUpdate(session, initial, 10*time.Second, nil)
 	if err != nil {
 		t.Fatalf("WaitForPaneUpdate: %v", err)
 	}
@@ -214,7 +217,7 @@ func TestIntegration_SendAndCaptureWithRecovery_HappyPath(t *testing.T) {
 	}
 
 	marker := fmt.Sprintf("HAPPY_%d", time.Now().UnixNano())
-	pane, err := tmux.SendAndCaptureWithRecovery(session, workDir, command, fmt.Sprintf("echo %s", marker), initial)
+	pane, err := tmux.SendAndCaptureWithRecovery(session, workDir, command, fmt.Sprintf("echo %s", marker), initial, nil)
 	if err != nil {
 		t.Fatalf("SendAndCaptureWithRecovery: %v", err)
 	}
@@ -235,7 +238,7 @@ func TestIntegration_SendAndCaptureWithRecovery_AfterSessionKill(t *testing.T) {
 
 	// sendAndCaptureWithRecovery should recreate the session and succeed.
 	marker := fmt.Sprintf("RECOVER_%d", time.Now().UnixNano())
-	pane, err := tmux.SendAndCaptureWithRecovery(session, workDir, command, fmt.Sprintf("echo %s", marker), "")
+	pane, err := tmux.SendAndCaptureWithRecovery(session, workDir, command, fmt.Sprintf("echo %s", marker), "", nil)
 	if err != nil {
 		t.Fatalf("SendAndCaptureWithRecovery after kill: %v", err)
 	}
@@ -254,7 +257,7 @@ func TestIntegration_SendAndCaptureWithRecovery_AfterServerKill(t *testing.T) {
 	time.Sleep(200 * time.Millisecond)
 
 	marker := fmt.Sprintf("SRVRECOV_%d", time.Now().UnixNano())
-	pane, err := tmux.SendAndCaptureWithRecovery(session, workDir, command, fmt.Sprintf("echo %s", marker), "")
+	pane, err := tmux.SendAndCaptureWithRecovery(session, workDir, command, fmt.Sprintf("echo %s", marker), "", nil)
 	if err != nil {
 		t.Fatalf("SendAndCaptureWithRecovery after server kill: %v", err)
 	}
@@ -271,7 +274,7 @@ func TestIntegration_MultipleMessages(t *testing.T) {
 	lastPane := ""
 	for i := 0; i < 3; i++ {
 		marker := fmt.Sprintf("MSG%d_%d", i, time.Now().UnixNano())
-		pane, err := tmux.SendAndCaptureWithRecovery(session, workDir, command, fmt.Sprintf("echo %s", marker), lastPane)
+		pane, err := tmux.SendAndCaptureWithRecovery(session, workDir, command, fmt.Sprintf("echo %s", marker), lastPane, nil)
 		if err != nil {
 			t.Fatalf("message %d: %v", i, err)
 		}
@@ -567,7 +570,7 @@ func TestIntegration_AutonomousLoop_TaskCompleteEmbedded(t *testing.T) {
 	}
 }
 
-// Three consecutive API errors cause the autonomous loop to abort.
+// MaxAttempts consecutive server errors cause the autonomous loop to abort.
 func TestIntegration_AutonomousLoop_APIErrorAbort(t *testing.T) {
 	session, workDir, command := setupIntegration(t)
 
@@ -584,6 +587,9 @@ func TestIntegration_AutonomousLoop_APIErrorAbort(t *testing.T) {
 	defer srv.Close()
 
 	setupAutonomous(t, srv.URL, 10)
+	oldRetry := orchestrator.DefaultRetry
+	orchestrator.DefaultRetry = orchestrator.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: time.Second}
+	t.Cleanup(func() { orchestrator.DefaultRetry = oldRetry })
 	createTestSession(t, session, workDir, command)
 
 	start := time.Now()
@@ -594,9 +600,9 @@ func TestIntegration_AutonomousLoop_APIErrorAbort(t *testing.T) {
 	if calls != 3 {
 		t.Fatalf("expected exactly 3 API calls before abort, got %d", calls)
 	}
-	// Should have waited ~10s (two 5s sleeps between the 3 attempts).
-	if elapsed < 8*time.Second {
-		t.Fatalf("expected at least ~10s of backoff, but only took %s", elapsed)
+	// Should have waited ~2s (two 1s sleeps between the 3 attempts).
+	if elapsed < 2*time.Second {
+		t.Fatalf("expected at least ~2s of backoff, but only took %s", elapsed)
 	}
 }
 
@@ -937,8 +943,8 @@ done:
 	if !strings.Contains(first.Orchestrator, marker) {
 		t.Fatalf("iteration_end orchestrator message missing marker %q: %q", marker, first.Orchestrator)
 	}
-	if !strings.Contains(first.ClaudeOutput, marker) {
-		t.Fatalf("iteration_end claude_output missing marker %q: %q", marker, first.ClaudeOutput)
+	if !strings.Contains(first.AgentOutput, marker) {
+		t.Fatalf("iteration_end agent_output missing marker %q: %q", marker, first.AgentOutput)
 	}
 	if first.DurationMs <= 0 {
 		t.Fatalf("iteration_end duration_ms should be positive, got %d", first.DurationMs)
@@ -1021,12 +1027,18 @@ func TestIntegration_AutonomousLoop_MemorySaved(t *testing.T) {
 	if err != nil {
 		t.Fatalf("memory.json should exist after TASK_COMPLETE: %v", err)
 	}
-	var facts []string
-	if err := json.Unmarshal(data, &facts); err != nil {
+	var saved struct {
+		Version int             `json:"version"`
+		Records []memory.Record `json:"records"`
+	}
+	if err := json.Unmarshal(data, &saved); err != nil {
 		t.Fatalf("memory.json should be valid JSON: %v", err)
 	}
-	if len(facts) != 1 || facts[0] != "project uses bash for tests" {
-		t.Fatalf("unexpected memory facts: %v", facts)
+	if saved.Version != memory.Version || len(saved.Records) != 1 || saved.Records[0].Text != "project uses bash for tests" {
+		t.Fatalf("unexpected memory facts: %+v", saved)
+	}
+	if saved.Records[0].Model != "test-model" || saved.Records[0].CreatedAt.IsZero() {
+		t.Fatalf("memory record should carry its provenance: %+v", saved.Records[0])
 	}
 }
 
@@ -1055,7 +1067,7 @@ func TestIntegration_AutonomousLoop_MemoryInjectedIntoPrompt(t *testing.T) {
 	session, workDir, command := setupIntegration(t)
 
 	initialFacts := []string{"always use gofmt", "tests must not use t.Parallel()"}
-	if err := memory.SaveMemory(workDir, initialFacts); err != nil {
+	if err := memory.SaveMemory(workDir, []memory.Record{{Text: initialFacts[0]}, {Text: initialFacts[1]}}); err != nil {
 		t.Fatalf("SaveMemory: %v", err)
 	}
 
@@ -1124,6 +1136,53 @@ func TestIntegration_AutonomousLoop_MemorySaveStrippedFromReply(t *testing.T) {
 	}
 }
 
+// MEMORY_UPDATE and MEMORY_FORGET lines edit stored facts and are stripped
+// before the reply is forwarded to Claude Code.
+func TestIntegration_AutonomousLoop_MemoryEdits(t *testing.T) {
+	session, workDir, command := setupIntegration(t)
+
+	initial := []memory.Record{{ID: "a1", Text: "build with make"}, {ID: "b2", Text: "lint with golangci-lint"}}
+	if err := memory.SaveMemory(workDir, initial); err != nil {
+		t.Fatalf("SaveMemory: %v", err)
+	}
+	memories, err := memory.LoadMemory(workDir)
+	if err != nil {
+		t.Fatalf("LoadMemory: %v", err)
+	}
+
+	marker := fmt.Sprintf("MEMEDIT_%d", time.Now().UnixNano())
+	callCount := 0
+	srv := mockOpenRouter(func(w http.ResponseWriter, r *http.Request) {
+		callCount++
+		switch callCount {
+		case 1:
+			respondJSON(w, fmt.Sprintf("echo %s\nMEMORY_UPDATE: a1 => build with go build\nMEMORY_FORGET: lint with golangci-lint", marker), callCount)
+		default:
+			respondJSON(w, "TASK_COMPLETE", callCount)
+		}
+	})
+	defer srv.Close()
+
+	setupAutonomous(t, srv.URL, 5)
+	createTestSession(t, session, workDir, command)
+	orchestrator.AutonomousLoop(session, workDir, command, "test-key", "test-model", "memory edit test", "Claude Code", nil, memories)
+
+	pane, err := tmux.CapturePane(session)
+	if err != nil {
+		t.Fatalf("CapturePane: %v", err)
+	}
+	if !strings.Contains(pane, marker) || strings.Contains(pane, "MEMORY_") {
+		t.Fatalf("memory directives should be stripped before sending to Claude Code:\n%s", pane)
+	}
+	facts, err := memory.LoadMemory(workDir)
+	if err != nil {
+		t.Fatalf("LoadMemory: %v", err)
+	}
+	if len(facts) != 1 || facts[0].ID != "a1" || facts[0].Text != "build with go build" {
+		t.Fatalf("unexpected memory after edits: %+v", facts)
+	}
+}
+
 // Memory persists across the loop exit even when max iterations is reached.
 func TestIntegration_AutonomousLoop_MemorySavedOnMaxIterations(t *testing.T) {
 	session, workDir, command := setupIntegration(t)
@@ -1209,7 +1268,8 @@ func TestIntegration_AutonomousLoop_CodexAgentName(t *testing.T) {
 	}
 }
 
-// SSE events include both agent_output and claude_output fields for backward compatibility.
+// iteration_end carries the cleaned pane once, in agent_output, and the
+// visible screen with ANSI escapes in agent_ansi.
 func TestIntegration_AutonomousLoop_SSEAgentOutput(t *testing.T) {
 	session, workDir, command := setupIntegration(t)
 
@@ -1258,14 +1318,482 @@ done:
 	}
 
 	first := iterEndEvents[0]
-	// Both fields should be set for backward compatibility.
-	if first.ClaudeOutput == "" {
-		t.Fatal("iteration_end claude_output should be set")
+	if !strings.Contains(first.AgentOutput, marker) {
+		t.Fatalf("iteration_end agent_output missing marker %q: %q", marker, first.AgentOutput)
+	}
+	if first.ClaudeOutput != "" {
+		t.Fatalf("the deprecated claude_output should not be set: %q", first.ClaudeOutput)
+	}
+	if first.AgentANSI == "" {
+		t.Fatal("iteration_end agent_ansi should be set")
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Tool-calling integration tests
+// ---------------------------------------------------------------------------
+
+// respondToolCalls writes an orchestrator.Response whose assistant message carries tool calls.
+func respondToolCalls(w http.ResponseWriter, callID int, calls ...orchestrator.ToolCall) {
+	resp := orchestrator.Response{
+		ID: fmt.Sprintf("call-%d", callID),
+		Choices: []orchestrator.Choice{
+			{Message: orchestrator.Message{Role: "assistant", ToolCalls: calls}, FinishReason: "tool_calls"},
+		},
+		Usage: orchestrator.Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// newToolCall builds a function tool call with JSON arguments.
+func newToolCall(id, name, args string) orchestrator.ToolCall {
+	return orchestrator.ToolCall{ID: id, Type: "function", Function: orchestrator.ToolCallFunction{Name: name, Arguments: args}}
+}
+
+// Tool calls are dispatched to tmux, results fed back as tool messages, and
+// only complete_task (not an embedded TASK_COMPLETE) ends the run.
+func TestIntegration_RunLoop_ToolCalls(t *testing.T) {
+	session, workDir, command := setupIntegration(t)
+
+	marker := fmt.Sprintf("TOOL_%d", time.Now().UnixNano())
+	var requests []orchestrator.Request
+
+	srv := mockOpenRouter(func(w http.ResponseWriter, r *http.Request) {
+		var req orchestrator.Request
+		json.NewDecoder(r.Body).Decode(&req)
+		requests = append(requests, req)
+		switch len(requests) {
+		case 1:
+			respondToolCalls(w, 1,
+				newToolCall("call_a", orchestrator.ToolTypeText, fmt.Sprintf(`{"text":"echo %s"}`, marker)),
+				newToolCall("call_b", orchestrator.ToolSaveMemory, `{"fact":"bash is the agent"}`),
+			)
+		case 2:
+			// Plain text quoting the marker must neither end the run nor be
+			// typed into the pane in tool mode.
+			respondJSON(w, "# the legacy TASK_COMPLETE marker is ignored here", 2)
+		default:
+			respondToolCalls(w, len(requests), newToolCall("call_c", orchestrator.ToolCompleteTask, `{"summary":"echoed"}`))
+		}
+	})
+	defer srv.Close()
+
+	setupAutonomous(t, srv.URL, 6)
+	createTestSession(t, session, workDir, command)
+	orchestrator.RunLoop(orchestrator.LoopConfig{
+		Session:     session,
+		WorkDir:     workDir,
+		Command:     command,
+		AgentName:   "Claude Code",
+		Task:        "tool test",
+		Provider:    &orchestrator.OpenRouterProvider{APIKey: "test-key"},
+		Model:       "test-model",
+		ToolCalling: true,
+	})
+
+	if len(requests) != 3 {
+		t.Fatalf("expected 3 API calls, got %d", len(requests))
+	}
+	if len(requests[0].Tools) == 0 {
+		t.Fatal("tool mode requests should declare tools")
+	}
+
+	// Call 2 should see: system, user, assistant(tool_calls), tool, tool.
+	msgs := requests[1].Messages
+	if len(msgs) != 5 {
+		t.Fatalf("call 2: expected 5 messages, got %d", len(msgs))
+	}
+	if len(msgs[2].ToolCalls) != 2 {
+		t.Fatalf("call 2: assistant message should carry 2 tool calls, got %+v", msgs[2])
+	}
+	if msgs[3].Role != "tool" || msgs[3].ToolCallID != "call_a" || !strings.Contains(msgs[3].Content, marker) {
+		t.Fatalf("call 2: type_text result should contain pane output, got %+v", msgs[3])
+	}
+	if msgs[4].Role != "tool" || msgs[4].ToolCallID != "call_b" {
+		t.Fatalf("call 2: expected save_memory result, got %+v", msgs[4])
+	}
+
+	// Call 3 should see the plain text answered with a nudge, not pane output.
+	msgs = requests[2].Messages
+	if last := msgs[len(msgs)-1]; last.Role != "user" || !strings.Contains(last.Content, "no tool call") {
+		t.Fatalf("call 3: expected a nudge to call a tool, got %+v", last)
+	}
+
+	pane, err := tmux.CapturePane(session)
+	if err != nil {
+		t.Fatalf("CapturePane: %v", err)
+	}
+	if !strings.Contains(pane, marker) {
+		t.Fatalf("marker %q not in pane:\n%s", marker, pane)
+	}
+	if strings.Contains(pane, "legacy TASK_COMPLETE") {
+		t.Fatalf("plain text should not be typed into the pane:\n%s", pane)
+	}
+
+	facts, err := memory.LoadMemory(workDir)
+	if err != nil {
+		t.Fatalf("LoadMemory: %v", err)
+	}
+	if len(facts) != 1 || facts[0].Text != "bash is the agent" {
+		t.Fatalf("expected saved fact, got %v", facts)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Pane delta integration tests
+// ---------------------------------------------------------------------------
+
+// After the first capture, the LLM only receives lines added since its last observation.
+func TestIntegration_AutonomousLoop_SendsPaneDelta(t *testing.T) {
+	session, workDir, command := setupIntegration(t)
+
+	markers := make([]string, 4)
+	for i := range markers {
+		markers[i] = fmt.Sprintf("DELTA%d_%d", i, time.Now().UnixNano())
+	}
+	var captured [][]orchestrator.Message
+
+	srv := mockOpenRouter(func(w http.ResponseWriter, r *http.Request) {
+		var req orchestrator.Request
+		json.NewDecoder(r.Body).Decode(&req)
+		captured = append(captured, req.Messages)
+		n := len(captured)
+		if n <= len(markers) {
+			respondJSON(w, "echo "+markers[n-1], n)
+			return
+		}
+		respondJSON(w, orchestrator.TaskCompleteMarker, n)
+	})
+	defer srv.Close()
+
+	setupAutonomous(t, srv.URL, 10)
+	createTestSession(t, session, workDir, command)
+	orchestrator.AutonomousLoop(session, workDir, command, "test-key", "test-model", "delta test", "Claude Code", nil, nil)
+
+	if len(captured) != 5 {
+		t.Fatalf("expected 5 API calls, got %d", len(captured))
+	}
+	msgs := captured[4]
+	last := msgs[len(msgs)-1].Content
+	if !strings.Contains(last, markers[3]) {
+		t.Fatalf("latest pane message should contain %q:\n%s", markers[3], last)
 	}
-	if first.AgentOutput == "" {
-		t.Fatal("iteration_end agent_output should be set")
+	if strings.Contains(last, markers[0]) {
+		t.Fatalf("latest pane message should not repeat the first command's output:\n%s", last)
 	}
-	if first.ClaudeOutput != first.AgentOutput {
-		t.Fatalf("claude_output and agent_output should match: %q vs %q", first.ClaudeOutput, first.AgentOutput)
+	if !strings.Contains(last, "earlier lines unchanged") {
+		t.Fatalf("latest pane message should note omitted lines:\n%s", last)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Checkpoint / resume integration tests
+// ---------------------------------------------------------------------------
+
+// A run interrupted by the iteration cap is checkpointed and resumed from the
+// next iteration with its full conversation.
+func TestIntegration_RunLoop_CheckpointAndResume(t *testing.T) {
+	session, workDir, command := setupIntegration(t)
+
+	marker := fmt.Sprintf("RESUME_%d", time.Now().UnixNano())
+	var captured [][]orchestrator.Message
+	srv := mockOpenRouter(func(w http.ResponseWriter, r *http.Request) {
+		var req orchestrator.Request
+		json.NewDecoder(r.Body).Decode(&req)
+		captured = append(captured, req.Messages)
+		switch len(captured) {
+		case 1:
+			respondJSON(w, "echo "+marker, 1)
+		case 2:
+			respondJSON(w, "MEMORY_SAVE: resumable runs work\necho second", 2)
+		default:
+			respondJSON(w, orchestrator.TaskCompleteMarker, len(captured))
+		}
+	})
+	defer srv.Close()
+
+	setupAutonomous(t, srv.URL, 2)
+	createTestSession(t, session, workDir, command)
+	runDir := filepath.Join(t.TempDir(), "run-1")
+	cfg := orchestrator.LoopConfig{
+		Session:   session,
+		WorkDir:   workDir,
+		Command:   command,
+		AgentName: "Claude Code",
+		Task:      "resume test",
+		Provider:  &orchestrator.OpenRouterProvider{APIKey: "test-key"},
+		Model:     "test-model",
+		RunID:     "run-1",
+		RunDir:    runDir,
+	}
+	orchestrator.RunLoop(cfg)
+
+	cp, err := orchestrator.LoadCheckpoint(runDir)
+	if err != nil {
+		t.Fatalf("LoadCheckpoint: %v", err)
+	}
+	if cp.Status != orchestrator.StatusMaxIterations || cp.Iteration != 2 || cp.Resumable() || !cp.ResumableWith(5, orchestrator.Budget{}) {
+		t.Fatalf("unexpected checkpoint after cap: status=%s iteration=%d", cp.Status, cp.Iteration)
+	}
+	if len(cp.Messages) != 6 || len(cp.Memories) != 1 || cp.LastPane == "" {
+		t.Fatalf("checkpoint should hold 6 messages, 1 memory and the pane: %d %v", len(cp.Messages), cp.Memories)
+	}
+
+	orchestrator.MaxIterations = 5
+	cfg.Resume = cp
+	orchestrator.RunLoop(cfg)
+
+	if len(captured) != 3 {
+		t.Fatalf("expected 3 API calls in total, got %d", len(captured))
+	}
+	resumed := captured[2]
+	if len(resumed) != 6 || !strings.Contains(resumed[3].Content, marker) {
+		t.Fatalf("resumed request should carry the restored conversation, got %d messages", len(resumed))
+	}
+	final, err := orchestrator.LoadCheckpoint(runDir)
+	if err != nil {
+		t.Fatalf("LoadCheckpoint: %v", err)
+	}
+	if final.Status != orchestrator.StatusComplete || final.Iteration != 3 {
+		t.Fatalf("expected complete at iteration 3, got %s at %d", final.Status, final.Iteration)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Transcript integration tests
+// ---------------------------------------------------------------------------
+
+// A run with a transcript records its events, LLM exchanges and pane captures.
+func TestIntegration_RunLoop_Transcript(t *testing.T) {
+	session, workDir, command := setupIntegration(t)
+
+	marker := fmt.Sprintf("TRANSCRIPT_%d", time.Now().UnixNano())
+	callCount := 0
+	srv := mockOpenRouter(func(w http.ResponseWriter, r *http.Request) {
+		callCount++
+		if callCount == 1 {
+			respondJSON(w, "echo "+marker, callCount)
+			return
+		}
+		respondJSON(w, orchestrator.TaskCompleteMarker, callCount)
+	})
+	defer srv.Close()
+
+	setupAutonomous(t, srv.URL, 5)
+	createTestSession(t, session, workDir, command)
+	runDir := filepath.Join(t.TempDir(), "run-t")
+	orchestrator.RunLoop(orchestrator.LoopConfig{
+		Session:    session,
+		WorkDir:    workDir,
+		Command:    command,
+		AgentName:  "Claude Code",
+		Task:       "transcript test",
+		Provider:   &orchestrator.OpenRouterProvider{APIKey: "test-key"},
+		Model:      "test-model",
+		RunID:      "run-t",
+		RunDir:     runDir,
+		Transcript: true,
+	})
+
+	records, err := transcript.Read(filepath.Join(runDir, transcript.FileName))
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	counts := map[string]int{}
+	var eventTypes []string
+	for _, rec := range records {
+		counts[rec.Kind]++
+		if rec.Kind == transcript.KindEvent {
+			eventTypes = append(eventTypes, rec.Event.Type)
+		}
+	}
+	if counts[transcript.KindLLM] != 2 || counts[transcript.KindPane] != 1 {
+		t.Fatalf("expected 2 llm and 1 pane records, got %v", counts)
+	}
+	if eventTypes[0] != "task_info" || eventTypes[len(eventTypes)-1] != "complete" {
+		t.Fatalf("unexpected event sequence: %v", eventTypes)
+	}
+	for _, typ := range eventTypes {
+		if typ == "pane_update" || typ == "orchestrator_delta" {
+			t.Fatalf("live %s events should not be recorded: %v", typ, eventTypes)
+		}
+	}
+
+	for _, rec := range records {
+		switch rec.Kind {
+		case transcript.KindLLM:
+			var req orchestrator.Request
+			if err := json.Unmarshal(rec.Request, &req); err != nil || req.Model != "test-model" || len(req.Messages) < 2 {
+				t.Fatalf("llm record should hold the full request: %v %s", err, rec.Request)
+			}
+		case transcript.KindPane:
+			if !strings.Contains(rec.RawPane, marker) || !strings.Contains(rec.CleanPane, marker) {
+				t.Fatalf("pane record should contain the marker: %+v", rec)
+			}
+		}
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Deterministic replay integration tests
+// ---------------------------------------------------------------------------
+
+// recordRun runs a scripted session against a mock server and returns its transcript path.
+func recordRun(t *testing.T, session, workDir, command string, replies []string, memories []memory.Record) string {
+	t.Helper()
+	callCount := 0
+	srv := mockOpenRouter(func(w http.ResponseWriter, r *http.Request) {
+		callCount++
+		respondJSON(w, replies[min(callCount, len(replies))-1], callCount)
+	})
+	defer srv.Close()
+
+	setupAutonomous(t, srv.URL, 5)
+	runDir := filepath.Join(t.TempDir(), "recorded")
+	orchestrator.RunLoop(orchestrator.LoopConfig{
+		Session:    session,
+		WorkDir:    workDir,
+		Command:    command,
+		AgentName:  "Claude Code",
+		Task:       "golden task",
+		Provider:   &orchestrator.OpenRouterProvider{APIKey: "test-key"},
+		Model:      "test-model",
+		Memories:   memories,
+		MemoryDir:  t.TempDir(),
+		RunID:      "recorded",
+		RunDir:     runDir,
+		Transcript: true,
+	})
+	return filepath.Join(runDir, transcript.FileName)
+}
+
+// A recorded session replays against a fresh tmux session with no network and
+// no drift, from the recorded memory and without saving memory or checkpoints.
+func TestIntegration_ReplayProvider_Golden(t *testing.T) {
+	session, workDir, _ := setupIntegration(t)
+	// A shell without startup files prints the same output in both sessions.
+	command := "bash --norc --noprofile"
+	createTestSession(t, session, workDir, command)
+	path := recordRun(t, session, workDir, command, []string{"echo golden-one", "echo golden-two", orchestrator.TaskCompleteMarker},
+		[]memory.Record{{ID: "a1", Text: "the build uses make"}})
+
+	replay, err := orchestrator.LoadReplayProvider(path)
+	if err != nil {
+		t.Fatalf("LoadReplayProvider: %v", err)
+	}
+	if got := replay.Memories(); len(got) != 1 || got[0].Text != "the build uses make" {
+		t.Fatalf("expected the recorded memory, got %+v", got)
+	}
+	replay.Normalize = orchestrator.NormalizeWhitespace
+	tmux.CleanupSession(session)
+	createTestSession(t, session, workDir, command)
+	memDir, runDir := t.TempDir(), t.TempDir()
+	orchestrator.RunLoop(orchestrator.LoopConfig{
+		Session:   session,
+		WorkDir:   workDir,
+		Command:   command,
+		AgentName: "Claude Code",
+		Task:      replay.Task(),
+		Provider:  replay,
+		Model:     replay.Model(),
+		Memories:  replay.Memories(),
+		MemoryDir: memDir,
+		RunDir:    runDir,
+	})
+
+	if err := replay.Err(); err != nil {
+		t.Fatalf("replay drifted: %v", err)
+	}
+	if n := replay.Remaining(); n != 0 {
+		t.Fatalf("expected every recorded call to be used, %d left", n)
+	}
+	for _, dir := range []string{memDir, runDir} {
+		if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+			t.Fatalf("a replay should write nothing, found %d entries in %s", len(entries), dir)
+		}
+	}
+}
+
+// A prompt change is detected as drift and aborts the run immediately.
+func TestIntegration_ReplayProvider_DriftAborts(t *testing.T) {
+	session, workDir, command := setupIntegration(t)
+	createTestSession(t, session, workDir, command)
+	path := recordRun(t, session, workDir, command, []string{"echo drift-one", orchestrator.TaskCompleteMarker}, nil)
+
+	replay, err := orchestrator.LoadReplayProvider(path)
+	if err != nil {
+		t.Fatalf("LoadReplayProvider: %v", err)
+	}
+	start := time.Now()
+	orchestrator.RunLoop(orchestrator.LoopConfig{
+		Session:   session,
+		WorkDir:   workDir,
+		Command:   command,
+		AgentName: "Claude Code",
+		Task:      "a different task",
+		Provider:  replay,
+		Model:     replay.Model(),
+	})
+
+	var drift *orchestrator.DriftError
+	if !errors.As(replay.Err(), &drift) || drift.Call != 1 {
+		t.Fatalf("expected drift at call 1, got %v", replay.Err())
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Fatalf("drift should abort without retries, took %s", elapsed)
+	}
+}
+
+// Two supervised runs drive their own sessions concurrently and both complete.
+func TestIntegration_Supervisor_ParallelRuns(t *testing.T) {
+	session, _, command := setupIntegration(t)
+	srv := mockOpenRouter(func(w http.ResponseWriter, r *http.Request) {
+		var req orchestrator.Request
+		json.NewDecoder(r.Body).Decode(&req)
+		reply := orchestrator.TaskCompleteMarker
+		if len(req.Messages) == 2 {
+			// First turn: echo the run's task ("Task: task-a ...") as a marker.
+			reply = "echo marker-" + strings.Fields(req.Messages[1].Content)[1]
+		}
+		respondJSON(w, reply, 1)
+	})
+	defer srv.Close()
+	setupAutonomous(t, srv.URL, 5)
+
+	sup := orchestrator.NewSupervisor()
+	var sessions []string
+	for _, name := range []string{"task-a", "task-b"} {
+		s, dir := session+"-"+name, t.TempDir()
+		createTestSession(t, s, dir, command)
+		sessions = append(sessions, s)
+		sup.Start(orchestrator.LoopConfig{
+			RunID:     name,
+			Session:   s,
+			WorkDir:   dir,
+			Command:   command,
+			AgentName: "Claude Code",
+			Task:      name,
+			Provider:  &orchestrator.OpenRouterProvider{APIKey: "test-key"},
+			Model:     "test-model",
+			Broker:    dashboard.NewSSEBroker(),
+			Stdout:    io.Discard,
+		})
+	}
+
+	for _, st := range sup.Wait() {
+		if st.Status != orchestrator.StatusComplete || st.Iteration != 2 {
+			t.Fatalf("%s: %+v", st.ID, st)
+		}
+	}
+	for i, name := range []string{"task-a", "task-b"} {
+		pane, err := tmux.CapturePane(sessions[i])
+		if err != nil {
+			t.Fatalf("CapturePane: %v", err)
+		}
+		other := []string{"task-b", "task-a"}[i]
+		if !strings.Contains(pane, "marker-"+name) || strings.Contains(pane, "marker-"+other) {
+			t.Fatalf("session %s has the wrong output:\n%s", sessions[i], pane)
+		}
 	}
 }
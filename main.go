@@ -2,6 +2,8 @@ package main
 
 import (
 	"bufio"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"os/signal"
@@ -11,10 +13,12 @@ import (
 	"syscall"
 
 	"github.com/dlee6018/agent-orchestrator/dashboard"
+	"github.com/dlee6018/agent-orchestrator/git"
 	"github.com/dlee6018/agent-orchestrator/helpers"
 	"github.com/dlee6018/agent-orchestrator/memory"
 	"github.com/dlee6018/agent-orchestrator/orchestrator"
 	"github.com/dlee6018/agent-orchestrator/tmux"
+	"github.com/dlee6018/agent-orchestrator/transcript"
 )
 
 const (
@@ -28,6 +32,26 @@ func main() {
 		fmt.Fprintf(os.Stderr, "warning: failed to load .env: %v\n", err)
 	}
 
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "resume":
+			resumeMain(os.Args[2:])
+			return
+		case "replay":
+			replayMain(os.Args[2:])
+			return
+		case "parallel":
+			parallelMain(os.Args[2:])
+			return
+		case "git-checkpoints":
+			gitCheckpointsMain(os.Args[2:])
+			return
+		case "memory":
+			memoryMain(os.Args[2:])
+			return
+		}
+	}
+
 	session := helpers.EnvOrDefault("CLAUDE_TMUX_SESSION", defaultSession)
 	tmux.Socket = helpers.EnvOrDefault("CLAUDE_TMUX_SOCKET", defaultSocket)
 	if err := helpers.ValidateSessionName(session); err != nil {
@@ -69,88 +93,368 @@ func main() {
 		}
 	}
 
-	if err := tmux.EnsureClaudeSession(session, workDir, command); err != nil {
-		fmt.Fprintf(os.Stderr, "failed to prepare session: %v\n", err)
-		os.Exit(1)
-	}
-
+	autonomous := helpers.EnvBool("AUTONOMOUS_MODE", true)
 	terminateOnQuit := helpers.EnvBool("TERMINATE_WHEN_QUIT", false)
 
-	if helpers.EnvBool("AUTONOMOUS_MODE", true) {
-		apiKey := os.Getenv("OPENROUTER_API_KEY")
-		if apiKey == "" {
-			fmt.Fprintln(os.Stderr, "OPENROUTER_API_KEY is required in autonomous mode")
+	if autonomous {
+		provider, model, err := resolveProvider(helpers.EnvOrDefault("LLM_PROVIDER", orchestrator.ProviderOpenRouter))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
 			os.Exit(1)
 		}
-		model := helpers.EnvOrDefault("OPENROUTER_MODEL", orchestrator.DefaultModel)
-		if v := os.Getenv("MAX_ITERATIONS"); v != "" {
-			if n, err := strconv.Atoi(v); err == nil && n > 0 {
-				orchestrator.MaxIterations = n
-			}
-		}
-		if v := os.Getenv("MEMORY_MAX_FACTS"); v != "" {
-			if n, err := strconv.Atoi(v); err == nil && n > 0 {
-				memory.MaxFacts = n
-			}
-		}
+		applyLimitsFromEnv()
 
-		fmt.Print("Enter task description: ")
 		scanner := bufio.NewScanner(os.Stdin)
 		scanner.Buffer(make([]byte, 0, 1024*1024), 1024*1024)
-		if !scanner.Scan() {
-			fmt.Fprintln(os.Stderr, "no task provided")
-			os.Exit(1)
+		replay, isReplay := provider.(*orchestrator.ReplayProvider)
+		var task string
+		if isReplay {
+			task = replay.Task()
+			fmt.Printf("Replaying recorded task: %s\n", task)
+		} else {
+			fmt.Print("Enter task description: ")
+			if !scanner.Scan() {
+				fmt.Fprintln(os.Stderr, "no task provided")
+				os.Exit(1)
+			}
+			task = strings.TrimSpace(scanner.Text())
 		}
-		task := strings.TrimSpace(scanner.Text())
 		if task == "" {
 			fmt.Fprintln(os.Stderr, "empty task")
 			os.Exit(1)
 		}
 
-		memories, memErr := memory.LoadMemory(workDir)
+		runID, repoDir := orchestrator.NewRunID(), workDir
+		var wt *git.Worktree
+		if helpers.EnvBool("WORKTREE", false) {
+			// Isolate the run on a fresh branch before the agent starts in it.
+			// Created only now, so a bad provider or task leaves nothing behind.
+			wt, err = prepareWorktree(workDir, runID)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "failed to create worktree: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("Working in worktree %s on branch %s\n", wt.Path, wt.Branch)
+			workDir = wt.Path
+		}
+		if err := tmux.EnsureClaudeSession(session, workDir, command); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to prepare session: %v\n", err)
+			discardWorktrees(wt)
+			os.Exit(1)
+		}
+
+		store := memoryStore(repoDir, wt)
+		var memories []memory.Record
+		var memErr error
+		if isReplay {
+			// Replay from the memory the recorded run started with, so the
+			// prompts match; the store is left untouched.
+			memories = replay.Memories()
+		} else {
+			memories, memErr = store.Load()
+		}
 		if memErr != nil {
 			fmt.Fprintf(os.Stderr, "warning: failed to load memory: %v\n", memErr)
 		} else if len(memories) > 0 {
-			fmt.Printf("Loaded %d memory facts from %s\n", len(memories), memory.FileName)
+			fmt.Printf("Loaded %s\n", describeMemory(memories))
 		}
 
-		var broker *dashboard.SSEBroker
-		if helpers.EnvBool("DASHBOARD_ENABLED", true) {
-			broker = dashboard.NewSSEBroker()
-			dashPort := 0
-			if v := os.Getenv("DASHBOARD_PORT"); v != "" {
-				if n, err := strconv.Atoi(v); err == nil && n >= 0 {
-					dashPort = n
-				}
-			}
-			addr, dashErr := dashboard.StartDashboard(broker, dashPort)
-			if dashErr != nil {
-				fmt.Fprintf(os.Stderr, "warning: failed to start dashboard: %v\n", dashErr)
-				broker = nil
-			} else {
-				dashURL := fmt.Sprintf("http://%s", addr)
-				fmt.Printf("Dashboard: %s\n", dashURL)
-				if helpers.EnvBool("DASHBOARD_OPEN", true) {
-					dashboard.OpenBrowser(dashURL)
-				}
-			}
+		broker := startDashboard()
+		showGitCheckpoints(func(string) string { return workDir })
+
+		cfg := envLoopConfig(scanner)
+		cfg.Session, cfg.WorkDir, cfg.Command, cfg.AgentName = session, workDir, command, agentName
+		cfg.Task, cfg.Provider, cfg.Model = task, provider, model
+		cfg.Broker, cfg.Memories, cfg.Control = broker, memories, broker.Control()
+		cfg.MemoryUserDir, cfg.MemoryBranch = store.UserDir, store.Branch
+		if wt != nil {
+			cfg.Worktree, cfg.MemoryDir = wt, repoDir
+		}
+		// The run ID also names the worktree branch, git snapshots and
+		// memory sources, so it is set even without checkpoints.
+		cfg.RunID = runID
+		if helpers.EnvBool("CHECKPOINTS", true) && !isReplay {
+			cfg.RunDir = filepath.Join(runsDir(), cfg.RunID)
 		}
 
-		runWithCleanup(session, terminateOnQuit, func() {
-			orchestrator.AutonomousLoop(session, workDir, command, apiKey, model, task, agentName, broker, memories)
+		runWithCleanup([]string{session}, terminateOnQuit, func() {
+			orchestrator.RunLoop(cfg)
 		})
+		if wt != nil {
+			finishWorktree(os.Stdout, wt, runID, helpers.EnvBool("WORKTREE_CLEANUP", false))
+		}
+		if isReplay {
+			if err := replay.Err(); err != nil {
+				fmt.Fprintf(os.Stderr, "replay failed: %v\n", err)
+				os.Exit(1)
+			}
+			if n := replay.Remaining(); n > 0 {
+				fmt.Fprintf(os.Stderr, "replay ended with %d recorded LLM calls unused\n", n)
+				os.Exit(1)
+			}
+			fmt.Println("Replay matched every recorded LLM call.")
+		}
 	} else {
+		if err := tmux.EnsureClaudeSession(session, workDir, command); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to prepare session: %v\n", err)
+			os.Exit(1)
+		}
 		fmt.Printf("Session %q is ready. Type messages and press Enter. Use /quit to exit.\n", session)
-		runWithCleanup(session, terminateOnQuit, func() {
+		runWithCleanup([]string{session}, terminateOnQuit, func() {
 			chatLoop(session, workDir, command)
 		})
 	}
 }
 
+// providerAPIKeyEnv maps provider names to the env var holding their API key.
+var providerAPIKeyEnv = map[string]string{
+	orchestrator.ProviderOpenRouter: "OPENROUTER_API_KEY",
+	orchestrator.ProviderAnthropic:  "ANTHROPIC_API_KEY",
+	orchestrator.ProviderOpenAI:     "OPENAI_API_KEY",
+}
+
+// resolveProvider builds the named orchestrator LLM provider (normally from
+// LLM_PROVIDER) and its model from env vars. LLM_API_KEY and LLM_MODEL override
+// the provider-specific key and model (OPENROUTER_MODEL is honoured for OpenRouter).
+func resolveProvider(name string) (orchestrator.Provider, string, error) {
+	name = strings.ToLower(name)
+	if name == orchestrator.ProviderReplay {
+		ref := os.Getenv("REPLAY_TRANSCRIPT")
+		if ref == "" {
+			return nil, "", fmt.Errorf("REPLAY_TRANSCRIPT is required with LLM_PROVIDER=%s", name)
+		}
+		replay, err := orchestrator.LoadReplayProvider(resolveTranscript(ref))
+		if err != nil {
+			return nil, "", err
+		}
+		replay.Normalize = orchestrator.NormalizeWhitespace
+		return replay, replay.Model(), nil
+	}
+	apiKey := os.Getenv("LLM_API_KEY")
+	if apiKey == "" && providerAPIKeyEnv[name] != "" {
+		apiKey = os.Getenv(providerAPIKeyEnv[name])
+	}
+	baseURL := os.Getenv("LLM_BASE_URL")
+	provider, err := orchestrator.NewProvider(name, apiKey, baseURL)
+	if err != nil {
+		return nil, "", err
+	}
+	if apiKey == "" && orchestrator.ProviderNeedsAPIKey(name, baseURL) {
+		keyVar := providerAPIKeyEnv[name]
+		if keyVar == "" {
+			keyVar = "LLM_API_KEY"
+		}
+		return nil, "", fmt.Errorf("%s is required in autonomous mode (LLM_PROVIDER=%s)", keyVar, name)
+	}
+	model := orchestrator.DefaultModelFor(name)
+	if name == orchestrator.ProviderOpenRouter {
+		model = helpers.EnvOrDefault("OPENROUTER_MODEL", model)
+	}
+	model = helpers.EnvOrDefault("LLM_MODEL", model)
+	return provider, model, nil
+}
+
+// envLoopConfig returns a LoopConfig with the tuning options that come from
+// env vars; callers fill in the session, task and provider.
+func envLoopConfig(scanner *bufio.Scanner) orchestrator.LoopConfig {
+	return orchestrator.LoopConfig{
+		Stream:             helpers.EnvBool("LLM_STREAM", true),
+		ToolCalling:        helpers.EnvBool("TOOL_CALLING", false),
+		AskHuman:           askHuman(scanner),
+		ContextTokens:      helpers.EnvInt("CONTEXT_MAX_TOKENS", 100000),
+		KeepPanes:          helpers.EnvInt("CONTEXT_KEEP_PANES", 3),
+		FullPane:           !helpers.EnvBool("PANE_DELTA", true),
+		Transcript:         helpers.EnvBool("TRANSCRIPT", true),
+		GitCheckpoints:     helpers.EnvBool("GIT_CHECKPOINTS", false),
+		AcceptanceCommands: splitCommands(os.Getenv("ACCEPTANCE_COMMANDS")),
+		FallbackModels:     splitList(os.Getenv("LLM_FALLBACK_MODELS")),
+		Budget: orchestrator.Budget{
+			MaxTokens: helpers.EnvInt("BUDGET_MAX_TOKENS", 0),
+			MaxCost:   helpers.EnvFloat("BUDGET_MAX_COST", 0),
+			SoftLimit: helpers.EnvFloat("BUDGET_SOFT_LIMIT", orchestrator.DefaultSoftLimit),
+		},
+	}
+}
+
+// splitList splits a comma-separated list, dropping empty entries.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// splitCommands splits a ";;"-separated list of shell commands, dropping
+// empty entries. A single ";" stays inside its command.
+func splitCommands(s string) []string {
+	var commands []string
+	for _, c := range strings.Split(s, ";;") {
+		if c = strings.TrimSpace(c); c != "" {
+			commands = append(commands, c)
+		}
+	}
+	return commands
+}
+
+// applyLimitsFromEnv sets the iteration and memory limits, the LLM retry
+// policy and the LLM price table from env vars.
+func applyLimitsFromEnv() {
+	if v := os.Getenv("LLM_PRICES"); v != "" {
+		if err := json.Unmarshal([]byte(v), &orchestrator.Prices); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: invalid LLM_PRICES: %v\n", err)
+		}
+	}
+	if v := os.Getenv("MAX_ITERATIONS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			orchestrator.MaxIterations = n
+		}
+	}
+	retry := &orchestrator.DefaultRetry
+	retry.MaxAttempts = max(1, helpers.EnvInt("LLM_RETRY_ATTEMPTS", retry.MaxAttempts))
+	retry.BaseDelay = helpers.EnvDuration("LLM_RETRY_BASE_DELAY", retry.BaseDelay)
+	retry.MaxDelay = helpers.EnvDuration("LLM_RETRY_MAX_DELAY", retry.MaxDelay)
+	if v := os.Getenv("MEMORY_MAX_FACTS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			memory.MaxFacts = n
+		}
+	}
+	memory.TopK = helpers.EnvInt("MEMORY_TOP_K", memory.TopK)
+}
+
+// startDashboard starts the web dashboard unless disabled, returning its
+// broker or nil when the dashboard is off or failed to start.
+func startDashboard() *dashboard.SSEBroker {
+	broker := dashboard.NewSSEBroker()
+	if _, ok := serveDashboard(func(port int) (string, error) { return dashboard.StartDashboard(broker, port) }); !ok {
+		return nil
+	}
+	return broker
+}
+
+// serveDashboard starts a dashboard server with start on DASHBOARD_PORT
+// unless DASHBOARD_ENABLED is false. It prints (and optionally opens) the URL
+// and reports whether the dashboard is running.
+func serveDashboard(start func(port int) (string, error)) (string, bool) {
+	if !helpers.EnvBool("DASHBOARD_ENABLED", true) {
+		return "", false
+	}
+	dashPort := 0
+	if v := os.Getenv("DASHBOARD_PORT"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			dashPort = n
+		}
+	}
+	showRunHistory(runsDir())
+	addr, err := start(dashPort)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: failed to start dashboard: %v\n", err)
+		return "", false
+	}
+	dashURL := fmt.Sprintf("http://%s", addr)
+	fmt.Printf("Dashboard: %s\n", dashURL)
+	if helpers.EnvBool("DASHBOARD_OPEN", true) {
+		dashboard.OpenBrowser(dashURL)
+	}
+	return dashURL, true
+}
+
+// showRunHistory serves the runs under base to the dashboard's history page.
+func showRunHistory(base string) {
+	dashboard.RunHistory = func() ([]dashboard.RunSummary, error) {
+		return orchestrator.ListRuns(base)
+	}
+	dashboard.RunEvents = func(run string) ([]dashboard.IterationEvent, error) {
+		records, err := transcript.Read(filepath.Join(base, run, transcript.FileName))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil, fmt.Errorf("no transcript was recorded for run %s", run)
+			}
+			return nil, err
+		}
+		return transcript.Events(records), nil
+	}
+}
+
+// runsDir returns the directory holding per-run state: RUNS_DIR, or
+// $XDG_STATE_HOME/agent-orchestrator/runs (default ~/.local/state/...).
+func runsDir() string {
+	if dir := helpers.EnvOrDefault("RUNS_DIR", ""); dir != "" {
+		return dir
+	}
+	state := os.Getenv("XDG_STATE_HOME")
+	if state == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return filepath.Join(os.TempDir(), "agent-orchestrator", "runs")
+		}
+		state = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(state, "agent-orchestrator", "runs")
+}
+
+// memoryStore returns the memory store of the project in dir. MEMORY_SCOPES
+// lists the scopes besides the project's to use: user memory under the XDG
+// config directory and memory for the branch checked out in dir. For a run
+// isolated in worktree wt (nil otherwise) the branch is the run's own branch,
+// while the memory files stay with the project in dir.
+func memoryStore(dir string, wt *git.Worktree) memory.Store {
+	store := memory.Store{ProjectDir: dir}
+	for _, name := range strings.Split(helpers.EnvOrDefault("MEMORY_SCOPES", "user,project,branch"), ",") {
+		switch scope, err := memory.ParseScope(name); {
+		case err != nil:
+			fmt.Fprintf(os.Stderr, "warning: MEMORY_SCOPES: %v\n", err)
+		case scope == memory.ScopeUser:
+			store.UserDir = memory.UserDir()
+		case scope == memory.ScopeBranch && wt != nil:
+			store.Branch = wt.Branch
+		case scope == memory.ScopeBranch:
+			store.Branch, _ = git.CurrentBranch(dir)
+		}
+	}
+	return store
+}
+
+// describeMemory summarizes loaded memory, e.g. "12 memory facts (user 2, project 10)".
+func describeMemory(records []memory.Record) string {
+	counts := make(map[memory.Scope]int)
+	for _, rec := range records {
+		counts[rec.Scope]++
+	}
+	var parts []string
+	for _, scope := range []memory.Scope{memory.ScopeUser, memory.ScopeProject, memory.ScopeBranch} {
+		if counts[scope] > 0 {
+			parts = append(parts, fmt.Sprintf("%s %d", scope, counts[scope]))
+		}
+	}
+	return fmt.Sprintf("%d memory facts (%s)", len(records), strings.Join(parts, ", "))
+}
+
+// askHuman returns an ask_human handler that prompts on stdout and reads the
+// operator's answer as one line from scanner.
+func askHuman(scanner *bufio.Scanner) func(question string) (string, error) {
+	return func(question string) (string, error) {
+		fmt.Printf("\nThe orchestrator asks: %s\nanswer> ", question)
+		if !scanner.Scan() {
+			if err := scanner.Err(); err != nil {
+				return "", err
+			}
+			return "", errors.New("input closed")
+		}
+		return strings.TrimSpace(scanner.Text()), nil
+	}
+}
+
 // runWithCleanup runs fn, optionally registering signal handlers and session cleanup.
-func runWithCleanup(session string, terminate bool, fn func()) {
+func runWithCleanup(sessions []string, terminate bool, fn func()) {
 	if terminate {
-		cleanup := func() { tmux.CleanupSession(session) }
+		cleanup := func() {
+			for _, session := range sessions {
+				tmux.CleanupSession(session)
+			}
+		}
 
 		sigCh := make(chan os.Signal, 1)
 		signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
@@ -189,7 +493,7 @@ func chatLoop(session, workDir, command string) {
 			return
 		}
 
-		pane, err := tmux.SendAndCaptureWithRecovery(session, workDir, command, message, lastPane)
+		pane, err := tmux.SendAndCaptureWithRecovery(session, workDir, command, message, lastPane, nil)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "message failed: %v\n", err)
 			continue
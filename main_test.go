@@ -1,9 +1,15 @@
 package main
 
 import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/dlee6018/agent-orchestrator/git"
 	"github.com/dlee6018/agent-orchestrator/helpers"
+	"github.com/dlee6018/agent-orchestrator/memory"
 )
 
 // DEFAULT_MODEL=gpt-4o causes ResolveAgentConfig to return the codex command.
@@ -34,3 +40,144 @@ func TestClaudeCMD_OverridesDefaultModel(t *testing.T) {
 		t.Fatalf("CLAUDE_CMD should override DEFAULT_MODEL command, got %q", result)
 	}
 }
+
+// RUNS_DIR overrides the run directory; otherwise it lives under XDG_STATE_HOME.
+func TestRunsDir(t *testing.T) {
+	t.Setenv("RUNS_DIR", "/tmp/custom-runs")
+	if got := runsDir(); got != "/tmp/custom-runs" {
+		t.Fatalf("RUNS_DIR: got %q", got)
+	}
+	t.Setenv("RUNS_DIR", "")
+	t.Setenv("XDG_STATE_HOME", "/tmp/state")
+	if got, want := runsDir(), filepath.Join("/tmp/state", "agent-orchestrator", "runs"); got != want {
+		t.Fatalf("XDG_STATE_HOME: got %q, want %q", got, want)
+	}
+}
+
+// MEMORY_SCOPES picks the scopes beyond the project's; the branch scope is
+// off outside a git checkout and is the run's branch in a worktree.
+func TestMemoryStore(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", "/tmp/config")
+	t.Setenv("MEMORY_SCOPES", "")
+	if store := memoryStore(dir, nil); store.ProjectDir != dir || store.UserDir != filepath.Join("/tmp/config", "agent-orchestrator") || store.Branch != "" {
+		t.Fatalf("default scopes: %+v", store)
+	}
+	wt := &git.Worktree{Repo: dir, Path: filepath.Join(t.TempDir(), "worktree"), Branch: "agent/run-1"}
+	if store := memoryStore(dir, wt); store.ProjectDir != dir || store.Branch != "agent/run-1" {
+		t.Fatalf("worktree run: %+v", store)
+	}
+	t.Setenv("MEMORY_SCOPES", "project")
+	if store := memoryStore(dir, wt); store.UserDir != "" || store.Branch != "" {
+		t.Fatalf("project only: %+v", store)
+	}
+	records := []memory.Record{{Scope: memory.ScopeProject}, {Scope: memory.ScopeUser}, {Scope: memory.ScopeProject}}
+	if got := describeMemory(records); got != "3 memory facts (user 1, project 2)" {
+		t.Fatalf("describeMemory = %q", got)
+	}
+}
+
+// Parallel tasks get default sessions, absolute work dirs, and must not share either.
+func TestLoadParallelTasks(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+	os.Mkdir(a, 0o755)
+	os.Mkdir(b, 0o755)
+	write := func(content string) string {
+		path := filepath.Join(dir, "tasks.json")
+		os.WriteFile(path, []byte(content), 0o644)
+		return path
+	}
+
+	tasks, err := loadParallelTasks(write(`[{"task":" fix a ","work_dir":"`+a+`"},{"task":"fix b","work_dir":"`+b+`","session":"custom"}]`), "loop", false)
+	if err != nil {
+		t.Fatalf("loadParallelTasks: %v", err)
+	}
+	if tasks[0].Task != "fix a" || tasks[0].Session != "loop-1" || tasks[1].Session != "custom" {
+		t.Fatalf("unexpected tasks: %+v", tasks)
+	}
+
+	for content, want := range map[string]string{
+		`[]`: "no tasks",
+		`[{"task":"x","work_dir":"` + a + `"},{"task":"y","work_dir":"` + a + `"}]`:                             "used by another task",
+		`[{"task":"x","work_dir":"` + a + `","session":"s"},{"task":"y","work_dir":"` + b + `","session":"s"}]`: "used by another task",
+		`[{"task":"x","work_dir":"` + filepath.Join(dir, "missing") + `"}]`:                                     "not a directory",
+		`[{"task":"","work_dir":"` + a + `"}]`:                                                                  "empty",
+		`[{"task":"x","work_dir":"` + a + `","session":"bad name"}]`:                                            "invalid character",
+	} {
+		if _, err := loadParallelTasks(write(content), "loop", false); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("%s: expected error containing %q, got %v", content, want, err)
+		}
+	}
+
+	// With worktrees each run gets its own checkout, so tasks may share a repository.
+	shared := write(`[{"task":"x","work_dir":"` + a + `"},{"task":"y","work_dir":"` + a + `"}]`)
+	if _, err := loadParallelTasks(shared, "loop", true); err != nil {
+		t.Fatalf("shared work_dir with worktrees: %v", err)
+	}
+}
+
+// A worktree run reports its branch and, with cleanup, keeps the work on the branch only.
+func TestPrepareAndFinishWorktree(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	repo := t.TempDir()
+	git := func(args ...string) string {
+		out, err := exec.Command("git", append([]string{"-C", repo, "-c", "user.name=t", "-c", "user.email=t@t"}, args...)...).CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+		return string(out)
+	}
+	git("init", "-q")
+	os.WriteFile(filepath.Join(repo, "README"), []byte("hi\n"), 0o644)
+	git("add", "README")
+	git("commit", "-q", "-m", "init")
+	t.Setenv("RUNS_DIR", t.TempDir())
+
+	wt, err := prepareWorktree(repo, "run-1")
+	if err != nil {
+		t.Fatalf("prepareWorktree: %v", err)
+	}
+	if wt.Branch != "agent/run-1" || !strings.HasPrefix(wt.Path, os.Getenv("RUNS_DIR")) {
+		t.Fatalf("unexpected worktree %+v", wt)
+	}
+	os.WriteFile(filepath.Join(wt.Path, "out.txt"), []byte("result\n"), 0o644)
+
+	var report strings.Builder
+	finishWorktree(&report, wt, "run-1", true)
+	if !strings.Contains(report.String(), "agent/run-1") || !strings.Contains(report.String(), "out.txt") {
+		t.Fatalf("report missing branch or diff stats:\n%s", report.String())
+	}
+	if _, err := os.Stat(wt.Path); !os.IsNotExist(err) {
+		t.Fatal("worktree should be removed with cleanup")
+	}
+	if files := git("ls-tree", "--name-only", "agent/run-1"); !strings.Contains(files, "out.txt") {
+		t.Fatalf("branch is missing the agent's work: %q", files)
+	}
+	if _, err := os.Stat(filepath.Join(repo, "out.txt")); !os.IsNotExist(err) {
+		t.Fatal("main checkout should be untouched")
+	}
+}
+
+// Iteration numbers name the run's checkpoint refs; anything else is a git revision.
+func TestSnapshotRev(t *testing.T) {
+	if got := snapshotRev("run-1", "3"); got != "refs/checkpoints/run-1/3" {
+		t.Errorf("snapshotRev(3) = %q", got)
+	}
+	if got := snapshotRev("run-1", "abc123"); got != "abc123" {
+		t.Errorf("snapshotRev(abc123) = %q", got)
+	}
+}
+
+// Acceptance commands are separated by ";;", so single semicolons stay in a command.
+func TestSplitCommands(t *testing.T) {
+	got := splitCommands(" go test ./... ;; cd web; npm test ;; ")
+	if len(got) != 2 || got[0] != "go test ./..." || got[1] != "cd web; npm test" {
+		t.Fatalf("splitCommands = %q", got)
+	}
+	if splitCommands("") != nil {
+		t.Fatal("empty input should give no commands")
+	}
+}
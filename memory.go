@@ -0,0 +1,303 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/dlee6018/agent-orchestrator/helpers"
+	"github.com/dlee6018/agent-orchestrator/memory"
+	"github.com/dlee6018/agent-orchestrator/orchestrator"
+)
+
+// memoryUsage documents the memory subcommand.
+const memoryUsage = `usage:
+  go-orchestrator memory list [-scope user|project|branch]
+  go-orchestrator memory search [-k N] <query>
+  go-orchestrator memory add [-scope S] [-tags a,b] <fact>
+  go-orchestrator memory rm <id|fact>...
+  go-orchestrator memory edit <id|fact> <new fact>
+  go-orchestrator memory compact [-scope S] [-dry-run]
+  go-orchestrator memory export [file]
+  go-orchestrator memory import [-scope S] <file|->
+
+The store is the memory of the current directory: its project memory, user
+memory and the memory of the checked-out branch, as limited by MEMORY_SCOPES.
+Facts are named by id or by text, as in MEMORY_FORGET. compact asks the
+LLM_PROVIDER model to consolidate each scope and prints the changes;
+-dry-run leaves the store untouched.`
+
+// errMemoryUsage reports invalid arguments to the memory subcommand.
+var errMemoryUsage = errors.New("invalid usage")
+
+// memoryMain implements `memory list|search|add|rm|edit|compact|export|import`
+// on the memory store of the current directory.
+func memoryMain(args []string) {
+	wd, err := os.Getwd()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to resolve working directory: %v\n", err)
+		os.Exit(1)
+	}
+	compactor := func() (memory.CompactFunc, error) {
+		provider, model, err := resolveProvider(helpers.EnvOrDefault("LLM_PROVIDER", orchestrator.ProviderOpenRouter))
+		if err != nil {
+			return nil, err
+		}
+		return func(prompt string) (string, error) {
+			msgs := []orchestrator.Message{{Role: "user", Content: prompt}}
+			c, err := provider.Complete(orchestrator.Request{Model: model, Messages: msgs, Temperature: 0.2})
+			return c.Content, err
+		}, nil
+	}
+	switch err := runMemory(memoryStore(wd, nil), args, os.Stdin, os.Stdout, compactor); {
+	case errors.Is(err, errMemoryUsage):
+		fmt.Fprintln(os.Stderr, memoryUsage)
+		os.Exit(2)
+	case err != nil:
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// runMemory runs one memory subcommand against store, reading imports from
+// stdin and writing to stdout. compactor supplies the LLM for compact.
+func runMemory(store memory.Store, args []string, stdin io.Reader, stdout io.Writer, compactor func() (memory.CompactFunc, error)) error {
+	if len(args) == 0 {
+		return errMemoryUsage
+	}
+	cmd := args[0]
+	fs := flag.NewFlagSet("memory "+cmd, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	var scopeName, tags string
+	var k int
+	var dryRun bool
+	switch cmd {
+	case "list", "add", "compact", "import":
+		fs.StringVar(&scopeName, "scope", "", "memory scope")
+	}
+	switch cmd {
+	case "search":
+		fs.IntVar(&k, "k", 10, "number of facts")
+	case "add":
+		fs.StringVar(&tags, "tags", "", "comma-separated tags")
+	case "compact":
+		fs.BoolVar(&dryRun, "dry-run", false, "print the changes without saving")
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		return errMemoryUsage
+	}
+	args = fs.Args()
+	var scope memory.Scope
+	if scopeName != "" {
+		var err error
+		if scope, err = memory.ParseScope(scopeName); err != nil {
+			return err
+		}
+		if store.Resolve(scope) != scope && (cmd == "add" || cmd == "import") {
+			return fmt.Errorf("the %s memory scope is off (see MEMORY_SCOPES)", scope)
+		}
+	}
+
+	records, err := store.Load()
+	if err != nil {
+		return err
+	}
+	loaded := records
+
+	switch {
+	case cmd == "list" && len(args) == 0:
+		shown := 0
+		for _, rec := range records {
+			if scope == "" || rec.Scope == scope {
+				printMemoryRecord(stdout, rec)
+				shown++
+			}
+		}
+		if shown == 0 {
+			fmt.Fprintln(stdout, "No memory facts.")
+		}
+		return nil
+
+	case cmd == "search" && len(args) > 0:
+		visible := memory.Visible(records)
+		scores := memory.Scores(visible, strings.Join(args, " "))
+		var hits []int
+		for i, score := range scores {
+			if score > 0 {
+				hits = append(hits, i)
+			}
+		}
+		sort.SliceStable(hits, func(a, b int) bool { return scores[hits[a]] > scores[hits[b]] })
+		if len(hits) == 0 {
+			fmt.Fprintln(stdout, "No matching memory facts.")
+		}
+		for _, i := range hits[:min(len(hits), max(k, 1))] {
+			fmt.Fprintf(stdout, "%6.2f  ", scores[i])
+			printMemoryRecord(stdout, visible[i])
+		}
+		return nil
+
+	case cmd == "add" && len(args) > 0:
+		var tagList []string
+		for _, tag := range strings.Split(tags, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				tagList = append(tagList, tag)
+			}
+		}
+		rec := memory.NewRecord(strings.Join(args, " "), "", "", tagList...)
+		rec.Scope = store.Resolve(scope)
+		records = memory.DeduplicateMemory(append(records, rec))
+		if err := store.Save(loaded, records); err != nil {
+			return err
+		}
+		for _, saved := range records {
+			if saved.Scope == rec.Scope && saved.Text == rec.Text {
+				fmt.Fprintf(stdout, "Added [%s] to %s memory.\n", saved.ID, saved.Scope)
+			}
+		}
+		return nil
+
+	case cmd == "rm" && len(args) > 0:
+		var removed []memory.Record
+		for _, target := range args {
+			var old memory.Record
+			if records, old, err = memory.Apply(records, memory.Edit{Target: target}, "", ""); err != nil {
+				return err
+			}
+			removed = append(removed, old)
+		}
+		if err := store.Save(loaded, records); err != nil {
+			return err
+		}
+		for _, old := range removed {
+			fmt.Fprintf(stdout, "Removed [%s] %s\n", old.ID, old.Text)
+		}
+		return nil
+
+	case cmd == "edit" && len(args) > 1:
+		text := strings.Join(args[1:], " ")
+		updated, old, err := memory.Apply(records, memory.Edit{Target: args[0], Text: text}, "", "")
+		if err != nil {
+			return err
+		}
+		if err := store.Save(loaded, updated); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Updated [%s] %q → %q\n", old.ID, old.Text, text)
+		return nil
+
+	case cmd == "compact" && len(args) == 0:
+		fn, err := compactor()
+		if err != nil {
+			return err
+		}
+		var all []memory.Record
+		for _, s := range memory.Scopes {
+			var before []memory.Record
+			for _, rec := range records {
+				if rec.Scope == s {
+					before = append(before, rec)
+				}
+			}
+			if len(before) == 0 || (scope != "" && s != scope) {
+				all = append(all, before...)
+				continue
+			}
+			after, err := memory.CompactMemory(fn, before)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(stdout, "%s memory: %d → %d facts\n", s, len(before), len(after))
+			printMemoryDiff(stdout, before, after)
+			all = append(all, after...)
+		}
+		if dryRun {
+			fmt.Fprintln(stdout, "Dry run: memory not changed.")
+			return nil
+		}
+		return store.Save(loaded, all)
+
+	case cmd == "export" && len(args) <= 1:
+		if len(args) == 0 {
+			return memory.Export(stdout, records)
+		}
+		f, err := os.Create(args[0])
+		if err != nil {
+			return err
+		}
+		if err := memory.Export(f, records); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Exported %d memory facts to %s\n", len(records), args[0])
+		return nil
+
+	case cmd == "import" && len(args) == 1:
+		in := stdin
+		if args[0] != "-" {
+			f, err := os.Open(args[0])
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			in = f
+		}
+		imported, err := memory.Import(in)
+		if err != nil {
+			return err
+		}
+		for i := range imported {
+			if scope != "" {
+				imported[i].Scope = scope
+			}
+			imported[i].Scope = store.Resolve(imported[i].Scope)
+		}
+		merged, added := memory.Merge(records, imported)
+		if err := store.Save(loaded, merged); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Imported %d new memory facts (%d read).\n", added, len(imported))
+		return nil
+	}
+	return errMemoryUsage
+}
+
+// printMemoryRecord prints one fact as a list line: id, scope, hits,
+// creation date, text and tags.
+func printMemoryRecord(w io.Writer, rec memory.Record) {
+	tags := ""
+	if len(rec.Tags) > 0 {
+		tags = "  #" + strings.Join(rec.Tags, " #")
+	}
+	fmt.Fprintf(w, "%-8s  %-7s  %3d  %s  %s%s\n", rec.ID, rec.Scope, rec.Hits, rec.CreatedAt.Format("2006-01-02"), rec.Text, tags)
+}
+
+// printMemoryDiff prints the facts compaction removed ("-"), added ("+")
+// and kept ("  "), matching records by ID.
+func printMemoryDiff(w io.Writer, before, after []memory.Record) {
+	kept := make(map[string]bool, len(after))
+	for _, rec := range after {
+		kept[rec.ID] = true
+	}
+	existed := make(map[string]bool, len(before))
+	for _, rec := range before {
+		existed[rec.ID] = true
+		mark := "-"
+		if kept[rec.ID] {
+			mark = " "
+		}
+		fmt.Fprintf(w, "%s [%s] %s\n", mark, rec.ID, rec.Text)
+	}
+	for _, rec := range after {
+		if !existed[rec.ID] {
+			fmt.Fprintf(w, "+ [%s] %s\n", rec.ID, rec.Text)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package memory
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MatchThreshold is the minimum word overlap (Dice coefficient, 0 to 1) at
+// which a MEMORY_FORGET or MEMORY_UPDATE target fuzzily matches a fact.
+var MatchThreshold = 0.6
+
+// Edit is a MEMORY_FORGET or MEMORY_UPDATE directive.
+type Edit struct {
+	Target string // record ID or fact text
+	Text   string // replacement fact; empty to forget the target
+}
+
+// Forget reports whether the edit removes its target.
+func (e Edit) Forget() bool {
+	return e.Text == ""
+}
+
+// ExtractMemoryEdits scans the LLM reply for lines matching
+// "MEMORY_FORGET: <id or fact>" and "MEMORY_UPDATE: <id or fact> => <new fact>",
+// and returns the edits and the cleaned reply with those lines removed.
+// Lines with an empty target, or an update without a new fact, are stripped
+// but ignored.
+func ExtractMemoryEdits(reply string) ([]Edit, string) {
+	var edits []Edit
+	var kept []string
+	for _, line := range strings.Split(reply, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if after, ok := strings.CutPrefix(trimmed, "MEMORY_FORGET:"); ok {
+			if target := strings.TrimSpace(after); target != "" {
+				edits = append(edits, Edit{Target: target})
+			}
+		} else if after, ok := strings.CutPrefix(trimmed, "MEMORY_UPDATE:"); ok {
+			target, text, _ := strings.Cut(after, "=>")
+			target, text = strings.TrimSpace(target), strings.TrimSpace(text)
+			if target != "" && text != "" {
+				edits = append(edits, Edit{Target: target, Text: text})
+			}
+		} else {
+			kept = append(kept, line)
+		}
+	}
+	return edits, strings.Join(kept, "\n")
+}
+
+// Find returns the index of the record target refers to: the record with
+// that ID, else the fact with the same text ignoring case and spacing, else
+// the fact whose words overlap target's by at least MatchThreshold, provided
+// no other fact does.
+func Find(records []Record, target string) (int, error) {
+	target = strings.TrimSpace(target)
+	id := strings.Trim(target, "[]")
+	for i, rec := range records {
+		if rec.ID != "" && rec.ID == id {
+			return i, nil
+		}
+	}
+	norm := normalize(target)
+	for i, rec := range records {
+		if normalize(rec.Text) == norm {
+			return i, nil
+		}
+	}
+
+	best, candidates := -1, 0
+	words := wordSet(target)
+	for i, rec := range records {
+		if dice(words, wordSet(rec.Text)) >= MatchThreshold {
+			best = i
+			candidates++
+		}
+	}
+	if candidates == 0 {
+		return -1, fmt.Errorf("Find: no memory fact matches %q", target)
+	}
+	if candidates > 1 {
+		return -1, fmt.Errorf("Find: %q matches several memory facts; use an id", target)
+	}
+	return best, nil
+}
+
+// Apply performs the edit on records and returns the updated records and
+// the record as it was before the edit. An updated record keeps its ID and
+// creation time; it is credited to run and model and loses its hits.
+func Apply(records []Record, e Edit, run, model string) ([]Record, Record, error) {
+	i, err := Find(records, e.Target)
+	if err != nil {
+		return records, Record{}, fmt.Errorf("Apply: %w", err)
+	}
+	old := records[i]
+	out := append([]Record(nil), records...)
+	if e.Forget() {
+		return append(out[:i], out[i+1:]...), old, nil
+	}
+	rec := &out[i]
+	rec.Text, rec.SourceRun, rec.Model, rec.Hits = e.Text, run, model, 0
+	rec.LastUsedAt = time.Now().UTC()
+	return DeduplicateMemory(out), old, nil
+}
+
+// normalize lowercases text and collapses its whitespace.
+func normalize(text string) string {
+	return strings.Join(strings.Fields(strings.ToLower(text)), " ")
+}
+
+// wordSet returns the distinct retrieval tokens of text.
+func wordSet(text string) map[string]bool {
+	set := make(map[string]bool)
+	for _, tok := range tokenize(text) {
+		set[tok] = true
+	}
+	return set
+}
+
+// dice returns the Dice coefficient of two word sets: twice the shared
+// words over the total.
+func dice(a, b map[string]bool) float64 {
+	if len(a)+len(b) == 0 {
+		return 0
+	}
+	shared := 0
+	for w := range a {
+		if b[w] {
+			shared++
+		}
+	}
+	return 2 * float64(shared) / float64(len(a)+len(b))
+}
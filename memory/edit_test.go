@@ -0,0 +1,75 @@
+package memory
+
+import (
+	"strings"
+	"testing"
+)
+
+// ExtractMemoryEdits parses forget and update lines and strips them from the reply.
+func TestExtractMemoryEdits(t *testing.T) {
+	reply := "check build\nMEMORY_FORGET: a1b2c3d4\n  MEMORY_UPDATE: run make test => run go test ./...\nMEMORY_UPDATE: missing arrow\nMEMORY_FORGET:  \nMEMORY_SAVE: kept"
+	edits, cleaned := ExtractMemoryEdits(reply)
+	if len(edits) != 2 || !edits[0].Forget() || edits[0].Target != "a1b2c3d4" {
+		t.Fatalf("unexpected edits: %+v", edits)
+	}
+	if edits[1].Target != "run make test" || edits[1].Text != "run go test ./..." || edits[1].Forget() {
+		t.Fatalf("unexpected update: %+v", edits[1])
+	}
+	if cleaned != "check build\nMEMORY_SAVE: kept" {
+		t.Fatalf("unexpected cleaned reply: %q", cleaned)
+	}
+}
+
+// Find matches by ID, then by text ignoring case and spacing, then fuzzily
+// by shared words, and refuses weak or ambiguous matches.
+func TestFind(t *testing.T) {
+	facts := []Record{
+		{ID: "a1", Text: "Build with make build"},
+		{ID: "b2", Text: "Tests run with go test ./..."},
+		{ID: "c3", Text: "dashboard port is 8080"},
+		{ID: "d4", Text: "dashboard port defaults to 0"},
+	}
+	for target, want := range map[string]int{
+		"b2":                            1,
+		"[c3]":                          2,
+		"  build WITH make   build ":    0,
+		"tests run with go test":        1,
+		"the dashboard port defaults 0": 3,
+	} {
+		if got, err := Find(facts, target); err != nil || got != want {
+			t.Fatalf("Find(%q) = %d, %v; want %d", target, got, err, want)
+		}
+	}
+	if _, err := Find(facts, "deploy with terraform"); err == nil || !strings.Contains(err.Error(), "no memory fact") {
+		t.Fatalf("unrelated target should not match: %v", err)
+	}
+	if _, err := Find(facts, "dashboard port"); err == nil || !strings.Contains(err.Error(), "several") {
+		t.Fatalf("ambiguous target should not match: %v", err)
+	}
+}
+
+// Apply removes a forgotten record and rewrites an updated one in place,
+// crediting the run that changed it.
+func TestApply(t *testing.T) {
+	facts := records("build with make", "tests use go test")
+	facts[1].Hits = 3
+
+	forgot, old, err := Apply(facts, Edit{Target: facts[0].ID}, "run-2", "m2")
+	if err != nil || len(forgot) != 1 || forgot[0].ID != facts[1].ID || old.Text != "build with make" || len(facts) != 2 {
+		t.Fatalf("forget: %+v %+v %v", forgot, old, err)
+	}
+
+	updated, old, err := Apply(facts, Edit{Target: "tests use go test", Text: "tests use gotestsum"}, "run-2", "m2")
+	if err != nil || old.Text != "tests use go test" {
+		t.Fatalf("update: %+v %v", old, err)
+	}
+	got := updated[1]
+	if got.ID != facts[1].ID || got.Text != "tests use gotestsum" || got.SourceRun != "run-2" || got.Model != "m2" ||
+		got.Hits != 0 || !got.CreatedAt.Equal(facts[1].CreatedAt) || facts[1].Text != "tests use go test" {
+		t.Fatalf("unexpected updated record: %+v", got)
+	}
+
+	if _, _, err := Apply(facts, Edit{Target: "nothing like it"}, "", ""); err == nil {
+		t.Fatal("expected error for unmatched target")
+	}
+}
@@ -1,16 +1,28 @@
 package memory
 
 import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"hash/fnv"
+	"io"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strings"
+	"time"
 )
 
 // FileName is the name of the persistent memory file.
 const FileName = "memory.json"
 
+// Version is the format version SaveMemory writes. Files without one hold a
+// plain JSON array of facts and are migrated by LoadMemory.
+const Version = 1
+
 // MaxFacts is the memory compaction threshold; overridden via MEMORY_MAX_FACTS.
 var MaxFacts = 50
 
@@ -18,78 +30,348 @@ var MaxFacts = 50
 // It receives a prompt string and returns the LLM's reply.
 type CompactFunc func(prompt string) (string, error)
 
-// LoadMemory reads the memory file from workDir and returns the stored facts.
+// Record is one remembered fact with its provenance.
+type Record struct {
+	ID         string    `json:"id"`
+	Text       string    `json:"text"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`         // last time a run was given the fact
+	SourceRun  string    `json:"source_run,omitempty"` // run that saved it, empty for migrated facts
+	Model      string    `json:"model,omitempty"`      // orchestrator model that saved it
+	Tags       []string  `json:"tags,omitempty"`
+	Hits       int       `json:"hits"`            // times the fact was saved again after it was learned
+	Scope      Scope     `json:"scope,omitempty"` // set by Store.Load; not written to memory files
+}
+
+// UnmarshalJSON also accepts a bare string, the fact format before records,
+// so old memory files and checkpoints still load.
+func (r *Record) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		*r = Record{}
+		return json.Unmarshal(data, &r.Text)
+	}
+	type plain Record
+	return json.Unmarshal(data, (*plain)(r))
+}
+
+// file is the on-disk layout of the memory file.
+type file struct {
+	Version int      `json:"version"`
+	Records []Record `json:"records"`
+}
+
+// NewRecord returns a record for a fact learned now by run using model.
+func NewRecord(text, run, model string, tags ...string) Record {
+	now := time.Now().UTC()
+	return Record{ID: newID(), Text: text, CreatedAt: now, LastUsedAt: now, SourceRun: run, Model: model, Tags: tags}
+}
+
+// newID returns a short random record ID.
+func newID() string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// textID returns an ID derived from a fact's text, for facts stored without one.
+func textID(text string) string {
+	h := fnv.New32a()
+	h.Write([]byte(text))
+	return fmt.Sprintf("%08x", h.Sum32())
+}
+
+// Texts returns the facts of records in order.
+func Texts(records []Record) []string {
+	texts := make([]string, len(records))
+	for i, rec := range records {
+		texts[i] = rec.Text
+	}
+	return texts
+}
+
+// Labels returns the facts of records in order, each prefixed with its
+// bracketed ID so the LLM can name it in MEMORY_FORGET and MEMORY_UPDATE,
+// and user and branch facts followed by their scope.
+func Labels(records []Record) []string {
+	labels := make([]string, len(records))
+	for i, rec := range records {
+		labels[i] = rec.Text
+		if rec.ID != "" {
+			labels[i] = "[" + rec.ID + "] " + rec.Text
+		}
+		if rec.Scope == ScopeUser || rec.Scope == ScopeBranch {
+			labels[i] += " (" + string(rec.Scope) + ")"
+		}
+	}
+	return labels
+}
+
+// MarkUsed returns a copy of records in which those with the ID of a record
+// in used have LastUsedAt set to now.
+func MarkUsed(records, used []Record, now time.Time) []Record {
+	ids := make(map[string]bool, len(used))
+	for _, rec := range used {
+		ids[rec.ID] = true
+	}
+	out := append([]Record(nil), records...)
+	for i := range out {
+		if ids[out[i].ID] {
+			out[i].LastUsedAt = now.UTC()
+		}
+	}
+	return out
+}
+
+// LoadMemory reads the memory file from workDir and returns the stored records.
 // If the file does not exist it returns nil, nil (SaveMemory creates the file).
-func LoadMemory(workDir string) ([]string, error) {
-	path := filepath.Join(workDir, FileName)
+// A plain array of facts from before records is migrated: each fact gets an
+// ID and the file's modification time, and SaveMemory rewrites it as records.
+func LoadMemory(workDir string) ([]Record, error) {
+	records, err := loadFile(filepath.Join(workDir, FileName))
+	if err != nil {
+		return nil, fmt.Errorf("LoadMemory: %w", err)
+	}
+	return records, nil
+}
+
+// SaveMemory writes the records to the memory file in workDir.
+func SaveMemory(workDir string, records []Record) error {
+	if err := saveFile(filepath.Join(workDir, FileName), records); err != nil {
+		return fmt.Errorf("SaveMemory: %w", err)
+	}
+	return nil
+}
+
+// loadFile reads and migrates the memory file at path; see LoadMemory.
+func loadFile(path string) ([]Record, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return nil, nil
 		}
-		return nil, fmt.Errorf("LoadMemory: %w", err)
+		return nil, err
+	}
+	var learned time.Time
+	if info, err := os.Stat(path); err == nil {
+		learned = info.ModTime().UTC()
+	}
+	return decode(data, learned)
+}
+
+// decode parses records in the versioned format or the older plain array of
+// facts. Records without an ID, or with one an earlier record has, get one
+// derived from their text and position among the records sharing it, so
+// loading the same old file twice gives the same IDs. Records without
+// timestamps are dated learned.
+func decode(data []byte, learned time.Time) ([]Record, error) {
+	var f file
+	var err error
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		err = json.Unmarshal(trimmed, &f.Records)
+	} else {
+		err = json.Unmarshal(data, &f)
+		if err == nil && f.Version > Version {
+			return nil, fmt.Errorf("unsupported version %d", f.Version)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal: %w", err)
+	}
+	seen := make(map[string]bool, len(f.Records))
+	for i := range f.Records {
+		rec := &f.Records[i]
+		if rec.ID == "" {
+			rec.ID = textID(rec.Text)
+		}
+		for n := 2; seen[rec.ID]; n++ {
+			rec.ID = textID(fmt.Sprintf("%s\x00%d", rec.Text, n))
+		}
+		seen[rec.ID] = true
+		if rec.CreatedAt.IsZero() {
+			rec.CreatedAt = learned
+		}
+		if rec.LastUsedAt.IsZero() {
+			rec.LastUsedAt = rec.CreatedAt
+		}
+	}
+	return f.Records, nil
+}
+
+// Export writes records, with their scopes, in the memory file format.
+func Export(w io.Writer, records []Record) error {
+	if records == nil {
+		records = []Record{}
+	}
+	data, err := json.MarshalIndent(file{Version: Version, Records: records}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Export: marshal: %w", err)
 	}
-	var facts []string
-	if err := json.Unmarshal(data, &facts); err != nil {
-		return nil, fmt.Errorf("LoadMemory: unmarshal: %w", err)
+	if _, err := w.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("Export: %w", err)
+	}
+	return nil
+}
+
+// Import reads records written by Export, or any memory file including the
+// older plain array of facts. Facts without a creation time are dated now.
+func Import(r io.Reader) ([]Record, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("Import: %w", err)
+	}
+	records, err := decode(data, time.Now().UTC())
+	if err != nil {
+		return nil, fmt.Errorf("Import: %w", err)
+	}
+	return records, nil
+}
+
+// Merge adds imported records to records and returns the result and how
+// many facts were added. A record whose ID and text are already present is
+// skipped; one whose ID is taken by another fact gets a new ID; one whose
+// text is already in its scope merges into that record as a hit.
+func Merge(records, imported []Record) ([]Record, int) {
+	byID := make(map[string]Record, len(records))
+	for _, rec := range records {
+		byID[rec.ID] = rec
+	}
+	out := append([]Record(nil), records...)
+	for _, rec := range imported {
+		if have, ok := byID[rec.ID]; ok {
+			if have.Text == rec.Text && have.Scope == rec.Scope {
+				continue
+			}
+			for ok {
+				rec.ID = newID()
+				_, ok = byID[rec.ID]
+			}
+		}
+		byID[rec.ID] = rec
+		out = append(out, rec)
 	}
-	return facts, nil
+	merged := DeduplicateMemory(out)
+	return merged, max(0, len(merged)-len(records))
 }
 
-// SaveMemory writes the facts to the memory file in workDir.
-func SaveMemory(workDir string, facts []string) error {
-	if facts == nil {
-		facts = []string{}
+// saveFile writes records to the memory file at path; the file's location
+// implies their scope, so it is not written.
+func saveFile(path string, records []Record) error {
+	f := file{Version: Version, Records: make([]Record, len(records))}
+	for i, rec := range records {
+		rec.Scope = ""
+		f.Records[i] = rec
 	}
-	data, err := json.MarshalIndent(facts, "", "  ")
+	data, err := json.MarshalIndent(f, "", "  ")
 	if err != nil {
-		return fmt.Errorf("SaveMemory: marshal: %w", err)
+		return fmt.Errorf("marshal: %w", err)
 	}
-	path := filepath.Join(workDir, FileName)
-	if err := os.WriteFile(path, data, 0644); err != nil {
-		return fmt.Errorf("SaveMemory: write: %w", err)
+	// Write a temporary file and rename it over the old one, so a crash or a
+	// concurrent reader never sees a partly written file.
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("write: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write: %w", err)
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return fmt.Errorf("chmod: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("sync: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("close: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("rename: %w", err)
 	}
 	return nil
 }
 
+// Fact is a fact from a MEMORY_SAVE line and the scope it targets.
+type Fact struct {
+	Text  string
+	Scope Scope
+}
+
+// saveLine matches "MEMORY_SAVE: <text>" and "MEMORY_SAVE[<scope>]: <text>".
+var saveLine = regexp.MustCompile(`^MEMORY_SAVE(?:\[([^\]]*)\])?:(.*)$`)
+
 // ExtractMemorySaves scans the LLM reply for lines matching "MEMORY_SAVE: <text>",
 // collects them as new facts, and returns the cleaned reply with those lines removed.
-func ExtractMemorySaves(reply string) ([]string, string) {
-	var facts []string
+// "MEMORY_SAVE[user]: <text>" or "MEMORY_SAVE[branch]: <text>" targets another
+// scope; an unknown scope saves to the project.
+func ExtractMemorySaves(reply string) ([]Fact, string) {
+	var facts []Fact
 	var kept []string
 	for _, line := range strings.Split(reply, "\n") {
-		trimmed := strings.TrimSpace(line)
-		if after, ok := strings.CutPrefix(trimmed, "MEMORY_SAVE:"); ok {
-			fact := strings.TrimSpace(after)
-			if fact != "" {
-				facts = append(facts, fact)
-			}
-		} else {
+		m := saveLine.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
 			kept = append(kept, line)
+			continue
+		}
+		if text := strings.TrimSpace(m[2]); text != "" {
+			scope, err := ParseScope(m[1])
+			if err != nil {
+				scope = ScopeProject
+			}
+			facts = append(facts, Fact{Text: text, Scope: scope})
 		}
 	}
 	return facts, strings.Join(kept, "\n")
 }
 
-// DeduplicateMemory returns facts with duplicates removed, preserving order.
-func DeduplicateMemory(facts []string) []string {
-	seen := make(map[string]bool, len(facts))
-	var out []string
-	for _, f := range facts {
-		if !seen[f] {
-			seen[f] = true
-			out = append(out, f)
+// DeduplicateMemory returns records with facts of the same text and scope
+// merged, preserving order. Each duplicate counts as a hit on the first
+// record, which keeps the earliest creation and the latest use and gains the
+// duplicate's tags. The same fact in two scopes is kept in both (see Visible).
+func DeduplicateMemory(records []Record) []Record {
+	type key struct {
+		scope Scope
+		text  string
+	}
+	index := make(map[key]int, len(records))
+	var out []Record
+	for _, rec := range records {
+		k := key{rec.Scope, rec.Text}
+		i, ok := index[k]
+		if !ok {
+			index[k] = len(out)
+			out = append(out, rec)
+			continue
+		}
+		kept := &out[i]
+		kept.Hits += rec.Hits + 1
+		if rec.CreatedAt.Before(kept.CreatedAt) {
+			kept.CreatedAt = rec.CreatedAt
+		}
+		if rec.LastUsedAt.After(kept.LastUsedAt) {
+			kept.LastUsedAt = rec.LastUsedAt
+		}
+		for _, tag := range rec.Tags {
+			if !slices.Contains(kept.Tags, tag) {
+				kept.Tags = append(kept.Tags, tag)
+			}
 		}
 	}
 	return out
 }
 
-// CompactMemory asks the LLM (via the provided callback) to consolidate a list of facts into a shorter list.
-// On failure it returns the original facts unchanged.
-func CompactMemory(fn CompactFunc, facts []string) ([]string, error) {
-	factsJSON, err := json.Marshal(facts)
+// CompactMemory asks the LLM (via the provided callback) to consolidate the
+// facts of records into a shorter list. A fact the LLM keeps verbatim keeps
+// its record; a new one gets a fresh record tagged "compacted" with no hits
+// that inherits the earliest creation time and the latest use of the inputs.
+// Records should share a scope; new ones take the scope of the last input.
+// On failure it returns the original records unchanged.
+func CompactMemory(fn CompactFunc, records []Record) ([]Record, error) {
+	factsJSON, err := json.Marshal(Texts(records))
 	if err != nil {
-		return facts, nil
+		return records, nil
 	}
 	prompt := fmt.Sprintf(`You are a memory compaction assistant. Below is a JSON array of facts from previous sessions. Consolidate them into a shorter list:
 - Merge duplicate or near-duplicate entries
@@ -104,7 +386,7 @@ Facts:
 
 	reply, err := fn(prompt)
 	if err != nil {
-		return facts, fmt.Errorf("CompactMemory: %w", err)
+		return records, fmt.Errorf("CompactMemory: %w", err)
 	}
 
 	// Extract JSON array from the reply (handle possible markdown fences).
@@ -117,10 +399,33 @@ Facts:
 
 	var compacted []string
 	if err := json.Unmarshal([]byte(cleaned), &compacted); err != nil {
-		return facts, fmt.Errorf("CompactMemory: parse response: %w", err)
+		return records, fmt.Errorf("CompactMemory: parse response: %w", err)
 	}
 	if len(compacted) == 0 {
-		return facts, nil
+		return records, nil
+	}
+
+	byText := make(map[string]Record, len(records))
+	var merged Record
+	for i, rec := range records {
+		byText[rec.Text] = rec
+		merged.Scope = rec.Scope
+		if i == 0 || rec.CreatedAt.Before(merged.CreatedAt) {
+			merged.CreatedAt = rec.CreatedAt
+		}
+		if i == 0 || rec.LastUsedAt.After(merged.LastUsedAt) {
+			merged.LastUsedAt = rec.LastUsedAt
+		}
+	}
+	out := make([]Record, 0, len(compacted))
+	for _, text := range compacted {
+		if rec, ok := byText[text]; ok {
+			out = append(out, rec)
+			continue
+		}
+		rec := merged
+		rec.ID, rec.Text, rec.Tags = newID(), text, []string{"compacted"}
+		out = append(out, rec)
 	}
-	return compacted, nil
+	return DeduplicateMemory(out), nil
 }
@@ -6,8 +6,18 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
+// records returns records for the facts, as a run would save them.
+func records(facts ...string) []Record {
+	out := make([]Record, len(facts))
+	for i, f := range facts {
+		out[i] = NewRecord(f, "run-1", "test-model")
+	}
+	return out
+}
+
 // LoadMemory returns nil, nil when the file does not exist.
 func TestLoadMemory_MissingFile(t *testing.T) {
 	dir := t.TempDir()
@@ -23,7 +33,9 @@ func TestLoadMemory_MissingFile(t *testing.T) {
 // LoadMemory reads a valid memory.json file.
 func TestLoadMemory_ValidFile(t *testing.T) {
 	dir := t.TempDir()
-	data := `["fact one", "fact two"]`
+	data := `{"version": 1, "records": [
+		{"id": "a1", "text": "fact one", "created_at": "2026-01-02T03:04:05Z", "source_run": "r1", "tags": ["go"], "hits": 2},
+		{"id": "b2", "text": "fact two"}]}`
 	if err := os.WriteFile(filepath.Join(dir, "memory.json"), []byte(data), 0644); err != nil {
 		t.Fatalf("write: %v", err)
 	}
@@ -31,8 +43,87 @@ func TestLoadMemory_ValidFile(t *testing.T) {
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
-	if len(facts) != 2 || facts[0] != "fact one" || facts[1] != "fact two" {
-		t.Fatalf("unexpected facts: %v", facts)
+	if len(facts) != 2 || facts[0].Text != "fact one" || facts[1].Text != "fact two" {
+		t.Fatalf("unexpected facts: %+v", facts)
+	}
+	if facts[0].ID != "a1" || facts[0].SourceRun != "r1" || facts[0].Hits != 2 || facts[0].Tags[0] != "go" ||
+		!facts[0].CreatedAt.Equal(time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Fatalf("record fields not loaded: %+v", facts[0])
+	}
+}
+
+// Records with a missing or repeated ID get the same derived IDs on every load.
+func TestLoadMemory_StableIDsForDuplicates(t *testing.T) {
+	dir := t.TempDir()
+	data := `{"version":1,"records":[{"id":"a1","text":"fact one"},{"id":"a1","text":"fact two"},{"text":"fact two"},{"text":"fact two"}]}`
+	if err := os.WriteFile(filepath.Join(dir, "memory.json"), []byte(data), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	facts, err := LoadMemory(dir)
+	if err != nil || len(facts) != 4 {
+		t.Fatalf("LoadMemory: %+v %v", facts, err)
+	}
+	ids := map[string]bool{}
+	for _, rec := range facts {
+		ids[rec.ID] = true
+	}
+	if len(ids) != 4 || facts[0].ID != "a1" {
+		t.Fatalf("expected unique IDs keeping the first a1: %+v", facts)
+	}
+	again, _ := LoadMemory(dir)
+	for i := range facts {
+		if again[i].ID != facts[i].ID {
+			t.Fatalf("IDs should be stable across loads: %+v then %+v", facts, again)
+		}
+	}
+}
+
+// LoadMemory migrates a plain array of facts to records with IDs and the
+// file's modification time, and SaveMemory then writes the versioned format.
+func TestLoadMemory_MigratesPlainArray(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "memory.json")
+	if err := os.WriteFile(path, []byte(`["fact one", "fact two"]`), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	learned := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(path, learned, learned); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+	facts, err := LoadMemory(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(facts) != 2 || facts[0].Text != "fact one" || facts[1].Text != "fact two" {
+		t.Fatalf("unexpected facts: %+v", facts)
+	}
+	if facts[0].ID == "" || facts[0].ID == facts[1].ID || !facts[0].CreatedAt.Equal(learned) || !facts[0].LastUsedAt.Equal(learned) {
+		t.Fatalf("migrated records need unique IDs and the file time: %+v", facts)
+	}
+	if again, _ := LoadMemory(dir); again[0].ID != facts[0].ID || again[1].ID != facts[1].ID {
+		t.Fatalf("IDs should be stable across loads: %+v then %+v", facts, again)
+	}
+
+	if err := SaveMemory(dir, facts); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if !strings.Contains(string(data), `"version": 1`) || !strings.Contains(string(data), `"id": "`+facts[0].ID+`"`) {
+		t.Fatalf("expected versioned records, got: %s", data)
+	}
+}
+
+// LoadMemory rejects files written by a newer format version.
+func TestLoadMemory_NewerVersion(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "memory.json"), []byte(`{"version": 99, "records": []}`), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if _, err := LoadMemory(dir); err == nil || !strings.Contains(err.Error(), "version 99") {
+		t.Fatalf("expected unsupported version error, got %v", err)
 	}
 }
 
@@ -51,7 +142,7 @@ func TestLoadMemory_InvalidJSON(t *testing.T) {
 // SaveMemory writes facts to memory.json and they can be read back.
 func TestSaveMemory_RoundTrip(t *testing.T) {
 	dir := t.TempDir()
-	facts := []string{"fact A", "fact B"}
+	facts := records("fact A", "fact B")
 	if err := SaveMemory(dir, facts); err != nil {
 		t.Fatalf("save: %v", err)
 	}
@@ -59,12 +150,15 @@ func TestSaveMemory_RoundTrip(t *testing.T) {
 	if err != nil {
 		t.Fatalf("load: %v", err)
 	}
-	if len(loaded) != 2 || loaded[0] != "fact A" || loaded[1] != "fact B" {
-		t.Fatalf("round-trip mismatch: %v", loaded)
+	if len(loaded) != 2 || loaded[0].Text != "fact A" || loaded[1].Text != "fact B" {
+		t.Fatalf("round-trip mismatch: %+v", loaded)
+	}
+	if loaded[0].ID != facts[0].ID || loaded[0].SourceRun != "run-1" || loaded[0].Model != "test-model" || !loaded[0].CreatedAt.Equal(facts[0].CreatedAt) {
+		t.Fatalf("provenance lost: %+v", loaded[0])
 	}
 }
 
-// SaveMemory with nil writes an empty array.
+// SaveMemory with nil writes an empty record array.
 func TestSaveMemory_NilWritesEmptyArray(t *testing.T) {
 	dir := t.TempDir()
 	if err := SaveMemory(dir, nil); err != nil {
@@ -74,7 +168,7 @@ func TestSaveMemory_NilWritesEmptyArray(t *testing.T) {
 	if err != nil {
 		t.Fatalf("read: %v", err)
 	}
-	if strings.TrimSpace(string(data)) != "[]" {
+	if !strings.Contains(string(data), `"records": []`) {
 		t.Fatalf("expected empty JSON array, got: %s", data)
 	}
 }
@@ -86,7 +180,7 @@ func TestExtractMemorySaves_Basic(t *testing.T) {
 	if len(facts) != 2 {
 		t.Fatalf("expected 2 facts, got %d: %v", len(facts), facts)
 	}
-	if facts[0] != "project uses Go 1.23" || facts[1] != "no external deps" {
+	if facts[0].Text != "project uses Go 1.23" || facts[1].Text != "no external deps" || facts[0].Scope != ScopeProject {
 		t.Fatalf("unexpected facts: %v", facts)
 	}
 	if strings.Contains(cleaned, "MEMORY_SAVE") {
@@ -97,6 +191,21 @@ func TestExtractMemorySaves_Basic(t *testing.T) {
 	}
 }
 
+// ExtractMemorySaves reads the scope a MEMORY_SAVE line targets.
+func TestExtractMemorySaves_Scopes(t *testing.T) {
+	reply := "MEMORY_SAVE[user]: I use conventional commits\nMEMORY_SAVE[Branch]: this branch targets v2\nMEMORY_SAVE[team]: unknown scope\nMEMORY_SAVE[]: plain"
+	facts, cleaned := ExtractMemorySaves(reply)
+	want := []Fact{{"I use conventional commits", ScopeUser}, {"this branch targets v2", ScopeBranch}, {"unknown scope", ScopeProject}, {"plain", ScopeProject}}
+	if len(facts) != len(want) || cleaned != "" {
+		t.Fatalf("got %+v, cleaned %q", facts, cleaned)
+	}
+	for i := range want {
+		if facts[i] != want[i] {
+			t.Fatalf("index %d: got %+v, want %+v", i, facts[i], want[i])
+		}
+	}
+}
+
 // ExtractMemorySaves returns no facts when none present.
 func TestExtractMemorySaves_NoFacts(t *testing.T) {
 	reply := "just a normal reply\nwith multiple lines"
@@ -120,8 +229,8 @@ func TestExtractMemorySaves_EmptyFact(t *testing.T) {
 
 // DeduplicateMemory removes duplicates preserving order.
 func TestDeduplicateMemory(t *testing.T) {
-	facts := []string{"a", "b", "a", "c", "b", "d"}
-	got := DeduplicateMemory(facts)
+	facts := records("a", "b", "a", "c", "b", "d")
+	got := Texts(DeduplicateMemory(facts))
 	want := []string{"a", "b", "c", "d"}
 	if len(got) != len(want) {
 		t.Fatalf("got %v, want %v", got, want)
@@ -133,19 +242,52 @@ func TestDeduplicateMemory(t *testing.T) {
 	}
 }
 
+// A duplicate counts as a hit on the original record, which keeps its ID and
+// creation time but takes the later use and the duplicate's tags.
+func TestDeduplicateMemory_MergesProvenance(t *testing.T) {
+	first := Record{ID: "a1", Text: "fact", CreatedAt: time.Unix(100, 0), LastUsedAt: time.Unix(100, 0), Hits: 1}
+	again := Record{ID: "b2", Text: "fact", CreatedAt: time.Unix(200, 0), LastUsedAt: time.Unix(300, 0), Tags: []string{"build"}}
+	got := DeduplicateMemory([]Record{first, again})
+	if len(got) != 1 || got[0].ID != "a1" || got[0].Hits != 2 || got[0].CreatedAt.Unix() != 100 ||
+		got[0].LastUsedAt.Unix() != 300 || len(got[0].Tags) != 1 {
+		t.Fatalf("unexpected merge: %+v", got)
+	}
+}
+
 // CompactMemory parses a valid JSON array from the callback response.
 func TestCompactMemory_Success(t *testing.T) {
 	fn := func(prompt string) (string, error) {
 		return `["consolidated fact 1", "consolidated fact 2"]`, nil
 	}
 
-	facts := []string{"fact 1", "fact 2", "fact 1 duplicate", "fact 3"}
+	facts := records("fact 1", "fact 2", "fact 1 duplicate", "fact 3")
+	facts[0].Hits, facts[2].Hits = 3, 2
 	compacted, err := CompactMemory(fn, facts)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
-	if len(compacted) != 2 || compacted[0] != "consolidated fact 1" {
-		t.Fatalf("unexpected compacted facts: %v", compacted)
+	if len(compacted) != 2 || compacted[0].Text != "consolidated fact 1" {
+		t.Fatalf("unexpected compacted facts: %+v", compacted)
+	}
+	if compacted[0].ID == "" || len(compacted[0].Tags) != 1 || compacted[0].Tags[0] != "compacted" ||
+		!compacted[0].CreatedAt.Equal(facts[0].CreatedAt) || compacted[0].Hits != 0 {
+		t.Fatalf("compacted fact should be a new tagged record: %+v", compacted[0])
+	}
+}
+
+// CompactMemory keeps the record of a fact the LLM returns verbatim.
+func TestCompactMemory_KeepsUnchangedRecords(t *testing.T) {
+	fn := func(prompt string) (string, error) {
+		return `["fact 2", "fact 1 and 3"]`, nil
+	}
+
+	facts := records("fact 1", "fact 2", "fact 3")
+	compacted, err := CompactMemory(fn, facts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(compacted) != 2 || compacted[0].ID != facts[1].ID || compacted[0].SourceRun != "run-1" || compacted[1].ID == facts[0].ID {
+		t.Fatalf("unexpected compacted records: %+v", compacted)
 	}
 }
 
@@ -155,12 +297,12 @@ func TestCompactMemory_APIError(t *testing.T) {
 		return "", fmt.Errorf("server error")
 	}
 
-	facts := []string{"fact A", "fact B"}
+	facts := records("fact A", "fact B")
 	got, err := CompactMemory(fn, facts)
 	if err == nil {
 		t.Fatal("expected error from CompactMemory")
 	}
-	if len(got) != 2 || got[0] != "fact A" {
+	if len(got) != 2 || got[0].Text != "fact A" {
 		t.Fatalf("expected original facts on error, got: %v", got)
 	}
 }
@@ -171,11 +313,38 @@ func TestCompactMemory_MarkdownFences(t *testing.T) {
 		return "```json\n[\"fact\"]\n```", nil
 	}
 
-	got, err := CompactMemory(fn, []string{"old fact"})
+	got, err := CompactMemory(fn, records("old fact"))
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
-	if len(got) != 1 || got[0] != "fact" {
+	if len(got) != 1 || got[0].Text != "fact" {
 		t.Fatalf("unexpected result: %v", got)
 	}
 }
+
+// Export and Import round-trip records with their scopes.
+func TestExportImport(t *testing.T) {
+	facts := records("a", "b")
+	facts[1].Scope = ScopeUser
+	var buf strings.Builder
+	if err := Export(&buf, facts); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	got, err := Import(strings.NewReader(buf.String()))
+	if err != nil || len(got) != 2 || got[0].ID != facts[0].ID || got[1].Scope != ScopeUser {
+		t.Fatalf("Import: %+v %v", got, err)
+	}
+	if _, err := Import(strings.NewReader("nope")); err == nil {
+		t.Fatal("expected error for invalid input")
+	}
+}
+
+// Merge skips records it already has and renumbers clashing IDs.
+func TestMerge(t *testing.T) {
+	have := []Record{{ID: "a1", Text: "a"}, {ID: "b2", Text: "b"}}
+	imported := []Record{{ID: "a1", Text: "a"}, {ID: "b2", Text: "c"}, {ID: "d4", Text: "b"}}
+	got, added := Merge(have, imported)
+	if added != 1 || len(got) != 3 || got[2].Text != "c" || got[2].ID == "b2" || got[1].Hits != 1 {
+		t.Fatalf("Merge = %+v, %d", got, added)
+	}
+}
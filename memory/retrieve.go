@@ -0,0 +1,123 @@
+package memory
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// TopK is how many facts Retrieve puts in a prompt; overridden via MEMORY_TOP_K.
+var TopK = 20
+
+// BM25 parameters: k1 limits how much repeating a term helps, b how much
+// longer facts are penalized.
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// stopWords are common English words that say nothing about relevance.
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true,
+	"by": true, "do": true, "for": true, "from": true, "in": true, "is": true, "it": true,
+	"not": true, "of": true, "on": true, "or": true, "that": true, "the": true, "this": true,
+	"to": true, "use": true, "uses": true, "with": true,
+}
+
+// tokenize splits text into lowercase words and numbers for retrieval,
+// dropping stop words and a plural "s" so "tests" matches "test".
+func tokenize(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	tokens := words[:0]
+	for _, w := range words {
+		if stopWords[w] {
+			continue
+		}
+		if len(w) > 3 && strings.HasSuffix(w, "s") && !strings.HasSuffix(w, "ss") {
+			w = w[:len(w)-1]
+		}
+		tokens = append(tokens, w)
+	}
+	return tokens
+}
+
+// Scores returns the BM25 relevance of each record's text to query, indexed
+// like records. Facts sharing no term with query score 0.
+func Scores(records []Record, query string) []float64 {
+	scores := make([]float64, len(records))
+	terms := tokenize(query)
+	if len(records) == 0 || len(terms) == 0 {
+		return scores
+	}
+
+	docs := make([]map[string]int, len(records))
+	lengths := make([]int, len(records))
+	docFreq := make(map[string]int)
+	total := 0
+	for i, rec := range records {
+		tokens := tokenize(rec.Text)
+		docs[i] = make(map[string]int, len(tokens))
+		for _, tok := range tokens {
+			if docs[i][tok] == 0 {
+				docFreq[tok]++
+			}
+			docs[i][tok]++
+		}
+		lengths[i] = len(tokens)
+		total += len(tokens)
+	}
+	avgLen := max(float64(total)/float64(len(records)), 1)
+
+	n := float64(len(records))
+	seen := make(map[string]bool, len(terms))
+	for _, term := range terms {
+		if seen[term] || docFreq[term] == 0 {
+			continue
+		}
+		seen[term] = true
+		df := float64(docFreq[term])
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		for i, doc := range docs {
+			tf := float64(doc[term])
+			if tf == 0 {
+				continue
+			}
+			norm := 1 - bm25B + bm25B*float64(lengths[i])/avgLen
+			scores[i] += idf * tf * (bm25K1 + 1) / (tf + bm25K1*norm)
+		}
+	}
+	return scores
+}
+
+// Retrieve returns the k records most relevant to query, best first. Ties,
+// including facts unrelated to query, go to the more confirmed and then the
+// more recently used fact. When records holds at most k facts (or k <= 0)
+// all of them are returned in their stored order.
+func Retrieve(records []Record, query string, k int) []Record {
+	if k <= 0 || len(records) <= k {
+		return records
+	}
+	scores := Scores(records, query)
+	order := make([]int, len(records))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		ra, rb := records[order[a]], records[order[b]]
+		if sa, sb := scores[order[a]], scores[order[b]]; sa != sb {
+			return sa > sb
+		}
+		if ra.Hits != rb.Hits {
+			return ra.Hits > rb.Hits
+		}
+		return ra.LastUsedAt.After(rb.LastUsedAt)
+	})
+	out := make([]Record, k)
+	for i, idx := range order[:k] {
+		out[i] = records[idx]
+	}
+	return out
+}
@@ -0,0 +1,63 @@
+package memory
+
+import (
+	"testing"
+	"time"
+)
+
+// Scores ranks facts sharing rarer query terms higher and gives unrelated facts 0.
+func TestScores(t *testing.T) {
+	facts := records(
+		"run make test before committing",
+		"the dashboard uses server-sent events",
+		"the CI pipeline runs make lint",
+		"prefer table-driven tests",
+	)
+	scores := Scores(facts, "Fix the failing dashboard events test")
+	if scores[2] != 0 {
+		t.Fatalf("fact sharing only stop words should score 0, got %v", scores)
+	}
+	if scores[1] <= scores[0] || scores[0] <= 0 || scores[3] <= 0 {
+		t.Fatalf("dashboard fact should rank first and plurals should match: %v", scores)
+	}
+	if got := Scores(facts, "  "); got[0] != 0 || got[1] != 0 {
+		t.Fatalf("empty query should score 0: %v", got)
+	}
+}
+
+// Retrieve returns the k best facts, breaking ties by hits and recent use.
+func TestRetrieve_TopK(t *testing.T) {
+	facts := records("go modules are vendored", "deploys use terraform", "use gofmt", "docs live in docs/")
+	facts[3].Hits = 5
+	facts[2].LastUsedAt = time.Now().Add(time.Hour)
+	got := Texts(Retrieve(facts, "update terraform deploys", 3))
+	want := []string{"deploys use terraform", "docs live in docs/", "use gofmt"}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("index %d: got %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+// Retrieve keeps a small store whole and in order, and k <= 0 disables it.
+func TestRetrieve_SmallStore(t *testing.T) {
+	facts := records("b fact", "a fact about tests")
+	for _, k := range []int{0, 2, 10} {
+		if got := Retrieve(facts, "tests", k); len(got) != 2 || got[0].Text != "b fact" {
+			t.Fatalf("k=%d: got %+v", k, got)
+		}
+	}
+}
+
+// MarkUsed stamps only the records that were used.
+func TestMarkUsed(t *testing.T) {
+	facts := records("a", "b")
+	now := time.Now().Add(time.Hour)
+	got := MarkUsed(facts, facts[1:], now)
+	if !got[1].LastUsedAt.Equal(now) || got[0].LastUsedAt.Equal(now) || facts[1].LastUsedAt.Equal(now) {
+		t.Fatalf("unexpected marks: %+v", got)
+	}
+}
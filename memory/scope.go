@@ -0,0 +1,222 @@
+package memory
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Scope is a layer of memory. Narrower scopes take precedence: a branch fact
+// overrides a project fact, which overrides a user fact.
+type Scope string
+
+const (
+	ScopeUser    Scope = "user"    // every project of the user
+	ScopeProject Scope = "project" // one working directory
+	ScopeBranch  Scope = "branch"  // one git branch of a project
+)
+
+// Scopes lists the scopes from narrowest to broadest, the order in which
+// Store.Load returns their records.
+var Scopes = []Scope{ScopeBranch, ScopeProject, ScopeUser}
+
+// BranchDir is the directory, inside the project directory, that holds one
+// memory file per branch.
+const BranchDir = "memory-branches"
+
+// ParseScope parses a scope name; an empty name is the project scope.
+func ParseScope(name string) (Scope, error) {
+	switch s := Scope(strings.ToLower(strings.TrimSpace(name))); s {
+	case "":
+		return ScopeProject, nil
+	case ScopeUser, ScopeProject, ScopeBranch:
+		return s, nil
+	}
+	return "", fmt.Errorf("ParseScope: unknown memory scope %q (want user, project or branch)", name)
+}
+
+// UserDir returns the default directory of user memory,
+// $XDG_CONFIG_HOME/agent-orchestrator (~/.config/agent-orchestrator), or ""
+// when there is no home directory.
+func UserDir() string {
+	config := os.Getenv("XDG_CONFIG_HOME")
+	if config == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		config = filepath.Join(home, ".config")
+	}
+	return filepath.Join(config, "agent-orchestrator")
+}
+
+// Store locates the memory file of each scope. An empty UserDir or Branch
+// disables that scope; the project scope is always on.
+type Store struct {
+	UserDir    string
+	ProjectDir string
+	Branch     string
+}
+
+// Path returns the memory file of scope, or "" when the scope is disabled.
+func (s Store) Path(scope Scope) string {
+	switch scope {
+	case ScopeUser:
+		if s.UserDir != "" {
+			return filepath.Join(s.UserDir, FileName)
+		}
+	case ScopeProject, "":
+		return filepath.Join(s.ProjectDir, FileName)
+	case ScopeBranch:
+		if s.Branch != "" {
+			return filepath.Join(s.ProjectDir, BranchDir, url.PathEscape(s.Branch)+".json")
+		}
+	}
+	return ""
+}
+
+// Resolve returns scope if it is enabled and the project scope otherwise.
+func (s Store) Resolve(scope Scope) Scope {
+	if scope == "" || s.Path(scope) == "" {
+		return ScopeProject
+	}
+	return scope
+}
+
+// Load reads the memory file of every enabled scope and returns their
+// records, narrowest scope first, each with Scope set. Missing files are
+// empty scopes.
+func (s Store) Load() ([]Record, error) {
+	var all []Record
+	for _, scope := range Scopes {
+		path := s.Path(scope)
+		if path == "" {
+			continue
+		}
+		records, err := loadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("Store.Load: %s: %w", scope, err)
+		}
+		for i := range records {
+			records[i].Scope = scope
+		}
+		all = append(all, records...)
+	}
+	return all, nil
+}
+
+// saveMu serializes Store.Save within the process, so parallel runs sharing
+// a memory file merge their changes one after the other.
+var saveMu sync.Mutex
+
+// Save writes the changes from base, the records as loaded, to records into
+// the files of their scopes. The files are read again first and only the
+// caller's additions, removals and edits, matched by record ID, are applied
+// to them (see rebase), so facts another run saved meanwhile are kept.
+// Records of a disabled scope go to the project. A scope file is only
+// written when it has records or already exists, so forgetting its last fact
+// empties it. Each file is replaced atomically.
+func (s Store) Save(base, records []Record) error {
+	saveMu.Lock()
+	defer saveMu.Unlock()
+	disk, err := s.Load()
+	if err != nil {
+		return fmt.Errorf("Store.Save: %w", err)
+	}
+	byScope := make(map[Scope][]Record)
+	for _, rec := range rebase(disk, base, s.resolveAll(records)) {
+		byScope[rec.Scope] = append(byScope[rec.Scope], rec)
+	}
+	for _, scope := range Scopes {
+		path := s.Path(scope)
+		if path == "" {
+			continue
+		}
+		if _, err := os.Stat(path); len(byScope[scope]) == 0 && err != nil {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return fmt.Errorf("Store.Save: %s: %w", scope, err)
+		}
+		if err := saveFile(path, byScope[scope]); err != nil {
+			return fmt.Errorf("Store.Save: %s: %w", scope, err)
+		}
+	}
+	return nil
+}
+
+// resolveAll returns records with each scope resolved (see Resolve).
+func (s Store) resolveAll(records []Record) []Record {
+	out := make([]Record, len(records))
+	for i, rec := range records {
+		rec.Scope = s.Resolve(rec.Scope)
+		out[i] = rec
+	}
+	return out
+}
+
+// rebase applies the changes from base to records onto disk, the records
+// currently stored, matching them by ID. Records added since base are
+// appended, records removed since base are dropped and records changed since
+// base replace their stored version; a changed fact that kept its text adds
+// its new hits to the stored ones. Stored records the caller never saw, or
+// did not change, are kept as stored, and facts another run removed stay
+// removed. Facts added by both end up merged by DeduplicateMemory.
+func rebase(disk, base, records []Record) []Record {
+	before := make(map[string]Record, len(base))
+	for _, rec := range base {
+		before[rec.ID] = rec
+	}
+	after := make(map[string]Record, len(records))
+	for _, rec := range records {
+		after[rec.ID] = rec
+	}
+
+	out := make([]Record, 0, len(disk)+len(records))
+	stored := make(map[string]bool, len(disk))
+	for _, rec := range disk {
+		stored[rec.ID] = true
+		old, known := before[rec.ID]
+		now, kept := after[rec.ID]
+		switch {
+		case known && !kept:
+			continue // removed by the caller
+		case kept && !known:
+			rec = now
+		case kept && !reflect.DeepEqual(old, now):
+			if now.Text == old.Text {
+				now.Hits = max(0, rec.Hits+now.Hits-old.Hits)
+				if rec.LastUsedAt.After(now.LastUsedAt) {
+					now.LastUsedAt = rec.LastUsedAt
+				}
+			}
+			rec = now
+		}
+		out = append(out, rec)
+	}
+	for _, rec := range records {
+		if _, known := before[rec.ID]; !known && !stored[rec.ID] {
+			out = append(out, rec)
+		}
+	}
+	return DeduplicateMemory(out)
+}
+
+// Visible returns records without those shadowed by the same fact in a
+// narrower or the same scope earlier in records, as ordered by Store.Load.
+func Visible(records []Record) []Record {
+	seen := make(map[string]bool, len(records))
+	var out []Record
+	for _, rec := range records {
+		key := normalize(rec.Text)
+		if !seen[key] {
+			seen[key] = true
+			out = append(out, rec)
+		}
+	}
+	return out
+}
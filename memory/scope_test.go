@@ -0,0 +1,144 @@
+package memory
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// ParseScope accepts the three scope names in any case and defaults to project.
+func TestParseScope(t *testing.T) {
+	for name, want := range map[string]Scope{"": ScopeProject, "USER": ScopeUser, " branch ": ScopeBranch, "project": ScopeProject} {
+		if got, err := ParseScope(name); err != nil || got != want {
+			t.Fatalf("ParseScope(%q) = %q, %v", name, got, err)
+		}
+	}
+	if _, err := ParseScope("team"); err == nil {
+		t.Fatal("expected error for unknown scope")
+	}
+}
+
+// UserDir lives under XDG_CONFIG_HOME.
+func TestUserDir(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", "/tmp/config")
+	if got := UserDir(); got != filepath.Join("/tmp/config", "agent-orchestrator") {
+		t.Fatalf("UserDir = %q", got)
+	}
+}
+
+// Store.Save writes each record to its scope's file and Store.Load reads
+// them back narrowest first; records of a disabled scope go to the project.
+func TestStore_SaveLoad(t *testing.T) {
+	store := Store{UserDir: t.TempDir(), ProjectDir: t.TempDir(), Branch: "feature/x"}
+	facts := records("commits are conventional", "tests use go test", "v2 API only")
+	facts[0].Scope, facts[1].Scope, facts[2].Scope = ScopeUser, ScopeProject, ScopeBranch
+	if err := store.Save(nil, facts); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	branchFile := filepath.Join(store.ProjectDir, BranchDir, "feature%2Fx.json")
+	if _, err := os.Stat(branchFile); err != nil {
+		t.Fatalf("branch file missing: %v", err)
+	}
+	if user, err := LoadMemory(store.UserDir); err != nil || len(user) != 1 || user[0].Text != "commits are conventional" || user[0].Scope != "" {
+		t.Fatalf("user file: %+v %v", user, err)
+	}
+
+	loaded, err := store.Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(loaded) != 3 || loaded[0].Scope != ScopeBranch || loaded[1].Scope != ScopeProject || loaded[2].Scope != ScopeUser {
+		t.Fatalf("unexpected load order: %+v", loaded)
+	}
+
+	noBranch := Store{ProjectDir: t.TempDir()}
+	if err := noBranch.Save(nil, facts); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if project, err := LoadMemory(noBranch.ProjectDir); err != nil || len(project) != 3 {
+		t.Fatalf("disabled scopes should fall back to the project: %+v %v", project, err)
+	}
+}
+
+// Store.Save does not create files for empty scopes but empties existing ones.
+func TestStore_SaveEmptyScopes(t *testing.T) {
+	store := Store{UserDir: t.TempDir(), ProjectDir: t.TempDir()}
+	if err := store.Save(nil, records("a")); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if _, err := os.Stat(store.Path(ScopeUser)); !os.IsNotExist(err) {
+		t.Fatalf("empty user scope should not be written: %v", err)
+	}
+	loaded, _ := store.Load()
+	if err := store.Save(loaded, nil); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if project, err := LoadMemory(store.ProjectDir); err != nil || project == nil || len(project) != 0 {
+		t.Fatalf("project file should be emptied: %+v %v", project, err)
+	}
+}
+
+// Store.Save applies only the caller's changes, so two runs that loaded the
+// same memory keep each other's additions, edits and removals.
+func TestStore_SaveMerges(t *testing.T) {
+	store := Store{UserDir: t.TempDir(), ProjectDir: t.TempDir()}
+	if err := store.Save(nil, records("keep", "edit me", "forget me", "shared")); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	first, _ := store.Load()
+	second, _ := store.Load()
+
+	// The first run forgets a fact, confirms another and learns a new one.
+	a := append([]Record(nil), first[0], first[1], first[3], NewRecord("from a", "run-a", ""))
+	a[2].Hits++
+	if err := store.Save(first, a); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	// The second, still working on what it loaded, edits a fact, confirms the
+	// shared one too and learns another.
+	b, _, err := Apply(second, Edit{Target: "edit me", Text: "edited"}, "run-b", "")
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	b = append(b, NewRecord("from b", "run-b", ""))
+	b[3].Hits++
+	if err := store.Save(second, b); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	got, _ := store.Load()
+	if texts := fmt.Sprint(Texts(got)); texts != "[keep edited shared from a from b]" {
+		t.Fatalf("merged facts: %s", texts)
+	}
+	if got[2].Hits != 2 {
+		t.Fatalf("both confirmations should count: %+v", got[2])
+	}
+	if matches, _ := filepath.Glob(filepath.Join(store.ProjectDir, "*.tmp")); len(matches) != 0 {
+		t.Fatalf("temporary files left behind: %v", matches)
+	}
+}
+
+// Visible hides a fact shadowed by the same fact in a narrower scope.
+func TestVisible(t *testing.T) {
+	facts := []Record{
+		{ID: "b", Text: "Use tabs", Scope: ScopeBranch},
+		{ID: "p", Text: "deploy with make", Scope: ScopeProject},
+		{ID: "u", Text: "use  tabs", Scope: ScopeUser},
+	}
+	got := Visible(facts)
+	if len(got) != 2 || got[0].ID != "b" || got[1].ID != "p" {
+		t.Fatalf("unexpected visible facts: %+v", got)
+	}
+	if labels := Labels(got); labels[0] != "[b] Use tabs (branch)" || labels[1] != "[p] deploy with make" {
+		t.Fatalf("unexpected labels: %q", labels)
+	}
+}
+
+// DeduplicateMemory keeps the same fact in different scopes apart.
+func TestDeduplicateMemory_Scopes(t *testing.T) {
+	facts := []Record{{Text: "a", Scope: ScopeUser}, {Text: "a", Scope: ScopeProject}, {Text: "a", Scope: ScopeUser}}
+	if got := DeduplicateMemory(facts); len(got) != 2 || got[0].Hits != 1 {
+		t.Fatalf("unexpected dedup: %+v", got)
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dlee6018/agent-orchestrator/memory"
+)
+
+// memoryCmd runs a memory subcommand against store and returns its output.
+func memoryCmd(t *testing.T, store memory.Store, compactor func() (memory.CompactFunc, error), args ...string) (string, error) {
+	t.Helper()
+	var out bytes.Buffer
+	err := runMemory(store, args, strings.NewReader(""), &out, compactor)
+	return out.String(), err
+}
+
+// add, list, search, edit and rm curate the store across scopes.
+func TestRunMemory_Curate(t *testing.T) {
+	store := memory.Store{UserDir: t.TempDir(), ProjectDir: t.TempDir()}
+	run := func(args ...string) string {
+		t.Helper()
+		out, err := memoryCmd(t, store, nil, args...)
+		if err != nil {
+			t.Fatalf("memory %v: %v", args, err)
+		}
+		return out
+	}
+
+	if out := run("list"); out != "No memory facts.\n" {
+		t.Fatalf("empty list: %q", out)
+	}
+	run("add", "-tags", "build", "build", "with", "make")
+	run("add", "-scope", "user", "I use conventional commits")
+	if out := run("list"); !strings.Contains(out, "project") || !strings.Contains(out, "build with make  #build") || !strings.Contains(out, "user") {
+		t.Fatalf("list: %q", out)
+	}
+	if out := run("list", "-scope", "user"); strings.Count(out, "\n") != 1 || !strings.Contains(out, "conventional commits") {
+		t.Fatalf("list -scope user: %q", out)
+	}
+	if out := run("search", "commits"); strings.Count(out, "\n") != 1 || !strings.Contains(out, "conventional commits") {
+		t.Fatalf("search: %q", out)
+	}
+	if out := run("edit", "build with make", "build", "with", "go", "build"); !strings.Contains(out, `"build with go build"`) {
+		t.Fatalf("edit: %q", out)
+	}
+	if out := run("rm", "conventional commits"); !strings.Contains(out, "Removed") {
+		t.Fatalf("rm: %q", out)
+	}
+	records, err := store.Load()
+	if err != nil || len(records) != 1 || records[0].Text != "build with go build" || records[0].Scope != memory.ScopeProject {
+		t.Fatalf("store after edits: %+v %v", records, err)
+	}
+
+	if _, err := memoryCmd(t, store, nil, "rm", "nothing like it"); err == nil {
+		t.Fatal("rm of an unknown fact should fail")
+	}
+	if _, err := memoryCmd(t, store, nil, "add", "-scope", "branch", "x"); err == nil {
+		t.Fatal("add to a disabled scope should fail")
+	}
+	for _, args := range [][]string{nil, {"frobnicate"}, {"add"}, {"edit", "one"}, {"list", "extra"}} {
+		if _, err := memoryCmd(t, store, nil, args...); !errors.Is(err, errMemoryUsage) {
+			t.Fatalf("memory %v: expected usage error, got %v", args, err)
+		}
+	}
+}
+
+// compact -dry-run prints the before/after diff without saving; compact saves.
+func TestRunMemory_Compact(t *testing.T) {
+	store := memory.Store{ProjectDir: t.TempDir()}
+	facts := []memory.Record{{ID: "a1", Text: "build with make"}, {ID: "b2", Text: "run make build"}, {ID: "c3", Text: "use gofmt"}}
+	if err := memory.SaveMemory(store.ProjectDir, facts); err != nil {
+		t.Fatalf("SaveMemory: %v", err)
+	}
+	compactor := func() (memory.CompactFunc, error) {
+		return func(string) (string, error) { return `["use gofmt", "build with make build"]`, nil }, nil
+	}
+
+	out, err := memoryCmd(t, store, compactor, "compact", "-dry-run")
+	if err != nil {
+		t.Fatalf("compact -dry-run: %v", err)
+	}
+	for _, want := range []string{"project memory: 3 → 2 facts", "- [a1] build with make", "- [b2] run make build", "  [c3] use gofmt", "+ [", "] build with make build", "Dry run"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("dry run output missing %q:\n%s", want, out)
+		}
+	}
+	if records, _ := store.Load(); len(records) != 3 {
+		t.Fatalf("dry run changed the store: %+v", records)
+	}
+
+	if _, err := memoryCmd(t, store, compactor, "compact"); err != nil {
+		t.Fatalf("compact: %v", err)
+	}
+	if records, _ := store.Load(); len(records) != 2 || records[0].ID != "c3" {
+		t.Fatalf("compacted store: %+v", records)
+	}
+
+	failing := func() (memory.CompactFunc, error) { return nil, errors.New("no API key") }
+	if _, err := memoryCmd(t, store, failing, "compact"); err == nil {
+		t.Fatal("compact without an LLM should fail")
+	}
+}
+
+// export writes every scope and import merges it into another store,
+// skipping facts it already has.
+func TestRunMemory_ExportImport(t *testing.T) {
+	src := memory.Store{UserDir: t.TempDir(), ProjectDir: t.TempDir()}
+	if _, err := memoryCmd(t, src, nil, "add", "-scope", "user", "I use conventional commits"); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	if _, err := memoryCmd(t, src, nil, "add", "tests use go test"); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	file := filepath.Join(t.TempDir(), "export.json")
+	if _, err := memoryCmd(t, src, nil, "export", file); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+
+	dst := memory.Store{UserDir: t.TempDir(), ProjectDir: t.TempDir()}
+	out, err := memoryCmd(t, dst, nil, "import", file)
+	if err != nil || !strings.Contains(out, "Imported 2 new") {
+		t.Fatalf("import: %q %v", out, err)
+	}
+	if out, _ := memoryCmd(t, dst, nil, "import", file); !strings.Contains(out, "Imported 0 new") {
+		t.Fatalf("second import should add nothing: %q", out)
+	}
+	if user, err := memory.LoadMemory(dst.UserDir); err != nil || len(user) != 1 || user[0].Text != "I use conventional commits" {
+		t.Fatalf("imported user memory: %+v %v", user, err)
+	}
+
+	// A plain array of facts imports into the chosen scope.
+	legacy := filepath.Join(t.TempDir(), "legacy.json")
+	os.WriteFile(legacy, []byte(`["prefer tabs"]`), 0o644)
+	if _, err := memoryCmd(t, dst, nil, "import", "-scope", "user", legacy); err != nil {
+		t.Fatalf("import legacy: %v", err)
+	}
+	if user, _ := memory.LoadMemory(dst.UserDir); len(user) != 2 {
+		t.Fatalf("legacy facts should go to user memory: %+v", user)
+	}
+}
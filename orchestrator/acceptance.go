@@ -0,0 +1,137 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// AcceptanceTimeout bounds how long a single acceptance command may run.
+var AcceptanceTimeout = 10 * time.Minute
+
+// acceptanceWaitDelay is how long a timed-out command may take to release its
+// output after it was killed, in case a process outside its group holds it.
+const acceptanceWaitDelay = 5 * time.Second
+
+// acceptanceOutputLimit caps the output of one command fed back to the LLM;
+// the tail is kept because that is where test and build failures end up.
+const acceptanceOutputLimit = 4000
+
+// AcceptanceResult is the outcome of one acceptance command.
+type AcceptanceResult struct {
+	Command  string
+	Output   string // combined stdout and stderr
+	ExitCode int    // -1 if the command could not be started or timed out
+	Duration time.Duration
+	Err      error // nil when the command exited 0
+}
+
+// Passed reports whether the command succeeded.
+func (res AcceptanceResult) Passed() bool {
+	return res.Err == nil
+}
+
+// RunAcceptance runs each command with `sh -c` in dir and reports whether all
+// of them passed. Every command runs even after a failure, so the LLM sees
+// all problems at once.
+func RunAcceptance(dir string, commands []string) ([]AcceptanceResult, bool) {
+	results := make([]AcceptanceResult, 0, len(commands))
+	ok := true
+	for _, command := range commands {
+		res := runAcceptanceCommand(dir, command)
+		if !res.Passed() {
+			ok = false
+		}
+		results = append(results, res)
+	}
+	return results, ok
+}
+
+// runAcceptanceCommand runs one acceptance command with AcceptanceTimeout.
+// On timeout its whole process group is killed.
+func runAcceptanceCommand(dir, command string) AcceptanceResult {
+	ctx, cancel := context.WithTimeout(context.Background(), AcceptanceTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Dir = dir
+	cmd.WaitDelay = acceptanceWaitDelay
+	setProcessGroup(cmd)
+	start := time.Now()
+	out, err := cmd.CombinedOutput()
+	res := AcceptanceResult{Command: command, Output: string(out), Duration: time.Since(start), Err: err}
+	var exitErr *exec.ExitError
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		res.ExitCode = -1
+		res.Err = fmt.Errorf("timed out after %s", AcceptanceTimeout)
+	case errors.As(err, &exitErr):
+		res.ExitCode = exitErr.ExitCode()
+	case err != nil:
+		res.ExitCode = -1
+	}
+	return res
+}
+
+// AcceptanceFeedback formats failed acceptance results as the user message
+// that sends the LLM back to work.
+func AcceptanceFeedback(results []AcceptanceResult) string {
+	var b strings.Builder
+	b.WriteString("The task is not complete: acceptance checks failed. Fix the problems below, then signal completion again.\n")
+	for _, res := range results {
+		if res.Passed() {
+			fmt.Fprintf(&b, "\n$ %s\n(passed)\n", res.Command)
+			continue
+		}
+		fmt.Fprintf(&b, "\n$ %s\n(failed: %v)\n", res.Command, res.Err)
+		out := strings.TrimSpace(res.Output)
+		if len(out) > acceptanceOutputLimit {
+			// Start the tail on a rune boundary so no character is split.
+			cut := len(out) - acceptanceOutputLimit
+			for cut < len(out) && !utf8.RuneStart(out[cut]) {
+				cut++
+			}
+			out = "[... output truncated ...]\n" + out[cut:]
+		}
+		if out != "" {
+			b.WriteString(out + "\n")
+		}
+	}
+	return b.String()
+}
+
+// AcceptancePrompt tells the LLM which commands gate completion; it is
+// appended to the system prompt when acceptance commands are configured.
+func AcceptancePrompt(commands []string) string {
+	if len(commands) == 0 {
+		return ""
+	}
+	return "\n\nACCEPTANCE CHECKS:\nWhen you signal completion, the orchestrator runs these commands in the working directory. Completion is only accepted if all of them pass; otherwise their output is sent back to you.\n- " + strings.Join(commands, "\n- ")
+}
+
+// verify runs the configured acceptance commands before a completion is
+// accepted. It returns "" when completion stands, or the feedback for the
+// LLM when a check failed.
+func (r *runner) verify() string {
+	commands := r.cfg.AcceptanceCommands
+	if len(commands) == 0 {
+		return ""
+	}
+	fmt.Fprintln(r.stdout, "│ Running acceptance checks...")
+	results, ok := RunAcceptance(r.cfg.WorkDir, commands)
+	for _, res := range results {
+		status := "ok"
+		if !res.Passed() {
+			status = fmt.Sprintf("FAILED (%v)", res.Err)
+		}
+		fmt.Fprintf(r.stdout, "│   %s: %s [%s]\n", res.Command, status, res.Duration.Round(time.Millisecond))
+	}
+	if ok {
+		return ""
+	}
+	fmt.Fprintln(r.stderr, "│ Acceptance checks failed; completion rejected.")
+	return AcceptanceFeedback(results)
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package orchestrator
+
+import "os/exec"
+
+// setProcessGroup is a no-op where process groups are unavailable; only the
+// command itself is killed on timeout.
+func setProcessGroup(cmd *exec.Cmd) {}
@@ -0,0 +1,86 @@
+package orchestrator
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+// Every acceptance command runs in the work dir; failures carry exit code and output.
+func TestRunAcceptance(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "ok.txt"), nil, 0o644)
+	results, ok := RunAcceptance(dir, []string{"test -f ok.txt", "echo broken; exit 3", "true"})
+	if ok || len(results) != 3 {
+		t.Fatalf("RunAcceptance = %d results, ok=%v", len(results), ok)
+	}
+	if !results[0].Passed() || results[1].Passed() || !results[2].Passed() {
+		t.Fatalf("unexpected pass/fail: %+v", results)
+	}
+	if results[1].ExitCode != 3 || !strings.Contains(results[1].Output, "broken") {
+		t.Fatalf("failed result = %+v", results[1])
+	}
+	feedback := AcceptanceFeedback(results)
+	if !strings.Contains(feedback, "$ echo broken; exit 3") || !strings.Contains(feedback, "broken") {
+		t.Fatalf("feedback missing the failure:\n%s", feedback)
+	}
+}
+
+// Long output keeps its tail, cut on a rune boundary.
+func TestAcceptanceFeedback_TruncatesOnRuneBoundary(t *testing.T) {
+	output := strings.Repeat("é", acceptanceOutputLimit) + "\nFAIL" // an odd tail length puts the cut mid-rune
+	feedback := AcceptanceFeedback([]AcceptanceResult{{Command: "go test", Output: output, ExitCode: 1, Err: errors.New("exit status 1")}})
+	if !utf8.ValidString(feedback) {
+		t.Fatalf("feedback has a split rune: %q", feedback[:200])
+	}
+	if !strings.Contains(feedback, "[... output truncated ...]\né") || !strings.HasSuffix(feedback, "FAIL\n") {
+		t.Fatalf("expected the truncated tail:\n%s", feedback[:200])
+	}
+}
+
+// A timed-out command is killed with the processes it started in the background.
+func TestRunAcceptance_Timeout(t *testing.T) {
+	defer func(d time.Duration) { AcceptanceTimeout = d }(AcceptanceTimeout)
+	AcceptanceTimeout = 200 * time.Millisecond
+
+	start := time.Now()
+	results, ok := RunAcceptance(t.TempDir(), []string{"sleep 30 & sleep 30"})
+	if ok || results[0].ExitCode != -1 || !strings.Contains(results[0].Err.Error(), "timed out") {
+		t.Fatalf("expected a timeout, got %+v", results[0])
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("background process kept the command alive for %s", elapsed)
+	}
+}
+
+// A TASK_COMPLETE rejected by a failing check feeds the output back and the loop goes on.
+func TestRunLoop_AcceptanceGate(t *testing.T) {
+	dir := t.TempDir()
+	calls := 0
+	var feedback string
+	cfg := quietConfig("gate", func(req Request) (Completion, error) {
+		calls++
+		if calls == 2 {
+			feedback = req.Messages[len(req.Messages)-1].Content
+			os.WriteFile(filepath.Join(dir, "done"), nil, 0o644)
+		}
+		return Completion{Content: TaskCompleteMarker}, nil
+	})
+	cfg.WorkDir = dir
+	cfg.MaxIterations = 5
+	cfg.AcceptanceCommands = []string{"test -f done"}
+
+	if status := RunLoop(cfg); status != StatusComplete {
+		t.Fatalf("RunLoop = %s, want %s", status, StatusComplete)
+	}
+	if calls != 2 {
+		t.Fatalf("LLM called %d times, want 2", calls)
+	}
+	if !strings.Contains(feedback, "acceptance checks failed") || !strings.Contains(feedback, "test -f done") {
+		t.Fatalf("feedback = %q", feedback)
+	}
+}
@@ -0,0 +1,256 @@
+package orchestrator
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// AnthropicVersion is the anthropic-version header sent with Messages API requests.
+const AnthropicVersion = "2023-06-01"
+
+// AnthropicMaxTokens is the max_tokens value sent to the Messages API (the field is required).
+var AnthropicMaxTokens = 4096
+
+// AnthropicProvider talks to the native Anthropic Messages API.
+type AnthropicProvider struct {
+	APIKey  string
+	BaseURL string // API root without the /v1 prefix, e.g. https://api.anthropic.com
+}
+
+// anthropicBlock is a content block: text, tool_use (assistant) or tool_result (user).
+type anthropicBlock struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text,omitempty"`
+	ID        string          `json:"id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Input     json.RawMessage `json:"input,omitempty"`
+	ToolUseID string          `json:"tool_use_id,omitempty"`
+	Content   string          `json:"content,omitempty"`
+}
+
+// anthropicMessage is a single turn in the Messages API.
+type anthropicMessage struct {
+	Role    string           `json:"role"`
+	Content []anthropicBlock `json:"content"`
+}
+
+// anthropicTool declares a tool in the Messages API format.
+type anthropicTool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"input_schema"`
+}
+
+// anthropicRequest is the request body for the Messages API.
+type anthropicRequest struct {
+	Model       string             `json:"model"`
+	System      string             `json:"system,omitempty"`
+	Messages    []anthropicMessage `json:"messages"`
+	Tools       []anthropicTool    `json:"tools,omitempty"`
+	MaxTokens   int                `json:"max_tokens"`
+	Temperature float64            `json:"temperature"`
+	Stream      bool               `json:"stream,omitempty"`
+}
+
+// anthropicResponse is the response body from the Messages API.
+type anthropicResponse struct {
+	ID         string           `json:"id"`
+	Content    []anthropicBlock `json:"content"`
+	StopReason string           `json:"stop_reason"`
+	Usage      struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+}
+
+// Name implements Provider.
+func (p *AnthropicProvider) Name() string { return ProviderAnthropic }
+
+// Complete implements Provider.
+func (p *AnthropicProvider) Complete(req Request) (Completion, error) {
+	base := p.BaseURL
+	if base == "" {
+		base = DefaultAnthropicBaseURL
+	}
+	headers := map[string]string{
+		"x-api-key":         p.APIKey,
+		"anthropic-version": AnthropicVersion,
+	}
+	body, err := postJSON(p.Name(), joinURL(base, "/v1/messages"), headers, toAnthropicRequest(req))
+	if err != nil {
+		return Completion{}, err
+	}
+
+	var result anthropicResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return Completion{}, fmt.Errorf("%s: unmarshal response: %w", p.Name(), err)
+	}
+	usage := Usage{
+		PromptTokens:     result.Usage.InputTokens,
+		CompletionTokens: result.Usage.OutputTokens,
+		TotalTokens:      result.Usage.InputTokens + result.Usage.OutputTokens,
+	}
+	if len(result.Content) == 0 {
+		return Completion{Usage: usage}, fmt.Errorf("%s: empty content in response", p.Name())
+	}
+	out := Completion{Usage: usage}
+	var sb strings.Builder
+	for _, block := range result.Content {
+		switch block.Type {
+		case "text":
+			sb.WriteString(block.Text)
+		case "tool_use":
+			out.ToolCalls = append(out.ToolCalls, ToolCall{
+				ID:       block.ID,
+				Type:     "function",
+				Function: ToolCallFunction{Name: block.Name, Arguments: string(block.Input)},
+			})
+		}
+	}
+	out.Content = sb.String()
+	return out, nil
+}
+
+// anthropicStreamEvent is one SSE event from a streaming Messages API response.
+type anthropicStreamEvent struct {
+	Type    string `json:"type"`
+	Index   int    `json:"index"`
+	Message struct {
+		Usage struct {
+			InputTokens int `json:"input_tokens"`
+		} `json:"usage"`
+	} `json:"message"`
+	ContentBlock anthropicBlock `json:"content_block"`
+	Delta        struct {
+		Type        string `json:"type"`
+		Text        string `json:"text"`
+		PartialJSON string `json:"partial_json"`
+	} `json:"delta"`
+	Usage struct {
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+	Error struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// CompleteStream implements StreamingProvider.
+func (p *AnthropicProvider) CompleteStream(req Request, onDelta DeltaFunc) (Completion, error) {
+	base := p.BaseURL
+	if base == "" {
+		base = DefaultAnthropicBaseURL
+	}
+	headers := map[string]string{
+		"x-api-key":         p.APIKey,
+		"anthropic-version": AnthropicVersion,
+	}
+	payload := toAnthropicRequest(req)
+	payload.Stream = true
+
+	var out Completion
+	var sb strings.Builder
+	calls := map[int]*ToolCall{} // tool_use blocks by content block index
+	var order []int
+	err := postStream(p.Name(), joinURL(base, "/v1/messages"), headers, payload, func(body []byte) error {
+		return fmt.Errorf("%s: expected an event stream, got JSON: %s", p.Name(), string(body))
+	}, func(data []byte) (bool, error) {
+		var ev anthropicStreamEvent
+		if err := json.Unmarshal(data, &ev); err != nil {
+			return false, fmt.Errorf("%s: unmarshal stream event: %w", p.Name(), err)
+		}
+		switch ev.Type {
+		case "message_start":
+			out.Usage.PromptTokens = ev.Message.Usage.InputTokens
+		case "content_block_start":
+			if ev.ContentBlock.Type == "tool_use" {
+				calls[ev.Index] = &ToolCall{
+					ID:       ev.ContentBlock.ID,
+					Type:     "function",
+					Function: ToolCallFunction{Name: ev.ContentBlock.Name},
+				}
+				order = append(order, ev.Index)
+			}
+		case "content_block_delta":
+			switch ev.Delta.Type {
+			case "text_delta":
+				sb.WriteString(ev.Delta.Text)
+				emit(onDelta, ev.Delta.Text)
+			case "input_json_delta":
+				if call := calls[ev.Index]; call != nil {
+					call.Function.Arguments += ev.Delta.PartialJSON
+				}
+			}
+		case "message_delta":
+			out.Usage.CompletionTokens = ev.Usage.OutputTokens
+		case "message_stop":
+			return true, nil
+		case "error":
+			return false, fmt.Errorf("%s: stream error: %s", p.Name(), ev.Error.Message)
+		}
+		return false, nil
+	})
+	out.Usage.TotalTokens = out.Usage.PromptTokens + out.Usage.CompletionTokens
+	out.Content = sb.String()
+	for _, idx := range order {
+		call := *calls[idx]
+		if call.Function.Arguments == "" {
+			call.Function.Arguments = "{}"
+		}
+		out.ToolCalls = append(out.ToolCalls, call)
+	}
+	return out, err
+}
+
+// toAnthropicRequest converts an OpenAI-style request: system messages move to
+// the top-level system field, assistant tool calls become tool_use blocks, tool
+// results become user tool_result blocks, and consecutive same-role turns are
+// merged, since the Messages API requires strict user/assistant alternation.
+func toAnthropicRequest(req Request) anthropicRequest {
+	out := anthropicRequest{
+		Model:       req.Model,
+		MaxTokens:   AnthropicMaxTokens,
+		Temperature: req.Temperature,
+	}
+	for _, t := range req.Tools {
+		out.Tools = append(out.Tools, anthropicTool{
+			Name:        t.Function.Name,
+			Description: t.Function.Description,
+			InputSchema: t.Function.Parameters,
+		})
+	}
+	var system []string
+	for _, m := range req.Messages {
+		role := m.Role
+		var blocks []anthropicBlock
+		switch m.Role {
+		case "system":
+			system = append(system, m.Content)
+			continue
+		case "tool":
+			role = "user"
+			blocks = append(blocks, anthropicBlock{Type: "tool_result", ToolUseID: m.ToolCallID, Content: m.Content})
+		default:
+			if m.Content != "" {
+				blocks = append(blocks, anthropicBlock{Type: "text", Text: m.Content})
+			}
+			for _, tc := range m.ToolCalls {
+				input := json.RawMessage(tc.Function.Arguments)
+				if !json.Valid(input) {
+					input = json.RawMessage("{}")
+				}
+				blocks = append(blocks, anthropicBlock{Type: "tool_use", ID: tc.ID, Name: tc.Function.Name, Input: input})
+			}
+		}
+		if len(blocks) == 0 {
+			continue
+		}
+		if n := len(out.Messages); n > 0 && out.Messages[n-1].Role == role {
+			out.Messages[n-1].Content = append(out.Messages[n-1].Content, blocks...)
+			continue
+		}
+		out.Messages = append(out.Messages, anthropicMessage{Role: role, Content: blocks})
+	}
+	out.System = strings.Join(system, "\n\n")
+	return out
+}
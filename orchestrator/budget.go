@@ -0,0 +1,122 @@
+package orchestrator
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dlee6018/agent-orchestrator/dashboard"
+)
+
+// DefaultSoftLimit is the fraction of a budget at which the LLM is told to
+// wrap up when Budget.SoftLimit is unset.
+const DefaultSoftLimit = 0.8
+
+// Price is what a model costs in USD per million tokens.
+type Price struct {
+	Prompt     float64 `json:"prompt"`
+	Completion float64 `json:"completion"`
+}
+
+// Prices maps model names to their price. It is used for providers that do
+// not report cost themselves; models missing from it cost nothing.
+var Prices = map[string]Price{}
+
+// Budget caps what one run may spend on orchestrator LLM calls. Zero limits
+// are unlimited.
+type Budget struct {
+	MaxTokens int     // prompt plus completion tokens
+	MaxCost   float64 // USD
+	// SoftLimit is the fraction of either limit at which the LLM is asked to
+	// wrap up; 0 means DefaultSoftLimit.
+	SoftLimit float64
+}
+
+// Spend is the cumulative LLM usage of a run, including summarization and
+// memory compaction calls.
+type Spend struct {
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	Cost             float64 `json:"cost"`
+}
+
+// Tokens returns the total tokens spent.
+func (s Spend) Tokens() int {
+	return s.PromptTokens + s.CompletionTokens
+}
+
+// Add records one call's usage. The provider-reported cost wins; otherwise
+// the cost comes from Prices.
+func (s *Spend) Add(model string, u Usage) {
+	s.PromptTokens += u.PromptTokens
+	s.CompletionTokens += u.CompletionTokens
+	if u.Cost > 0 {
+		s.Cost += u.Cost
+	} else if p, ok := Prices[model]; ok {
+		s.Cost += (float64(u.PromptTokens)*p.Prompt + float64(u.CompletionTokens)*p.Completion) / 1e6
+	}
+}
+
+// Exceeded returns why s is over the budget, or "" if it is not.
+func (b Budget) Exceeded(s Spend) string {
+	if b.MaxTokens > 0 && s.Tokens() >= b.MaxTokens {
+		return fmt.Sprintf("token budget exhausted (%d of %d tokens)", s.Tokens(), b.MaxTokens)
+	}
+	if b.MaxCost > 0 && s.Cost >= b.MaxCost {
+		return fmt.Sprintf("cost budget exhausted ($%.4f of $%.2f)", s.Cost, b.MaxCost)
+	}
+	return ""
+}
+
+// Used returns the largest fraction of any limit that s has consumed.
+func (b Budget) Used(s Spend) float64 {
+	used := 0.0
+	if b.MaxTokens > 0 {
+		used = float64(s.Tokens()) / float64(b.MaxTokens)
+	}
+	if b.MaxCost > 0 {
+		used = max(used, s.Cost/b.MaxCost)
+	}
+	return used
+}
+
+// softLimit returns the configured soft threshold.
+func (b Budget) softLimit() float64 {
+	if b.SoftLimit > 0 {
+		return b.SoftLimit
+	}
+	return DefaultSoftLimit
+}
+
+// charge adds the usage of one call to model to the run's spend.
+func (r *runner) charge(model string, u Usage) {
+	r.spend.Add(model, u)
+}
+
+// warnBudget asks the LLM to wrap up, once, when the spend crosses the soft
+// threshold of the budget.
+func (r *runner) warnBudget() {
+	b := r.cfg.Budget
+	used := b.Used(r.spend)
+	if r.budgetWarned || used < b.softLimit() {
+		return
+	}
+	r.budgetWarned = true
+	fmt.Fprintf(r.stderr, "│ Budget warning: %.0f%% used\n", used*100)
+	r.messages = append(r.messages, Message{Role: "user", Content: fmt.Sprintf(
+		"BUDGET WARNING: this run has used %.0f%% of its budget (%d tokens, $%.4f). Wrap up: finish the most important remaining work, verify it and signal completion soon. The run will be stopped when the budget is exhausted.",
+		used*100, r.spend.Tokens(), r.spend.Cost)})
+}
+
+// stopForBudget ends the run after iteration i because the budget is spent.
+func (r *runner) stopForBudget(i int, reason string) string {
+	r.saveCheckpoint(i, StatusBudgetExceeded)
+	fmt.Fprintf(r.stderr, "\nStopping: %s.\n", reason)
+	r.publish(dashboard.IterationEvent{
+		Type:      "complete",
+		Iteration: i,
+		Timestamp: time.Now().Format(time.RFC3339),
+		Error:     reason,
+		Cost:      r.spend.Cost,
+	})
+	return StatusBudgetExceeded
+}
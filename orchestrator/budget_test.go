@@ -0,0 +1,77 @@
+package orchestrator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dlee6018/agent-orchestrator/dashboard"
+)
+
+// Reported cost wins over the price table; limits trip on tokens or dollars.
+func TestSpend_AddAndBudget(t *testing.T) {
+	old := Prices
+	t.Cleanup(func() { Prices = old })
+	Prices = map[string]Price{"priced": {Prompt: 3, Completion: 15}}
+
+	var s Spend
+	s.Add("priced", Usage{PromptTokens: 1_000_000, CompletionTokens: 100_000})
+	if s.Cost != 4.5 || s.Tokens() != 1_100_000 {
+		t.Fatalf("priced spend = %+v", s)
+	}
+	s.Add("priced", Usage{PromptTokens: 10, Cost: 0.5})
+	s.Add("unpriced", Usage{PromptTokens: 10})
+	if s.Cost != 5 {
+		t.Fatalf("cost = %g, want 5", s.Cost)
+	}
+
+	if reason := (Budget{MaxCost: 10}).Exceeded(s); reason != "" {
+		t.Fatalf("under budget reported %q", reason)
+	}
+	if reason := (Budget{MaxCost: 5}).Exceeded(s); !strings.Contains(reason, "cost budget") {
+		t.Fatalf("Exceeded = %q", reason)
+	}
+	if reason := (Budget{MaxTokens: 1000}).Exceeded(s); !strings.Contains(reason, "token budget") {
+		t.Fatalf("Exceeded = %q", reason)
+	}
+	if used := (Budget{MaxTokens: 11_000_000, MaxCost: 10}).Used(s); used != 0.5 {
+		t.Fatalf("Used = %g, want 0.5", used)
+	}
+}
+
+// The LLM is warned at the soft limit and the run stops once the budget is spent.
+func TestRunLoop_BudgetStops(t *testing.T) {
+	var requests []Request
+	cfg := quietConfig("budget", func(req Request) (Completion, error) {
+		requests = append(requests, req)
+		return Completion{Usage: Usage{PromptTokens: 80, CompletionTokens: 20, TotalTokens: 100}}, nil
+	})
+	cfg.ToolCalling, cfg.MaxIterations = true, 10
+	cfg.Budget = Budget{MaxTokens: 250}
+	var last string
+	cfg.OnEvent = func(evt dashboard.IterationEvent) {
+		if evt.Type == "complete" {
+			last = evt.Error
+		}
+	}
+
+	if status := RunLoop(cfg); status != StatusBudgetExceeded {
+		t.Fatalf("RunLoop = %s, want %s", status, StatusBudgetExceeded)
+	}
+	if len(requests) != 3 {
+		t.Fatalf("LLM called %d times, want 3", len(requests))
+	}
+	warned := func(req Request) bool {
+		for _, m := range req.Messages {
+			if strings.Contains(m.Content, "BUDGET WARNING") {
+				return true
+			}
+		}
+		return false
+	}
+	if warned(requests[1]) || !warned(requests[2]) {
+		t.Fatal("the wrap-up warning should first appear once 80% is used")
+	}
+	if !strings.Contains(last, "token budget exhausted (300 of 250 tokens)") {
+		t.Fatalf("complete event error = %q", last)
+	}
+}
@@ -0,0 +1,279 @@
+package orchestrator
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/dlee6018/agent-orchestrator/dashboard"
+	"github.com/dlee6018/agent-orchestrator/git"
+	"github.com/dlee6018/agent-orchestrator/memory"
+	"github.com/dlee6018/agent-orchestrator/transcript"
+)
+
+// CheckpointFile is the checkpoint file name inside a run directory.
+const CheckpointFile = "checkpoint.json"
+
+// SummaryFile is the run summary file name inside a run directory. It holds
+// a dashboard.RunSummary, so run history can be listed without loading every
+// conversation.
+const SummaryFile = "summary.json"
+
+// CheckpointVersion is the current checkpoint format version.
+const CheckpointVersion = 1
+
+// Run statuses recorded in checkpoints.
+const (
+	StatusRunning        = "running"
+	StatusComplete       = "complete"
+	StatusAborted        = "aborted"
+	StatusMaxIterations  = "max_iterations"
+	StatusBudgetExceeded = "budget_exceeded"
+	StatusStopped        = "stopped"
+)
+
+// Checkpoint is the persisted state of an autonomous run, written after every
+// iteration so an interrupted run can be resumed.
+type Checkpoint struct {
+	Version        int             `json:"version"`
+	RunID          string          `json:"run_id"`
+	Status         string          `json:"status"`
+	Session        string          `json:"session"`
+	Socket         string          `json:"socket,omitempty"`
+	WorkDir        string          `json:"work_dir"`
+	Command        string          `json:"command"`
+	AgentName      string          `json:"agent_name"`
+	Task           string          `json:"task"`
+	Provider       string          `json:"provider"`
+	Model          string          `json:"model"`
+	FallbackModels []string        `json:"fallback_models,omitempty"`
+	ToolCalling    bool            `json:"tool_calling,omitempty"`
+	Worktree       *git.Worktree   `json:"worktree,omitempty"`
+	MemoryDir      string          `json:"memory_dir,omitempty"`
+	MemoryUserDir  string          `json:"memory_user_dir,omitempty"`
+	MemoryBranch   string          `json:"memory_branch,omitempty"`
+	Acceptance     []string        `json:"acceptance,omitempty"`
+	Iteration      int             `json:"iteration"` // last completed iteration
+	Messages       []Message       `json:"messages"`
+	Memories       []memory.Record `json:"memories,omitempty"`
+	MemoryBase     []memory.Record `json:"memory_base,omitempty"` // memories as loaded when the run started
+	LastPane       string          `json:"last_pane,omitempty"`
+	LastSeen       string          `json:"last_seen,omitempty"`
+	PaneDeltas     int             `json:"pane_deltas,omitempty"` // pane messages sent as deltas since the last full capture
+	Context        ContextState    `json:"context"`
+	Spend          Spend           `json:"spend"`
+	BudgetWarned   bool            `json:"budget_warned,omitempty"`
+	StartedAt      time.Time       `json:"started_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+}
+
+// Resumable reports whether the run was interrupted, so resume can continue
+// it under its own limits. Complete runs and runs that hit their iteration
+// cap or budget are not (see ResumableWith).
+func (cp *Checkpoint) Resumable() bool {
+	switch cp.Status {
+	case StatusRunning, StatusAborted, StatusStopped:
+		return true
+	}
+	return false
+}
+
+// ResumableWith reports whether the run can continue under the iteration cap
+// maxIterations (0 for none) and budget b. An interrupted run always can; a
+// run that hit its cap or budget only once that limit leaves room.
+func (cp *Checkpoint) ResumableWith(maxIterations int, b Budget) bool {
+	switch cp.Status {
+	case StatusMaxIterations:
+		return maxIterations == 0 || maxIterations > cp.Iteration
+	case StatusBudgetExceeded:
+		return b.Exceeded(cp.Spend) == ""
+	}
+	return cp.Resumable()
+}
+
+// NewRunID returns a sortable, unique run identifier such as
+// "20260102-150405-a1b2c3d4". The 32 random bits keep runs started in the same
+// second apart; without a random source the clock's nanoseconds stand in.
+func NewRunID() string {
+	now := time.Now()
+	var b [4]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		binary.BigEndian.PutUint32(b[:], uint32(now.UnixNano()))
+	}
+	return now.Format("20060102-150405") + "-" + hex.EncodeToString(b[:])
+}
+
+// SaveCheckpoint atomically writes cp to dir/checkpoint.json and its summary
+// to dir/summary.json, creating dir if needed. Files are written to a
+// temporary name and renamed so a crash mid-write never leaves a truncated
+// checkpoint.
+func SaveCheckpoint(dir string, cp *Checkpoint) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("SaveCheckpoint: %w", err)
+	}
+	cp.Version = CheckpointVersion
+	if err := writeJSONAtomic(dir, CheckpointFile, cp); err != nil {
+		return fmt.Errorf("SaveCheckpoint: %w", err)
+	}
+	if err := writeJSONAtomic(dir, SummaryFile, cp.Summary()); err != nil {
+		return fmt.Errorf("SaveCheckpoint: %w", err)
+	}
+	return nil
+}
+
+// writeJSONAtomic writes v as indented JSON to dir/name via a temporary file
+// and a rename.
+func writeJSONAtomic(dir, name string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal %s: %w", name, err)
+	}
+	tmp, err := os.CreateTemp(dir, name+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write %s: %w", name, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("sync %s: %w", name, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("close %s: %w", name, err)
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(dir, name)); err != nil {
+		return fmt.Errorf("rename %s: %w", name, err)
+	}
+	return nil
+}
+
+// Summary returns the run's summary for the dashboard history.
+func (cp *Checkpoint) Summary() dashboard.RunSummary {
+	return dashboard.RunSummary{
+		RunID:      cp.RunID,
+		Task:       cp.Task,
+		Agent:      cp.AgentName,
+		Provider:   cp.Provider,
+		Model:      cp.Model,
+		Status:     cp.Status,
+		Iterations: cp.Iteration,
+		Tokens:     cp.Spend.Tokens(),
+		Cost:       cp.Spend.Cost,
+		StartedAt:  cp.StartedAt,
+		UpdatedAt:  cp.UpdatedAt,
+		DurationMs: cp.UpdatedAt.Sub(cp.StartedAt).Milliseconds(),
+	}
+}
+
+// LoadCheckpoint reads the checkpoint in run directory dir.
+func LoadCheckpoint(dir string) (*Checkpoint, error) {
+	data, err := os.ReadFile(filepath.Join(dir, CheckpointFile))
+	if err != nil {
+		return nil, fmt.Errorf("LoadCheckpoint: %w", err)
+	}
+	var cp Checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, fmt.Errorf("LoadCheckpoint: unmarshal: %w", err)
+	}
+	if cp.Version > CheckpointVersion {
+		return nil, fmt.Errorf("LoadCheckpoint: unsupported checkpoint version %d", cp.Version)
+	}
+	return &cp, nil
+}
+
+// ListCheckpoints loads the checkpoint of every run directory under base,
+// newest first. Directories without a readable checkpoint are skipped.
+func ListCheckpoints(base string) ([]*Checkpoint, error) {
+	entries, err := os.ReadDir(base)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("ListCheckpoints: %w", err)
+	}
+	var cps []*Checkpoint
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		cp, err := LoadCheckpoint(filepath.Join(base, e.Name()))
+		if err != nil {
+			continue
+		}
+		cps = append(cps, cp)
+	}
+	sort.Slice(cps, func(i, j int) bool { return cps[i].UpdatedAt.After(cps[j].UpdatedAt) })
+	return cps, nil
+}
+
+// ListRuns returns the summary of every run directory under base, newest
+// first. Runs saved before summaries existed are summarized from their
+// checkpoint; directories with neither are skipped. Events is set when the
+// run has a transcript.
+func ListRuns(base string) ([]dashboard.RunSummary, error) {
+	entries, err := os.ReadDir(base)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("ListRuns: %w", err)
+	}
+	var runs []dashboard.RunSummary
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		dir := filepath.Join(base, e.Name())
+		var sum dashboard.RunSummary
+		data, err := os.ReadFile(filepath.Join(dir, SummaryFile))
+		if err == nil {
+			err = json.Unmarshal(data, &sum)
+		}
+		if err != nil {
+			cp, err := LoadCheckpoint(dir)
+			if err != nil {
+				continue
+			}
+			sum = cp.Summary()
+		}
+		if _, err := os.Stat(filepath.Join(dir, transcript.FileName)); err == nil {
+			sum.Events = true
+		}
+		runs = append(runs, sum)
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].StartedAt.After(runs[j].StartedAt) })
+	return runs, nil
+}
+
+// FindRunDir resolves a run to resume. ref may be a run ID under base, a path
+// to a run directory, or empty for the most recently updated resumable run.
+func FindRunDir(base, ref string) (string, error) {
+	if ref != "" {
+		for _, dir := range []string{ref, filepath.Join(base, ref)} {
+			if _, err := os.Stat(filepath.Join(dir, CheckpointFile)); err == nil {
+				return dir, nil
+			}
+		}
+		return "", fmt.Errorf("FindRunDir: no checkpoint for run %q in %s", ref, base)
+	}
+	cps, err := ListCheckpoints(base)
+	if err != nil {
+		return "", fmt.Errorf("FindRunDir: %w", err)
+	}
+	for _, cp := range cps {
+		if cp.Resumable() {
+			return filepath.Join(base, cp.RunID), nil
+		}
+	}
+	return "", errors.New("FindRunDir: no resumable runs found")
+}
@@ -0,0 +1,181 @@
+package orchestrator
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dlee6018/agent-orchestrator/memory"
+	"github.com/dlee6018/agent-orchestrator/transcript"
+)
+
+// SaveCheckpoint/LoadCheckpoint round-trip the loop state without leaving temp files.
+func TestCheckpoint_RoundTrip(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "run1")
+	cp := &Checkpoint{
+		RunID:     "run1",
+		Status:    StatusRunning,
+		Task:      "do it",
+		Iteration: 4,
+		Messages: []Message{
+			{Role: "system", Content: "sys"},
+			{Role: "assistant", ToolCalls: []ToolCall{toolCall(ToolWait, `{"seconds":5}`)}},
+			{Role: "tool", ToolCallID: "call_1", Content: "out"},
+		},
+		Memories: []memory.Record{{ID: "a1", Text: "fact"}},
+		LastPane: "$ ",
+		Context:  ContextState{Task: "Task: do it", Summary: "- did things"},
+	}
+	if err := SaveCheckpoint(dir, cp); err != nil {
+		t.Fatalf("SaveCheckpoint: %v", err)
+	}
+	got, err := LoadCheckpoint(dir)
+	if err != nil {
+		t.Fatalf("LoadCheckpoint: %v", err)
+	}
+	if got.Version != CheckpointVersion || got.Iteration != 4 || len(got.Messages) != 3 {
+		t.Fatalf("unexpected checkpoint: %+v", got)
+	}
+	if got.Messages[1].ToolCalls[0].Function.Name != ToolWait || got.Messages[2].ToolCallID != "call_1" {
+		t.Fatalf("tool calls not preserved: %+v", got.Messages)
+	}
+	if got.Context.Summary != "- did things" || got.Memories[0].Text != "fact" {
+		t.Fatalf("state not preserved: %+v", got)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 2 {
+		t.Fatalf("expected only %s and %s in run dir, got %d entries", CheckpointFile, SummaryFile, len(entries))
+	}
+}
+
+// Checkpoints from a newer format version are rejected.
+func TestLoadCheckpoint_FutureVersion(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, CheckpointFile), []byte(`{"version":99}`), 0o644)
+	if _, err := LoadCheckpoint(dir); err == nil || !strings.Contains(err.Error(), "version") {
+		t.Fatalf("expected version error, got %v", err)
+	}
+}
+
+// Run IDs sort by start time and stay unique for runs started in the same second.
+func TestNewRunID(t *testing.T) {
+	format := regexp.MustCompile(`^\d{8}-\d{6}-[0-9a-f]{8}$`)
+	seen := make(map[string]bool)
+	for range 1000 {
+		id := NewRunID()
+		if !format.MatchString(id) {
+			t.Fatalf("unexpected run ID format %q", id)
+		}
+		if seen[id] {
+			t.Fatalf("duplicate run ID %q", id)
+		}
+		seen[id] = true
+	}
+}
+
+// FindRunDir resolves IDs and paths, and defaults to the newest resumable run.
+func TestFindRunDir(t *testing.T) {
+	base := t.TempDir()
+	now := time.Now()
+	for _, cp := range []*Checkpoint{
+		{RunID: "old", Status: StatusRunning, UpdatedAt: now.Add(-2 * time.Hour)},
+		{RunID: "mid", Status: StatusAborted, UpdatedAt: now.Add(-time.Hour)},
+		{RunID: "capped", Status: StatusMaxIterations, UpdatedAt: now.Add(-time.Minute)},
+		{RunID: "new", Status: StatusComplete, UpdatedAt: now},
+	} {
+		if err := SaveCheckpoint(filepath.Join(base, cp.RunID), cp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	os.MkdirAll(filepath.Join(base, "empty"), 0o755)
+
+	cps, err := ListCheckpoints(base)
+	if err != nil || len(cps) != 4 || cps[0].RunID != "new" {
+		t.Fatalf("ListCheckpoints: %v %v", err, cps)
+	}
+	if dir, err := FindRunDir(base, ""); err != nil || filepath.Base(dir) != "mid" {
+		t.Fatalf("latest resumable: %q %v", dir, err)
+	}
+	if dir, err := FindRunDir(base, "old"); err != nil || dir != filepath.Join(base, "old") {
+		t.Fatalf("by id: %q %v", dir, err)
+	}
+	if dir, err := FindRunDir(base, filepath.Join(base, "new")); err != nil || dir != filepath.Join(base, "new") {
+		t.Fatalf("by path: %q %v", dir, err)
+	}
+	if _, err := FindRunDir(base, "missing"); err == nil {
+		t.Fatal("expected error for unknown run")
+	}
+	if _, err := FindRunDir(t.TempDir(), ""); err == nil {
+		t.Fatal("expected error when there are no runs")
+	}
+}
+
+// Interrupted runs are resumable as they are; runs that hit a limit only
+// once it is raised, and complete runs never.
+func TestCheckpoint_Resumable(t *testing.T) {
+	spent := Spend{Cost: 2}
+	for _, tc := range []struct {
+		status    string
+		resumable bool // under the run's own limits
+		raised    bool // with the cap and budget raised
+	}{
+		{StatusRunning, true, true},
+		{StatusAborted, true, true},
+		{StatusStopped, true, true},
+		{StatusMaxIterations, false, true},
+		{StatusBudgetExceeded, false, true},
+		{StatusComplete, false, false},
+	} {
+		cp := &Checkpoint{Status: tc.status, Iteration: 10, Spend: spent}
+		if got := cp.Resumable(); got != tc.resumable {
+			t.Errorf("%s: Resumable() = %v, want %v", tc.status, got, tc.resumable)
+		}
+		if got := cp.ResumableWith(10, Budget{MaxCost: 2}); got != tc.resumable {
+			t.Errorf("%s: ResumableWith(same limits) = %v, want %v", tc.status, got, tc.resumable)
+		}
+		if got := cp.ResumableWith(20, Budget{MaxCost: 5}); got != tc.raised {
+			t.Errorf("%s: ResumableWith(raised limits) = %v, want %v", tc.status, got, tc.raised)
+		}
+	}
+}
+
+// ListRuns reads run summaries newest first, falling back to the checkpoint
+// for runs without one, and flags runs that have a transcript.
+func TestListRuns(t *testing.T) {
+	base := t.TempDir()
+	start := time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC)
+	for i, cp := range []*Checkpoint{
+		{RunID: "first", Status: StatusComplete, Task: "one", Model: "m", Iteration: 3,
+			Spend: Spend{PromptTokens: 100, CompletionTokens: 20, Cost: 0.5}, StartedAt: start, UpdatedAt: start.Add(90 * time.Second)},
+		{RunID: "second", Status: StatusRunning, Task: "two", StartedAt: start.Add(time.Hour), UpdatedAt: start.Add(time.Hour)},
+	} {
+		dir := filepath.Join(base, cp.RunID)
+		if err := SaveCheckpoint(dir, cp); err != nil {
+			t.Fatal(err)
+		}
+		if i == 1 {
+			os.Remove(filepath.Join(dir, SummaryFile)) // saved before summaries existed
+			os.WriteFile(filepath.Join(dir, transcript.FileName), nil, 0o644)
+		}
+	}
+	os.MkdirAll(filepath.Join(base, "empty"), 0o755)
+
+	runs, err := ListRuns(base)
+	if err != nil || len(runs) != 2 {
+		t.Fatalf("ListRuns: %v %+v", err, runs)
+	}
+	if runs[0].RunID != "second" || runs[0].Task != "two" || !runs[0].Events {
+		t.Fatalf("newest run: %+v", runs[0])
+	}
+	first := runs[1]
+	if first.Status != StatusComplete || first.Iterations != 3 || first.Tokens != 120 || first.Cost != 0.5 ||
+		first.DurationMs != 90000 || first.Events {
+		t.Fatalf("summary: %+v", first)
+	}
+	if runs, err := ListRuns(filepath.Join(base, "missing")); err != nil || runs != nil {
+		t.Fatalf("missing base: %v %v", runs, err)
+	}
+}
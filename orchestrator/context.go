@@ -0,0 +1,192 @@
+package orchestrator
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SummarizeFunc is the callback signature for LLM-based conversation summarization.
+// It receives a prompt string and returns the LLM's reply.
+type SummarizeFunc func(prompt string) (string, error)
+
+// ContextManager keeps a long orchestrator conversation within the model's
+// context window. The system prompt (messages[0]) and the task (messages[1])
+// are always kept; older turns are folded into a rolling summary appended to
+// the task message, and only the most recent pane captures are sent verbatim.
+type ContextManager struct {
+	MaxTokens  int    // estimated token budget per request; 0 disables summarization
+	KeepPanes  int    // most recent pane captures sent verbatim; 0 keeps all
+	PanePrefix string // content prefix identifying pane-capture messages, e.g. "Claude Code output:\n"
+	Summarize  SummarizeFunc
+
+	task    string // original task message, before any summary was appended
+	summary string // rolling summary of folded turns
+}
+
+// Summary returns the rolling summary of turns folded so far.
+func (c *ContextManager) Summary() string {
+	return c.summary
+}
+
+// EstimateTokens roughly estimates the prompt tokens of msgs (~4 characters
+// per token plus a small per-message overhead). It errs on the high side for
+// English text, which is what a budget check wants.
+func EstimateTokens(msgs []Message) int {
+	chars := 0
+	for _, m := range msgs {
+		chars += len(m.Content) + 16
+		for _, tc := range m.ToolCalls {
+			chars += len(tc.Function.Name) + len(tc.Function.Arguments) + 16
+		}
+	}
+	return chars / 4
+}
+
+// View returns the messages to send: msgs with every pane capture except the
+// last KeepPanes replaced by a one-line placeholder. msgs is not modified.
+func (c *ContextManager) View(msgs []Message) []Message {
+	if c.KeepPanes <= 0 || c.PanePrefix == "" {
+		return msgs
+	}
+	out := make([]Message, len(msgs))
+	copy(out, msgs)
+	kept := 0
+	for i := len(out) - 1; i >= 2; i-- {
+		if !c.isPane(out[i]) {
+			continue
+		}
+		kept++
+		if kept > c.KeepPanes {
+			lines := strings.Count(out[i].Content, "\n")
+			out[i].Content = fmt.Sprintf("%s[%d lines of earlier output elided]", c.PanePrefix, lines)
+		}
+	}
+	return out
+}
+
+// isPane reports whether m carries a pane capture.
+func (c *ContextManager) isPane(m Message) bool {
+	return (m.Role == "user" || m.Role == "tool") && strings.HasPrefix(m.Content, c.PanePrefix)
+}
+
+// Fit folds the oldest turns of msgs into the rolling summary until the
+// request View fits within MaxTokens, and returns the shortened conversation.
+// At least the latest turn is always kept. If summarization fails the folded
+// turns are dropped with a note so the run can continue; the error is returned
+// alongside the shortened conversation.
+func (c *ContextManager) Fit(msgs []Message) ([]Message, error) {
+	if c.MaxTokens <= 0 || len(msgs) < 2 {
+		return msgs, nil
+	}
+	if c.task == "" {
+		c.task = msgs[1].Content
+	}
+	var firstErr error
+	for EstimateTokens(c.View(msgs)) > c.MaxTokens {
+		starts := turnStarts(msgs)
+		if len(starts) < 2 {
+			break
+		}
+		// Fold the older half of the turns in one summarization call.
+		cut := starts[len(starts)/2]
+		folded := msgs[2:cut]
+
+		summary, err := c.summarize(folded)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("ContextManager.Fit: %w", err)
+			}
+			summary = strings.TrimSpace(c.summary + fmt.Sprintf("\n(%d earlier messages were dropped without a summary.)", len(folded)))
+		}
+		c.summary = summary
+
+		rest := msgs[cut:]
+		msgs = append([]Message{msgs[0], {Role: msgs[1].Role, Content: c.taskWithSummary()}}, rest...)
+	}
+	return msgs, firstErr
+}
+
+// turnStarts returns the indices of assistant messages after the pinned
+// prefix. A turn is an assistant message plus the user/tool messages that
+// answer it, so cutting at a turn start never orphans a tool result.
+func turnStarts(msgs []Message) []int {
+	var starts []int
+	for i := 2; i < len(msgs); i++ {
+		if msgs[i].Role == "assistant" {
+			starts = append(starts, i)
+		}
+	}
+	return starts
+}
+
+// taskWithSummary returns the pinned task message with the rolling summary appended.
+func (c *ContextManager) taskWithSummary() string {
+	if c.summary == "" {
+		return c.task
+	}
+	return c.task + "\n\n## Progress so far (summary of earlier turns)\n" + c.summary
+}
+
+// summarize asks the LLM to merge the previous summary with the folded turns.
+func (c *ContextManager) summarize(folded []Message) (string, error) {
+	if c.Summarize == nil {
+		return "", fmt.Errorf("no summarizer configured")
+	}
+	var sb strings.Builder
+	for _, m := range folded {
+		role := m.Role
+		if role == "user" || role == "tool" {
+			role = "observation"
+		}
+		fmt.Fprintf(&sb, "[%s]\n%s\n", role, m.Content)
+		for _, tc := range m.ToolCalls {
+			fmt.Fprintf(&sb, "(called %s)\n", DescribeToolCall(tc))
+		}
+		sb.WriteByte('\n')
+	}
+	previous := c.summary
+	if previous == "" {
+		previous = "(none)"
+	}
+	prompt := fmt.Sprintf(`You are summarizing the history of an autonomous agent driving a coding CLI, so it can continue with a shorter context.
+
+Merge the previous summary and the transcript below into one concise summary:
+- What has been done and verified so far
+- Files, commands and decisions that matter for the rest of the task
+- Errors encountered and approaches that failed (so they are not repeated)
+- What was in progress at the end
+
+Return ONLY the summary as plain text bullet points.
+
+Previous summary:
+%s
+
+Transcript:
+%s`, previous, sb.String())
+
+	reply, err := c.Summarize(prompt)
+	if err != nil {
+		return "", err
+	}
+	reply = strings.TrimSpace(reply)
+	if reply == "" {
+		return "", fmt.Errorf("empty summary")
+	}
+	return reply, nil
+}
+
+// ContextState is the persistable part of a ContextManager, saved in run checkpoints.
+type ContextState struct {
+	Task    string `json:"task,omitempty"`
+	Summary string `json:"summary,omitempty"`
+}
+
+// State returns the manager's pinned task and rolling summary.
+func (c *ContextManager) State() ContextState {
+	return ContextState{Task: c.task, Summary: c.summary}
+}
+
+// Restore reinstates state saved by State, e.g. when resuming a run.
+func (c *ContextManager) Restore(s ContextState) {
+	c.task, c.summary = s.Task, s.Summary
+}
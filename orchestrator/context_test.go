@@ -0,0 +1,134 @@
+package orchestrator
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// testConversation builds a pinned prefix followed by n assistant/pane turns.
+func testConversation(n int) []Message {
+	msgs := []Message{
+		{Role: "system", Content: "sys"},
+		{Role: "user", Content: "Task: build it"},
+	}
+	for i := 1; i <= n; i++ {
+		msgs = append(msgs,
+			Message{Role: "assistant", Content: fmt.Sprintf("cmd %d", i)},
+			Message{Role: "user", Content: fmt.Sprintf("Agent output:\npane %d\n%s", i, strings.Repeat("x", 400))},
+		)
+	}
+	return msgs
+}
+
+// Token estimates grow with content and count tool call arguments.
+func TestEstimateTokens(t *testing.T) {
+	small := EstimateTokens([]Message{{Role: "user", Content: "hi"}})
+	big := EstimateTokens([]Message{{Role: "user", Content: strings.Repeat("a", 4000)}})
+	if small <= 0 || big < 1000 {
+		t.Fatalf("unexpected estimates: small=%d big=%d", small, big)
+	}
+	withCall := EstimateTokens([]Message{{Role: "assistant", ToolCalls: []ToolCall{toolCall(ToolTypeText, strings.Repeat("b", 400))}}})
+	if withCall < 100 {
+		t.Fatalf("tool call arguments should count, got %d", withCall)
+	}
+}
+
+// View keeps only the latest KeepPanes captures verbatim and leaves the input untouched.
+func TestContextManager_View(t *testing.T) {
+	c := &ContextManager{KeepPanes: 2, PanePrefix: "Agent output:\n"}
+	msgs := testConversation(4)
+	view := c.View(msgs)
+
+	for i, want := range map[int]bool{3: false, 5: false, 7: true, 9: true} {
+		verbatim := strings.Contains(view[i].Content, "xxxx")
+		if verbatim != want {
+			t.Fatalf("message %d verbatim=%v, want %v: %q", i, verbatim, want, view[i].Content)
+		}
+	}
+	if !strings.Contains(view[3].Content, "elided") {
+		t.Fatalf("old pane should be replaced by a placeholder: %q", view[3].Content)
+	}
+	if !strings.Contains(msgs[3].Content, "xxxx") {
+		t.Fatal("View must not modify its input")
+	}
+}
+
+// Fit is a no-op while the conversation is under budget.
+func TestContextManager_FitUnderBudget(t *testing.T) {
+	c := &ContextManager{MaxTokens: 100000, Summarize: func(string) (string, error) {
+		t.Fatal("should not summarize under budget")
+		return "", nil
+	}}
+	msgs := testConversation(3)
+	got, err := c.Fit(msgs)
+	if err != nil || len(got) != len(msgs) {
+		t.Fatalf("Fit: %v, %d messages", err, len(got))
+	}
+}
+
+// Fit folds the oldest turns into a summary pinned to the task message.
+func TestContextManager_FitSummarizes(t *testing.T) {
+	var prompts []string
+	c := &ContextManager{MaxTokens: 400, Summarize: func(prompt string) (string, error) {
+		prompts = append(prompts, prompt)
+		return fmt.Sprintf("- summary %d", len(prompts)), nil
+	}}
+	got, err := c.Fit(testConversation(6))
+	if err != nil {
+		t.Fatalf("Fit: %v", err)
+	}
+	if len(prompts) == 0 {
+		t.Fatal("expected a summarization call")
+	}
+	if !strings.Contains(prompts[0], "cmd 1") || !strings.Contains(prompts[0], "pane 1") {
+		t.Fatalf("first prompt should contain the oldest turn:\n%s", prompts[0])
+	}
+	if got[0].Content != "sys" {
+		t.Fatal("system prompt must stay pinned")
+	}
+	if !strings.HasPrefix(got[1].Content, "Task: build it") || !strings.Contains(got[1].Content, c.Summary()) {
+		t.Fatalf("task message should keep the task and carry the summary: %q", got[1].Content)
+	}
+	if got[2].Role != "assistant" {
+		t.Fatalf("kept history should start at a turn boundary, got %q", got[2].Role)
+	}
+	if last := got[len(got)-1].Content; !strings.Contains(last, "pane 6") {
+		t.Fatalf("latest turn must be kept, got %q", last)
+	}
+	if EstimateTokens(got) > 400 && len(turnStarts(got)) > 1 {
+		t.Fatalf("conversation still over budget: %d tokens", EstimateTokens(got))
+	}
+}
+
+// Turns with tool calls are never split from their tool results.
+func TestContextManager_FitKeepsToolResults(t *testing.T) {
+	msgs := []Message{{Role: "system", Content: "sys"}, {Role: "user", Content: "task"}}
+	for i := 0; i < 6; i++ {
+		msgs = append(msgs,
+			Message{Role: "assistant", ToolCalls: []ToolCall{toolCall(ToolTypeText, `{"text":"ls"}`)}},
+			Message{Role: "tool", ToolCallID: "call_1", Content: strings.Repeat("y", 400)},
+		)
+	}
+	c := &ContextManager{MaxTokens: 300, Summarize: func(string) (string, error) { return "done stuff", nil }}
+	got, _ := c.Fit(msgs)
+	if got[2].Role != "assistant" || len(got[2].ToolCalls) == 0 {
+		t.Fatalf("first kept message should be the assistant tool call, got %+v", got[2])
+	}
+}
+
+// A failing summarizer drops the folded turns with a note and reports the error.
+func TestContextManager_FitSummarizeError(t *testing.T) {
+	c := &ContextManager{MaxTokens: 300, Summarize: func(string) (string, error) { return "", errors.New("boom") }}
+	got, err := c.Fit(testConversation(6))
+	if err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Fatalf("expected summarizer error, got %v", err)
+	}
+	if len(got) >= len(testConversation(6)) {
+		t.Fatal("conversation should still be shortened")
+	}
+	if !strings.Contains(got[1].Content, "dropped without a summary") {
+		t.Fatalf("task message should note dropped turns: %q", got[1].Content)
+	}
+}
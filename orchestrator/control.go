@@ -0,0 +1,71 @@
+package orchestrator
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dlee6018/agent-orchestrator/dashboard"
+)
+
+// pauseOrStop blocks while the operator has paused the run after iteration i
+// and reports whether the run should stop.
+func (r *runner) pauseOrStop(i int) bool {
+	ctl := r.cfg.Control
+	if ctl == nil {
+		return false
+	}
+	if !ctl.State().Paused {
+		return ctl.State().Stopped
+	}
+	r.saveCheckpoint(i, StatusRunning)
+	fmt.Fprintln(r.stdout, "\n║ Paused from the dashboard; waiting to resume...")
+	r.publishControl(i, "paused", "")
+	if ctl.WaitWhilePaused() {
+		return true
+	}
+	fmt.Fprintln(r.stdout, "║ Resumed")
+	r.publishControl(i, "resumed", "")
+	return false
+}
+
+// stop ends the run after iteration i on the operator's request. Memory is
+// saved by RunLoop's deferred saveMemory like on every other exit.
+func (r *runner) stop(i int) string {
+	r.saveCheckpoint(i, StatusStopped)
+	fmt.Fprintln(r.stderr, "\nStopped from the dashboard.")
+	r.publishControl(i, "stopped", "")
+	r.publish(dashboard.IterationEvent{
+		Type:      "complete",
+		Iteration: i,
+		Timestamp: time.Now().Format(time.RFC3339),
+		Error:     "stopped by the operator",
+		Cost:      r.spend.Cost,
+	})
+	return StatusStopped
+}
+
+// steer appends the operator's queued messages to the conversation so the
+// next LLM call sees them.
+func (r *runner) steer() {
+	ctl := r.cfg.Control
+	if ctl == nil {
+		return
+	}
+	for _, msg := range ctl.TakeSteer() {
+		fmt.Fprintf(r.stdout, "│ Operator: %s\n", msg)
+		r.messages = append(r.messages, Message{Role: "user", Content: "Message from the human operator:\n" + msg})
+		r.publishControl(r.iteration, "steered", msg)
+	}
+}
+
+// publishControl publishes a control event so every dashboard client and
+// the transcript see the operator's intervention.
+func (r *runner) publishControl(i int, action, message string) {
+	r.publish(dashboard.IterationEvent{
+		Type:      "control",
+		Iteration: i,
+		Timestamp: time.Now().Format(time.RFC3339),
+		Control:   action,
+		Message:   message,
+	})
+}
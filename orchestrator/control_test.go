@@ -0,0 +1,61 @@
+package orchestrator
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dlee6018/agent-orchestrator/dashboard"
+)
+
+// Operator messages reach the next LLM call; pause holds the loop and stop ends it.
+func TestRunLoop_Control(t *testing.T) {
+	ctl := dashboard.NewControl()
+	ctl.Steer("use the v2 API")
+	var steered string
+	calls := 0
+	cfg := quietConfig("control", func(req Request) (Completion, error) {
+		calls++
+		switch calls {
+		case 1:
+			steered = req.Messages[len(req.Messages)-1].Content
+			ctl.Pause()
+		case 2:
+			ctl.Stop()
+		}
+		return Completion{}, nil // empty tool-mode reply: the loop just asks again
+	})
+	cfg.ToolCalling, cfg.MaxIterations, cfg.Control = true, 10, ctl
+	events := make(chan dashboard.IterationEvent, 100)
+	cfg.OnEvent = func(evt dashboard.IterationEvent) { events <- evt }
+
+	done := make(chan string)
+	go func() { done <- RunLoop(cfg) }()
+	waitControl := func(action string) {
+		t.Helper()
+		timeout := time.After(5 * time.Second)
+		for {
+			select {
+			case evt := <-events:
+				if evt.Type == "control" && evt.Control == action {
+					return
+				}
+			case <-timeout:
+				t.Fatalf("no %q control event", action)
+			}
+		}
+	}
+	waitControl("paused")
+	if calls != 1 {
+		t.Fatalf("paused loop made %d calls, want 1", calls)
+	}
+	ctl.Resume()
+	waitControl("stopped")
+
+	if status := <-done; status != StatusStopped || calls != 2 {
+		t.Fatalf("RunLoop = %s after %d calls", status, calls)
+	}
+	if !strings.Contains(steered, "use the v2 API") {
+		t.Fatalf("steering message not sent to the LLM, last message %q", steered)
+	}
+}
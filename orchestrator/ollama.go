@@ -0,0 +1,148 @@
+package orchestrator
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// OllamaProvider talks to an Ollama-style local server via its /api/chat endpoint.
+// No API key is sent, so it works in air-gapped environments.
+type OllamaProvider struct {
+	BaseURL string // server root, e.g. http://localhost:11434
+}
+
+// ollamaToolCall is a tool call in Ollama's format: arguments are a JSON
+// object rather than an encoded string, and calls carry no id.
+type ollamaToolCall struct {
+	Function struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	} `json:"function"`
+}
+
+// ollamaMessage is a chat message in Ollama's format.
+type ollamaMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	ToolCalls []ollamaToolCall `json:"tool_calls,omitempty"`
+}
+
+// ollamaRequest is the request body for /api/chat.
+type ollamaRequest struct {
+	Model    string          `json:"model"`
+	Messages []ollamaMessage `json:"messages"`
+	Tools    []Tool          `json:"tools,omitempty"`
+	Stream   bool            `json:"stream"`
+	Options  struct {
+		Temperature float64 `json:"temperature"`
+	} `json:"options"`
+}
+
+// ollamaResponse is the (non-streaming) response body from /api/chat.
+type ollamaResponse struct {
+	Model           string        `json:"model"`
+	Message         ollamaMessage `json:"message"`
+	Done            bool          `json:"done"`
+	PromptEvalCount int           `json:"prompt_eval_count"`
+	EvalCount       int           `json:"eval_count"`
+}
+
+// Name implements Provider.
+func (p *OllamaProvider) Name() string { return ProviderOllama }
+
+// Complete implements Provider.
+func (p *OllamaProvider) Complete(req Request) (Completion, error) {
+	base := p.BaseURL
+	if base == "" {
+		base = DefaultOllamaBaseURL
+	}
+	payload := toOllamaRequest(req)
+
+	body, err := postJSON(p.Name(), joinURL(base, "/api/chat"), nil, payload)
+	if err != nil {
+		return Completion{}, err
+	}
+	var result ollamaResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return Completion{}, fmt.Errorf("%s: unmarshal response: %w", p.Name(), err)
+	}
+	usage := Usage{
+		PromptTokens:     result.PromptEvalCount,
+		CompletionTokens: result.EvalCount,
+		TotalTokens:      result.PromptEvalCount + result.EvalCount,
+	}
+	return Completion{Content: result.Message.Content, ToolCalls: fromOllamaToolCalls(result.Message.ToolCalls, 0), Usage: usage}, nil
+}
+
+// toOllamaRequest converts an OpenAI-style request to Ollama's /api/chat format.
+func toOllamaRequest(req Request) ollamaRequest {
+	out := ollamaRequest{Model: req.Model, Tools: req.Tools}
+	out.Options.Temperature = req.Temperature
+	for _, m := range req.Messages {
+		om := ollamaMessage{Role: m.Role, Content: m.Content}
+		for _, tc := range m.ToolCalls {
+			var call ollamaToolCall
+			call.Function.Name = tc.Function.Name
+			call.Function.Arguments = json.RawMessage(tc.Function.Arguments)
+			if !json.Valid(call.Function.Arguments) {
+				call.Function.Arguments = json.RawMessage("{}")
+			}
+			om.ToolCalls = append(om.ToolCalls, call)
+		}
+		out.Messages = append(out.Messages, om)
+	}
+	return out
+}
+
+// fromOllamaToolCalls converts Ollama tool calls, synthesizing ids numbered from start.
+func fromOllamaToolCalls(calls []ollamaToolCall, start int) []ToolCall {
+	var out []ToolCall
+	for i, c := range calls {
+		out = append(out, ToolCall{
+			ID:       fmt.Sprintf("call_%d", start+i+1),
+			Type:     "function",
+			Function: ToolCallFunction{Name: c.Function.Name, Arguments: string(c.Function.Arguments)},
+		})
+	}
+	return out
+}
+
+// CompleteStream implements StreamingProvider. Ollama streams newline-delimited
+// JSON objects rather than SSE; the final object carries the token counts.
+func (p *OllamaProvider) CompleteStream(req Request, onDelta DeltaFunc) (Completion, error) {
+	base := p.BaseURL
+	if base == "" {
+		base = DefaultOllamaBaseURL
+	}
+	payload := toOllamaRequest(req)
+	payload.Stream = true
+
+	var out Completion
+	var sb strings.Builder
+	handle := func(data []byte) (bool, error) {
+		var chunk ollamaResponse
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return false, fmt.Errorf("%s: unmarshal stream chunk: %w", p.Name(), err)
+		}
+		sb.WriteString(chunk.Message.Content)
+		emit(onDelta, chunk.Message.Content)
+		out.ToolCalls = append(out.ToolCalls, fromOllamaToolCalls(chunk.Message.ToolCalls, len(out.ToolCalls))...)
+		if chunk.Done {
+			out.Usage = Usage{
+				PromptTokens:     chunk.PromptEvalCount,
+				CompletionTokens: chunk.EvalCount,
+				TotalTokens:      chunk.PromptEvalCount + chunk.EvalCount,
+			}
+			return true, nil
+		}
+		return false, nil
+	}
+	// application/x-ndjson is read line by line; a plain JSON body is a single chunk.
+	err := postStream(p.Name(), joinURL(base, "/api/chat"), nil, payload, func(body []byte) error {
+		_, err := handle(body)
+		return err
+	}, handle)
+	out.Content = sb.String()
+	return out, err
+}
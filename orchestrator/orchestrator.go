@@ -1,252 +1,912 @@
 package orchestrator
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/dlee6018/agent-orchestrator/dashboard"
+	"github.com/dlee6018/agent-orchestrator/git"
 	"github.com/dlee6018/agent-orchestrator/memory"
 	"github.com/dlee6018/agent-orchestrator/tmux"
+	"github.com/dlee6018/agent-orchestrator/transcript"
 )
 
-// MaxIterations is the safety cap on agent loop iterations (0 means unlimited).
+// MaxIterations is the default safety cap on agent loop iterations (0 means
+// unlimited); LoopConfig.MaxIterations overrides it per run.
 var MaxIterations = 0
 
-// AutonomousLoop drives an agent CLI via an LLM agent loop.
+// LoopConfig holds everything RunLoop needs to drive one autonomous session.
+type LoopConfig struct {
+	Session   string
+	WorkDir   string
+	Command   string
+	AgentName string // display name of the inner coding agent (e.g. "Claude Code", "Codex")
+	Task      string
+	Provider  Provider
+	Model     string
+	Broker    *dashboard.SSEBroker
+	Memories  []memory.Record
+	Stream    bool // stream partial replies to the terminal and dashboard when the provider supports it
+
+	// ToolCalling makes the orchestrator act through AgentTools instead of
+	// having its whole reply typed into the pane.
+	ToolCalling bool
+	// AskHuman answers the ask_human tool; nil means no human is available.
+	AskHuman func(question string) (string, error)
+
+	// ContextTokens is the estimated prompt budget; older turns are summarized
+	// once a request would exceed it. 0 disables summarization.
+	ContextTokens int
+	// KeepPanes is how many recent pane captures are sent verbatim; 0 keeps all.
+	KeepPanes int
+	// FullPane sends the whole cleaned pane each iteration instead of only the
+	// lines added since the previous capture.
+	FullPane bool
+
+	// RunID identifies the run in checkpoints; RunDir is where they are
+	// written after every iteration. An empty RunDir disables checkpoints.
+	RunID  string
+	RunDir string
+	// Transcript records every event, LLM call and pane capture to
+	// RunDir/transcript.jsonl.
+	Transcript bool
+	// Resume restores the conversation from a checkpoint and continues from
+	// the iteration after the one it recorded.
+	Resume *Checkpoint
+
+	// MaxIterations caps this run's iterations; 0 uses the package-level
+	// MaxIterations.
+	MaxIterations int
+	// Stdout and Stderr receive the terminal log; nil means os.Stdout and
+	// os.Stderr. Concurrent runs give each its own (e.g. prefixed) writers.
+	Stdout, Stderr io.Writer
+	// OnEvent, if set, is called with every event the run publishes.
+	OnEvent func(dashboard.IterationEvent)
+
+	// Worktree is the git worktree WorkDir points at, if the run is isolated
+	// in one; it is recorded in checkpoints so resume can report on it.
+	Worktree *git.Worktree
+	// MemoryDir is where memory.json is saved; empty means WorkDir. Runs in
+	// a worktree keep memory in the original checkout.
+	MemoryDir string
+	// MemoryUserDir holds user memory shared by all projects, and
+	// MemoryBranch names the branch whose memory the run uses; empty
+	// disables that scope (see memory.Store).
+	MemoryUserDir string
+	MemoryBranch  string
+	// GitCheckpoints snapshots WorkDir to refs/checkpoints/<run-id>/<n>
+	// after every iteration (see git.SaveSnapshot). It requires WorkDir to
+	// be inside a git repository and is switched off after the first failure.
+	GitCheckpoints bool
+	// AcceptanceCommands are shell commands run in WorkDir when the LLM
+	// signals completion; completion is accepted only if all of them pass,
+	// otherwise their output is fed back and the loop continues.
+	AcceptanceCommands []string
+	// Budget caps the run's LLM tokens and cost; a run over budget stops
+	// cleanly before its next iteration.
+	Budget Budget
+	// Retry governs waits and aborts after failed LLM calls; a zero policy
+	// uses DefaultRetry.
+	Retry RetryPolicy
+	// FallbackModels are tried in order, within the same call, when Model
+	// fails with a transient or model-specific error.
+	FallbackModels []string
+	// Control, if set, lets an operator pause, stop and steer the run
+	// between iterations (normally Broker.Control() from the dashboard).
+	Control *dashboard.Control
+}
+
+// AutonomousLoop drives an agent CLI via an LLM agent loop using OpenRouter.
 // It sends the task to the LLM, relays its decisions to the agent,
 // and feeds back the pane output until the LLM signals TASK_COMPLETE.
 // If MaxIterations > 0 the loop stops after that many iterations.
 // agentName is the display name of the inner coding agent (e.g. "Claude Code", "Codex").
 // memories carries persistent facts from previous sessions; new facts
 // are extracted from MEMORY_SAVE: lines and saved on exit.
-func AutonomousLoop(session, workDir, command, apiKey, model, task, agentName string, broker *dashboard.SSEBroker, memories []string) {
-	fmt.Println("========================================")
-	fmt.Println("AUTONOMOUS MODE")
-	fmt.Printf("Model: %s\n", model)
-	if MaxIterations > 0 {
-		fmt.Printf("Max iterations: %d\n", MaxIterations)
+func AutonomousLoop(session, workDir, command, apiKey, model, task, agentName string, broker *dashboard.SSEBroker, memories []memory.Record) {
+	RunLoop(LoopConfig{
+		Session:   session,
+		WorkDir:   workDir,
+		Command:   command,
+		AgentName: agentName,
+		Task:      task,
+		Provider:  &OpenRouterProvider{APIKey: apiKey},
+		Model:     model,
+		Broker:    broker,
+		Memories:  memories,
+	})
+}
+
+// RunLoop is AutonomousLoop with an explicit configuration, so the
+// orchestrator LLM can be served by any Provider. It returns the final run
+// status: StatusComplete when the task is done, StatusAborted on a fatal LLM
+// error or replay drift, StatusMaxIterations at the iteration cap,
+// StatusBudgetExceeded when cfg.Budget runs out, or StatusStopped when an
+// operator stops the run through cfg.Control. RunLoop only touches state
+// owned by cfg, so several runs with distinct sessions and working
+// directories may execute concurrently (see Supervisor).
+func RunLoop(cfg LoopConfig) string {
+	r := newRunner(cfg)
+	cfg = r.cfg
+	task, model := cfg.Task, cfg.Model
+
+	fmt.Fprintln(r.stdout, "========================================")
+	fmt.Fprintln(r.stdout, "AUTONOMOUS MODE")
+	fmt.Fprintf(r.stdout, "Provider: %s\n", cfg.Provider.Name())
+	fmt.Fprintf(r.stdout, "Model: %s\n", model)
+	if len(cfg.FallbackModels) > 0 {
+		fmt.Fprintf(r.stdout, "Fallback models: %s\n", strings.Join(cfg.FallbackModels, ", "))
+	}
+	if cfg.ToolCalling {
+		fmt.Fprintln(r.stdout, "Actions: tool calls")
+	}
+	if cfg.MaxIterations > 0 {
+		fmt.Fprintf(r.stdout, "Max iterations: %d\n", cfg.MaxIterations)
 	} else {
-		fmt.Println("Max iterations: unlimited")
+		fmt.Fprintln(r.stdout, "Max iterations: unlimited")
+	}
+	fmt.Fprintf(r.stdout, "Task: %s\n", task)
+	if cfg.RunDir != "" {
+		fmt.Fprintf(r.stdout, "Run: %s (%s)\n", cfg.RunID, cfg.RunDir)
+	}
+	fmt.Fprintln(r.stdout, "========================================")
+
+	if cfg.Transcript && cfg.RunDir != "" {
+		tw, err := transcript.Open(filepath.Join(cfg.RunDir, transcript.FileName))
+		if err != nil {
+			fmt.Fprintf(r.stderr, "warning: failed to open transcript: %v\n", err)
+		} else {
+			r.transcript = tw
+			defer tw.Close()
+		}
 	}
-	fmt.Printf("Task: %s\n", task)
-	fmt.Println("========================================")
 
-	broker.Publish(dashboard.IterationEvent{
+	r.publish(dashboard.IterationEvent{
 		Type:      "task_info",
 		Timestamp: time.Now().Format(time.RFC3339),
-		MaxIter:   MaxIterations,
+		MaxIter:   cfg.MaxIterations,
 		Task:      task,
 		Model:     model,
+		MaxCost:   cfg.Budget.MaxCost,
+		Cost:      r.spend.Cost,
 	})
 
+	if cfg.Resume == nil {
+		// The memory the run starts from, so a replay can start from it too.
+		r.record(transcript.Record{Kind: transcript.KindMemory, Memories: cfg.Memories})
+	}
+
 	// Save memory on exit (deferred early so it runs on all exit paths).
-	defer func() {
-		if len(memories) > 0 {
-			if err := memory.SaveMemory(workDir, memories); err != nil {
-				fmt.Fprintf(os.Stderr, "warning: failed to save memory: %v\n", err)
-			} else {
-				fmt.Printf("Saved %d memory facts to %s\n", len(memories), memory.FileName)
-			}
-		}
-	}()
+	defer r.saveMemory()
 
-	messages := []Message{
-		{Role: "system", Content: BuildSystemPrompt(agentName, memories)},
-		{Role: "user", Content: fmt.Sprintf("Task: %s\n\nYou are now connected to the %s CLI. Send your first message to begin working on the task.", task, agentName)},
+	start := 1
+	if cp := cfg.Resume; cp != nil {
+		r.restore(cp)
+		start = cp.Iteration + 1
+		fmt.Fprintf(r.stdout, "Resuming run %s after iteration %d (%d messages)\n", cp.RunID, cp.Iteration, len(r.messages))
+	} else {
+		if n := len(r.recall()); n < len(r.memories) {
+			fmt.Fprintf(r.stdout, "Recalled %d of %d memory facts relevant to the task\n", n, len(r.memories))
+		}
+		r.messages = []Message{
+			{Role: "system", Content: r.systemPrompt()},
+			{Role: "user", Content: fmt.Sprintf("Task: %s\n\nYou are now connected to the %s CLI. Send your first message to begin working on the task.", task, cfg.AgentName)},
+		}
 	}
 
-	lastPane := ""
 	consecutiveAPIErrors := 0
 
-	for i := 1; MaxIterations == 0 || i <= MaxIterations; i++ {
+	for i := start; cfg.MaxIterations == 0 || i <= cfg.MaxIterations; i++ {
+		if r.pauseOrStop(i - 1) {
+			return r.stop(i - 1)
+		}
+		if reason := cfg.Budget.Exceeded(r.spend); reason != "" {
+			return r.stopForBudget(i-1, reason)
+		}
+		// Persist the previous iteration before starting the next one.
+		r.saveCheckpoint(i-1, StatusRunning)
 		iterStart := time.Now()
+		r.iteration = i
 
-		if MaxIterations > 0 {
-			fmt.Printf("\n┌─── Iteration %d/%d ───────────────────────\n", i, MaxIterations)
+		if cfg.MaxIterations > 0 {
+			fmt.Fprintf(r.stdout, "\n┌─── Iteration %d/%d ───────────────────────\n", i, cfg.MaxIterations)
 		} else {
-			fmt.Printf("\n┌─── Iteration %d ─────────────────────────\n", i)
+			fmt.Fprintf(r.stdout, "\n┌─── Iteration %d ─────────────────────────\n", i)
 		}
 
-		broker.Publish(dashboard.IterationEvent{
+		r.publish(dashboard.IterationEvent{
 			Type:      "iteration_start",
 			Iteration: i,
-			MaxIter:   MaxIterations,
+			MaxIter:   cfg.MaxIterations,
 			Timestamp: iterStart.Format(time.RFC3339),
 		})
 
-		// Compact memory if it exceeds the threshold.
-		if len(memories) > memory.MaxFacts {
-			fmt.Printf("│ Memory has %d facts (threshold %d), compacting...\n", len(memories), memory.MaxFacts)
-			compactFn := func(prompt string) (string, error) {
-				msgs := []Message{{Role: "user", Content: prompt}}
-				reply, _, err := CallOpenRouter(apiKey, model, msgs, 0.2)
-				return reply, err
-			}
-			compacted, compactErr := memory.CompactMemory(compactFn, memories)
-			if compactErr != nil {
-				fmt.Fprintf(os.Stderr, "│ Memory compaction failed (non-fatal): %v\n", compactErr)
-			} else {
-				fmt.Printf("│ Compacted memory: %d → %d facts\n", len(memories), len(compacted))
-				memories = compacted
-				// Rebuild system prompt with compacted memories.
-				messages[0] = Message{Role: "system", Content: BuildSystemPrompt(agentName, memories)}
-			}
-		}
+		r.steer()
+		r.warnBudget()
+		r.compactMemory()
+		r.fitContext()
 
 		// Call the orchestrator LLM.
-		reply, usage, err := CallOpenRouter(apiKey, model, messages, 0.3)
+		completion, streamed, err := r.callLLM(transcript.PurposeTurn, r.request())
+		if errors.Is(err, ErrReplayDrift) || errors.Is(err, ErrReplayExhausted) {
+			// Retrying cannot fix a replay that diverged from its recording.
+			fmt.Fprintf(r.stderr, "│ REPLAY FAILED: %v\n", err)
+			r.saveCheckpoint(i-1, StatusAborted)
+			r.publish(dashboard.IterationEvent{
+				Type:      "complete",
+				Iteration: i,
+				Timestamp: time.Now().Format(time.RFC3339),
+				Error:     err.Error(),
+			})
+			return StatusAborted
+		}
 		if err != nil {
 			consecutiveAPIErrors++
-			fmt.Fprintf(os.Stderr, "│ API ERROR (%d/3): %v\n", consecutiveAPIErrors, err)
-			broker.Publish(dashboard.IterationEvent{
+			retry := cfg.Retry
+			fmt.Fprintf(r.stderr, "│ API ERROR (%d/%d): %v\n", consecutiveAPIErrors, retry.MaxAttempts, err)
+			r.publish(dashboard.IterationEvent{
 				Type:      "error",
 				Iteration: i,
 				Timestamp: time.Now().Format(time.RFC3339),
-				Error:     fmt.Sprintf("API error (%d/3): %v", consecutiveAPIErrors, err),
+				Error:     fmt.Sprintf("API error (%d/%d): %v", consecutiveAPIErrors, retry.MaxAttempts, err),
 			})
-			if consecutiveAPIErrors >= 3 {
-				fmt.Fprintln(os.Stderr, "│ Too many consecutive API errors, aborting.")
-				broker.Publish(dashboard.IterationEvent{
+			if reason := abortReason(err, consecutiveAPIErrors, retry); reason != "" {
+				fmt.Fprintf(r.stderr, "│ %s\n", reason)
+				r.saveCheckpoint(i-1, StatusAborted)
+				r.publish(dashboard.IterationEvent{
 					Type:      "complete",
 					Iteration: i,
 					Timestamp: time.Now().Format(time.RFC3339),
-					Error:     "aborted after 3 consecutive API errors",
+					Error:     reason,
 				})
-				return
+				return StatusAborted
 			}
-			fmt.Fprintln(os.Stderr, "│ Retrying in 5s...")
-			time.Sleep(5 * time.Second)
-			if MaxIterations > 0 {
+			delay := retry.Delay(consecutiveAPIErrors, err)
+			fmt.Fprintf(r.stderr, "│ Retrying in %s...\n", delay.Round(100*time.Millisecond))
+			time.Sleep(delay)
+			if cfg.MaxIterations > 0 {
 				i-- // Don't count API errors toward iteration limit.
 			}
 			continue
 		}
 		consecutiveAPIErrors = 0
+		reply, usage := completion.Content, completion.Usage
+		r.model = completion.Model
+		if r.model != cfg.Model {
+			fmt.Fprintf(r.stdout, "│ Answered by fallback model %s\n", r.model)
+		}
 
-		fmt.Printf("│ Tokens: prompt=%d completion=%d total=%d\n", usage.PromptTokens, usage.CompletionTokens, usage.TotalTokens)
+		// Log the LLM's decision (already printed token by token when streamed).
+		if !streamed && (reply != "" || len(completion.ToolCalls) == 0) {
+			fmt.Fprintln(r.stdout, "│")
+			fmt.Fprintf(r.stdout, "│ ╔══ ORCHESTRATOR → %s ══════════\n", strings.ToUpper(cfg.AgentName))
+			for _, line := range strings.Split(reply, "\n") {
+				fmt.Fprintf(r.stdout, "│ ║ %s\n", line)
+			}
+			fmt.Fprintln(r.stdout, "│ ╚════════════════════════════════════════")
+		}
+		fmt.Fprintf(r.stdout, "│ Tokens: prompt=%d completion=%d total=%d\n", usage.PromptTokens, usage.CompletionTokens, usage.TotalTokens)
 
-		// Log the LLM's decision.
-		fmt.Println("│")
-		fmt.Printf("│ ╔══ ORCHESTRATOR → %s ══════════\n", strings.ToUpper(agentName))
-		for _, line := range strings.Split(reply, "\n") {
-			fmt.Printf("│ ║ %s\n", line)
+		if cfg.ToolCalling && len(completion.ToolCalls) > 0 {
+			if r.runToolCalls(i, iterStart, completion) {
+				return StatusComplete
+			}
+			continue
 		}
-		fmt.Println("│ ╚════════════════════════════════════════")
 
-		// Extract memory saves from the reply.
+		// Extract memory edits and saves from the reply.
+		edits, cleanedReply := memory.ExtractMemoryEdits(reply)
+		if len(edits) > 0 {
+			for _, e := range edits {
+				r.editMemory(e)
+			}
+			reply = cleanedReply
+		}
 		newFacts, cleanedReply := memory.ExtractMemorySaves(reply)
 		if len(newFacts) > 0 {
-			memories = append(memories, newFacts...)
-			memories = memory.DeduplicateMemory(memories)
-			fmt.Printf("│ Saved %d new memory fact(s) (total: %d)\n", len(newFacts), len(memories))
+			r.addMemories(newFacts)
 			reply = cleanedReply
 		}
 
-		// Check for task completion.
-		if strings.Contains(reply, TaskCompleteMarker) {
-			fmt.Println("│")
-			fmt.Println("│ *** TASK COMPLETE ***")
-			fmt.Printf("└─── Finished after %d iterations ────────\n", i)
-			messages = append(messages, Message{Role: "assistant", Content: reply})
-			broker.Publish(dashboard.IterationEvent{
-				Type:       "iteration_end",
-				Iteration:  i,
-				MaxIter:    MaxIterations,
-				Timestamp:  time.Now().Format(time.RFC3339),
-				DurationMs: time.Since(iterStart).Milliseconds(),
-				Tokens: &dashboard.TokenUsage{
-					Prompt:     usage.PromptTokens,
-					Completion: usage.CompletionTokens,
-					Total:      usage.TotalTokens,
-				},
-				Orchestrator: reply,
-			})
-			broker.Publish(dashboard.IterationEvent{
-				Type:      "complete",
-				Iteration: i,
-				Timestamp: time.Now().Format(time.RFC3339),
-				Task:      task,
-			})
-			return
+		// Check for task completion. In tool mode only complete_task ends the
+		// run, so a marker quoted inside normal text is not a false positive.
+		if !cfg.ToolCalling && strings.Contains(reply, TaskCompleteMarker) {
+			r.messages = append(r.messages, Message{Role: "assistant", Content: reply})
+			if feedback := r.verify(); feedback != "" {
+				r.publishIterationEnd(i, iterStart, usage, reply, "", "acceptance checks failed")
+				r.messages = append(r.messages, Message{Role: "user", Content: feedback})
+				fmt.Fprintf(r.stdout, "└─────────────────────────────────────────\n")
+				continue
+			}
+			r.publishIterationEnd(i, iterStart, usage, reply, "", "")
+			r.finish(i)
+			return StatusComplete
 		}
 
-		// Send the LLM's reply to Claude Code.
-		pane, err := tmux.SendAndCaptureWithRecovery(session, workDir, command, reply, lastPane)
-
-		// If the agent is still working, keep polling instead of calling the LLM.
-		for err != nil && strings.Contains(err.Error(), "agent is still working") {
-			fmt.Printf("│ %s is still working, waiting for output...\n", agentName)
-			lastPane = pane
-			pane, err = tmux.WaitForPaneUpdate(session, lastPane, 90*time.Second)
+		// In tool mode plain text is a private note: it is never typed into
+		// the pane, and the LLM is nudged to act through a tool instead.
+		if cfg.ToolCalling {
+			problem := "reply without tool calls"
+			nudge := fmt.Sprintf("Your reply had no tool call, so nothing was sent to %s. Call one of the tools to act.", cfg.AgentName)
+			if strings.TrimSpace(reply) == "" {
+				problem, nudge = "empty reply without tool calls", "Your reply was empty. Call one of the tools to act."
+			}
+			fmt.Fprintf(r.stderr, "│ Not sent: %s\n", problem)
+			r.publishIterationEnd(i, iterStart, usage, reply, "", problem)
+			r.messages = append(r.messages,
+				Message{Role: "assistant", Content: reply},
+				Message{Role: "user", Content: nudge},
+			)
+			fmt.Fprintf(r.stdout, "└─────────────────────────────────────────\n")
+			continue
 		}
 
+		// Send the LLM's reply to the agent.
+		pane, err := r.sendAndCapture(reply)
 		if err != nil {
-			errMsg := fmt.Sprintf("Error sending to %s: %v", agentName, err)
-			fmt.Fprintf(os.Stderr, "│ TMUX ERROR: %v\n", err)
-			broker.Publish(dashboard.IterationEvent{
-				Type:       "iteration_end",
-				Iteration:  i,
-				MaxIter:    MaxIterations,
-				Timestamp:  time.Now().Format(time.RFC3339),
-				DurationMs: time.Since(iterStart).Milliseconds(),
-				Tokens: &dashboard.TokenUsage{
-					Prompt:     usage.PromptTokens,
-					Completion: usage.CompletionTokens,
-					Total:      usage.TotalTokens,
-				},
-				Orchestrator: reply,
-				Error:        fmt.Sprintf("tmux error: %v", err),
-			})
+			fmt.Fprintf(r.stderr, "│ TMUX ERROR: %v\n", err)
+			r.publishIterationEnd(i, iterStart, usage, reply, "", fmt.Sprintf("tmux error: %v", err))
 			// Feed the error back so the LLM can adapt.
-			messages = append(messages,
+			r.messages = append(r.messages,
 				Message{Role: "assistant", Content: reply},
-				Message{Role: "user", Content: errMsg},
+				Message{Role: "user", Content: fmt.Sprintf("Error sending to %s: %v", cfg.AgentName, err)},
 			)
 			continue
 		}
 
-		cleaned := tmux.CleanPaneOutput(pane)
-
-		// Log the agent's response.
-		fmt.Println("│")
-		fmt.Printf("│ ╔══ %s OUTPUT ══════════════════\n", strings.ToUpper(agentName))
-		for _, line := range strings.Split(cleaned, "\n") {
-			fmt.Printf("│ ║ %s\n", line)
-		}
-		fmt.Println("│ ╚════════════════════════════════════════")
-		fmt.Printf("└─────────────────────────────────────────\n")
-
-		broker.Publish(dashboard.IterationEvent{
-			Type:       "iteration_end",
-			Iteration:  i,
-			MaxIter:    MaxIterations,
-			Timestamp:  time.Now().Format(time.RFC3339),
-			DurationMs: time.Since(iterStart).Milliseconds(),
-			Tokens: &dashboard.TokenUsage{
-				Prompt:     usage.PromptTokens,
-				Completion: usage.CompletionTokens,
-				Total:      usage.TotalTokens,
-			},
-			Orchestrator: reply,
-			ClaudeOutput: cleaned,
-			AgentOutput:  cleaned,
-		})
+		cleaned := r.logAgentOutput(pane)
+		r.recordPane(pane, cleaned)
+		fmt.Fprintf(r.stdout, "└─────────────────────────────────────────\n")
+		r.publishIterationEnd(i, iterStart, usage, reply, pane, "")
 
 		// Append to conversation history.
-		messages = append(messages,
+		r.messages = append(r.messages,
 			Message{Role: "assistant", Content: reply},
-			Message{Role: "user", Content: fmt.Sprintf("%s output:\n%s", agentName, cleaned)},
+			Message{Role: "user", Content: r.paneMessage(cleaned)},
 		)
-		lastPane = pane
+		r.lastPane = pane
 	}
 
-	fmt.Fprintf(os.Stderr, "\nReached maximum iterations (%d) without task completion.\n", MaxIterations)
-	broker.Publish(dashboard.IterationEvent{
+	r.saveCheckpoint(cfg.MaxIterations, StatusMaxIterations)
+	fmt.Fprintf(r.stderr, "\nReached maximum iterations (%d) without task completion.\n", cfg.MaxIterations)
+	r.publish(dashboard.IterationEvent{
 		Type:      "complete",
-		Iteration: MaxIterations,
+		Iteration: cfg.MaxIterations,
 		Timestamp: time.Now().Format(time.RFC3339),
-		Error:     fmt.Sprintf("reached maximum iterations (%d) without task completion", MaxIterations),
+		Error:     fmt.Sprintf("reached maximum iterations (%d) without task completion", cfg.MaxIterations),
+		Cost:      r.spend.Cost,
 	})
+	return StatusMaxIterations
+}
+
+// runner holds the mutable state of one RunLoop session.
+type runner struct {
+	cfg      LoopConfig
+	messages []Message
+	memories []memory.Record
+	loaded   []memory.Record // memories as loaded, the base of this run's changes
+	forgot   bool            // a fact was forgotten, so memory is saved even when empty
+	lastPane string
+	lastSeen string // cleaned pane last reported to the LLM
+	deltas   int    // pane messages sent as deltas since the last full capture
+	context  *ContextManager
+
+	startedAt    time.Time
+	iteration    int    // current iteration, for transcript records
+	model        string // model that answered the current iteration
+	spend        Spend
+	budgetWarned bool // the LLM was told to wrap up
+	transcript   *transcript.Writer
+
+	stdout, stderr io.Writer // terminal log
+}
+
+// newRunner prepares the loop state for cfg, filling in defaults.
+func newRunner(cfg LoopConfig) *runner {
+	if cfg.MaxIterations == 0 {
+		cfg.MaxIterations = MaxIterations
+	}
+	if cfg.Retry.MaxAttempts == 0 {
+		cfg.Retry = DefaultRetry
+	}
+	if _, ok := cfg.Provider.(*ReplayProvider); ok {
+		// A replay re-runs a recorded session and must leave no trace in the
+		// run history, git refs or memory of real runs.
+		cfg.RunDir, cfg.GitCheckpoints = "", false
+	}
+	if cfg.GitCheckpoints && cfg.RunID == "" {
+		cfg.RunID = NewRunID()
+	}
+	r := &runner{cfg: cfg, memories: cfg.Memories, loaded: cfg.Memories, startedAt: time.Now(), stdout: cfg.Stdout, stderr: cfg.Stderr}
+	r.memories = memory.MarkUsed(r.memories, r.recall(), r.startedAt)
+	if r.stdout == nil {
+		r.stdout = os.Stdout
+	}
+	if r.stderr == nil {
+		r.stderr = os.Stderr
+	}
+	r.context = &ContextManager{
+		MaxTokens:  cfg.ContextTokens,
+		KeepPanes:  cfg.KeepPanes,
+		PanePrefix: r.agentOutputMessage(""),
+		Summarize: func(prompt string) (string, error) {
+			msgs := []Message{{Role: "user", Content: prompt}}
+			c, _, err := r.callLLM(transcript.PurposeSummary, Request{Model: cfg.Model, Messages: msgs, Temperature: 0.2})
+			return c.Content, err
+		},
+	}
+	return r
+}
+
+// restore reinstates the loop state recorded in cp.
+func (r *runner) restore(cp *Checkpoint) {
+	r.messages = cp.Messages
+	r.memories, r.loaded = cp.Memories, cp.MemoryBase
+	r.lastPane = cp.LastPane
+	r.lastSeen, r.deltas = cp.LastSeen, cp.PaneDeltas
+	r.spend, r.budgetWarned = cp.Spend, cp.BudgetWarned
+	r.context.Restore(cp.Context)
+	if !cp.StartedAt.IsZero() {
+		r.startedAt = cp.StartedAt
+	}
+}
+
+// saveCheckpoint persists the loop state after iteration i with the given
+// status. Failures are logged but never stop the run.
+func (r *runner) saveCheckpoint(i int, status string) {
+	cfg := r.cfg
+	if cfg.RunDir == "" {
+		return
+	}
+	cp := &Checkpoint{
+		RunID:          cfg.RunID,
+		Status:         status,
+		Session:        cfg.Session,
+		Socket:         tmux.Socket,
+		WorkDir:        cfg.WorkDir,
+		Command:        cfg.Command,
+		AgentName:      cfg.AgentName,
+		Task:           cfg.Task,
+		Provider:       cfg.Provider.Name(),
+		Model:          cfg.Model,
+		FallbackModels: cfg.FallbackModels,
+		ToolCalling:    cfg.ToolCalling,
+		Worktree:       cfg.Worktree,
+		MemoryDir:      cfg.MemoryDir,
+		MemoryUserDir:  cfg.MemoryUserDir,
+		MemoryBranch:   cfg.MemoryBranch,
+		Acceptance:     cfg.AcceptanceCommands,
+		Iteration:      i,
+		Messages:       r.messages,
+		Memories:       r.memories,
+		MemoryBase:     r.loaded,
+		LastPane:       r.lastPane,
+		LastSeen:       r.lastSeen,
+		PaneDeltas:     r.deltas,
+		Context:        r.context.State(),
+		Spend:          r.spend,
+		BudgetWarned:   r.budgetWarned,
+		StartedAt:      r.startedAt,
+		UpdatedAt:      time.Now(),
+	}
+	if err := SaveCheckpoint(cfg.RunDir, cp); err != nil {
+		fmt.Fprintf(r.stderr, "│ warning: failed to save checkpoint: %v\n", err)
+	}
+}
+
+// systemPrompt builds the system prompt for the configured action mode.
+func (r *runner) systemPrompt() string {
+	facts := memory.Labels(r.recall())
+	if r.cfg.ToolCalling {
+		return BuildToolSystemPrompt(r.cfg.AgentName, facts) + AcceptancePrompt(r.cfg.AcceptanceCommands)
+	}
+	return BuildSystemPrompt(r.cfg.AgentName, facts) + AcceptancePrompt(r.cfg.AcceptanceCommands)
+}
+
+// recall returns the memory facts most relevant to the task, at most
+// memory.TopK, leaving out facts shadowed by the same fact in a narrower scope.
+func (r *runner) recall() []memory.Record {
+	return memory.Retrieve(memory.Visible(r.memories), r.cfg.Task, memory.TopK)
+}
+
+// request builds the next orchestrator LLM request from the conversation.
+func (r *runner) request() Request {
+	req := Request{Model: r.cfg.Model, Messages: r.context.View(r.messages), Temperature: 0.3}
+	if r.cfg.ToolCalling {
+		req.Tools = AgentTools(r.cfg.AgentName)
+	}
+	return req
+}
+
+// currentModel returns the model that answered the last LLM call, or the
+// configured model before the first one.
+func (r *runner) currentModel() string {
+	if r.model != "" {
+		return r.model
+	}
+	return r.cfg.Model
+}
+
+// addMemories merges new facts into the session memory, recording the run
+// and model that learned them. A fact already in its scope counts as a hit.
+func (r *runner) addMemories(facts []memory.Fact, tags ...string) {
+	store := r.memoryStore()
+	for _, fact := range facts {
+		rec := memory.NewRecord(fact.Text, r.cfg.RunID, r.currentModel(), tags...)
+		rec.Scope = store.Resolve(fact.Scope)
+		r.memories = append(r.memories, rec)
+	}
+	r.memories = memory.DeduplicateMemory(r.memories)
+	fmt.Fprintf(r.stdout, "│ Saved %d new memory fact(s) (total: %d)\n", len(facts), len(r.memories))
+}
+
+// editMemory forgets or rewrites the memory fact e targets, logging the
+// change or why nothing matched.
+func (r *runner) editMemory(e memory.Edit) error {
+	updated, old, err := memory.Apply(r.memories, e, r.cfg.RunID, r.currentModel())
+	if err != nil {
+		fmt.Fprintf(r.stdout, "│ Memory unchanged: %v\n", err)
+		return err
+	}
+	r.memories = updated
+	if e.Forget() {
+		r.forgot = true
+		fmt.Fprintf(r.stdout, "│ Forgot memory fact [%s] %q (total: %d)\n", old.ID, old.Text, len(r.memories))
+	} else {
+		fmt.Fprintf(r.stdout, "│ Updated memory fact [%s] %q → %q\n", old.ID, old.Text, e.Text)
+	}
+	return nil
+}
+
+// compactMemory summarizes each memory scope with the LLM when it exceeds
+// memory.MaxFacts.
+func (r *runner) compactMemory() {
+	store := r.memoryStore()
+	compactFn := func(prompt string) (string, error) {
+		msgs := []Message{{Role: "user", Content: prompt}}
+		c, _, err := r.callLLM(transcript.PurposeMemoryCompaction, Request{Model: r.cfg.Model, Messages: msgs, Temperature: 0.2})
+		return c.Content, err
+	}
+	var all []memory.Record
+	changed := false
+	for _, scope := range memory.Scopes {
+		var records []memory.Record
+		for _, rec := range r.memories {
+			if store.Resolve(rec.Scope) == scope {
+				records = append(records, rec)
+			}
+		}
+		if len(records) <= memory.MaxFacts {
+			all = append(all, records...)
+			continue
+		}
+		fmt.Fprintf(r.stdout, "│ Memory has %d %s facts (threshold %d), compacting...\n", len(records), scope, memory.MaxFacts)
+		compacted, err := memory.CompactMemory(compactFn, records)
+		if err != nil {
+			fmt.Fprintf(r.stderr, "│ Memory compaction failed (non-fatal): %v\n", err)
+		} else {
+			fmt.Fprintf(r.stdout, "│ Compacted %s memory: %d → %d facts\n", scope, len(records), len(compacted))
+			changed = true
+		}
+		all = append(all, compacted...)
+	}
+	if !changed {
+		return
+	}
+	r.memories = all
+	// Rebuild system prompt with compacted memories.
+	r.messages[0] = Message{Role: "system", Content: r.systemPrompt()}
+}
+
+// fitContext summarizes older turns when the conversation outgrows the context budget.
+func (r *runner) fitContext() {
+	before := len(r.messages)
+	fitted, err := r.context.Fit(r.messages)
+	if err != nil {
+		fmt.Fprintf(r.stderr, "│ Context summarization failed, dropped older turns: %v\n", err)
+	}
+	if len(fitted) < before {
+		// The folded turns may hold the pane the next delta would build on.
+		r.lastSeen = ""
+		fmt.Fprintf(r.stdout, "│ Context over %d tokens: folded %d older messages into the summary (now ~%d tokens)\n",
+			r.context.MaxTokens, before-len(fitted), EstimateTokens(r.context.View(fitted)))
+	}
+	r.messages = fitted
+}
+
+// memoryStore locates the run's memory files.
+func (r *runner) memoryStore() memory.Store {
+	dir := r.cfg.MemoryDir
+	if dir == "" {
+		dir = r.cfg.WorkDir
+	}
+	return memory.Store{UserDir: r.cfg.MemoryUserDir, ProjectDir: dir, Branch: r.cfg.MemoryBranch}
+}
+
+// saveMemory merges the session's memory changes into the files of its
+// scopes, keeping facts other runs saved meanwhile.
+func (r *runner) saveMemory() {
+	if len(r.memories) == 0 && !r.forgot {
+		return
+	}
+	if _, ok := r.cfg.Provider.(*ReplayProvider); ok {
+		fmt.Fprintln(r.stdout, "Replay: memory not saved")
+		return
+	}
+	if err := r.memoryStore().Save(r.loaded, r.memories); err != nil {
+		fmt.Fprintf(r.stderr, "warning: failed to save memory: %v\n", err)
+	} else {
+		fmt.Fprintf(r.stdout, "Saved %d memory facts to %s\n", len(r.memories), memory.FileName)
+	}
+}
+
+// sendAndCapture types text into the agent, presses Enter and returns the
+// settled pane. While the agent is still working it keeps polling instead of
+// going back to the LLM.
+func (r *runner) sendAndCapture(text string) (string, error) {
+	cfg := r.cfg
+	pane, err := tmux.SendAndCaptureWithRecovery(cfg.Session, cfg.WorkDir, cfg.Command, text, r.lastPane, r.publishPane)
+	for err != nil && errors.Is(err, tmux.ErrStillWorking) {
+		fmt.Fprintf(r.stdout, "│ %s is still working, waiting for output...\n", cfg.AgentName)
+		r.lastPane = pane
+		pane, err = tmux.WaitForPaneUpdate(cfg.Session, r.lastPane, 90*time.Second, r.publishPane)
+	}
+	return pane, err
+}
+
+// logAgentOutput cleans the pane, prints it in the terminal log box and returns it.
+func (r *runner) logAgentOutput(pane string) string {
+	cleaned := tmux.CleanPaneOutput(pane)
+	fmt.Fprintln(r.stdout, "│")
+	fmt.Fprintf(r.stdout, "│ ╔══ %s OUTPUT ══════════════════\n", strings.ToUpper(r.cfg.AgentName))
+	for _, line := range strings.Split(cleaned, "\n") {
+		fmt.Fprintf(r.stdout, "│ ║ %s\n", line)
+	}
+	fmt.Fprintln(r.stdout, "│ ╚════════════════════════════════════════")
+	return cleaned
+}
+
+// paneMessage formats a cleaned pane capture for the LLM. Unless cfg.FullPane
+// is set only the delta since the last reported capture is sent, since the
+// scrollback otherwise repeats the same old output every iteration. The
+// context manager elides all but the last KeepPanes captures, so every
+// KeepPanes-th capture is sent in full: the captures still shown then always
+// start from a full one and the deltas after it rebuild the current screen.
+func (r *runner) paneMessage(cleaned string) string {
+	text := cleaned
+	if !r.cfg.FullPane {
+		if keep := r.cfg.KeepPanes; keep > 0 && r.deltas >= keep-1 {
+			r.lastSeen = ""
+		}
+		if r.lastSeen == "" {
+			r.deltas = 0
+		} else {
+			r.deltas++
+		}
+		text = tmux.DiffPane(r.lastSeen, cleaned, tmux.DeltaContextLines).String()
+	}
+	r.lastSeen = cleaned
+	return r.agentOutputMessage(text)
+}
+
+// agentOutputMessage formats cleaned pane output for the LLM conversation.
+func (r *runner) agentOutputMessage(cleaned string) string {
+	return fmt.Sprintf("%s output:\n%s", r.cfg.AgentName, cleaned)
+}
+
+// publishPane publishes a pane_update event with the agent's visible screen,
+// ANSI escapes included, while the pane is still changing, so the dashboard
+// can show the agent at work. The scrollback is left out to keep the frequent
+// events small.
+func (r *runner) publishPane(string) {
+	screen, err := tmux.CaptureScreenANSI(r.cfg.Session)
+	if err != nil {
+		return
+	}
+	r.publish(dashboard.IterationEvent{
+		Type:      "pane_update",
+		Iteration: r.iteration,
+		Timestamp: time.Now().Format(time.RFC3339),
+		Pane:      screen,
+	})
+}
+
+// publishIterationEnd snapshots the working directory and publishes the
+// iteration_end event for iteration i. pane is the plain capture of the
+// agent's pane, or "" if the iteration did not observe it. For the dashboard
+// the visible screen is recaptured with its ANSI escapes; the scrollback is
+// left out, since every event is buffered and recorded in the transcript.
+func (r *runner) publishIterationEnd(i int, start time.Time, usage Usage, orchestrator, pane, errMsg string) {
+	checkpoint := r.snapshot(i)
+	agentOutput, agentANSI := "", ""
+	if pane != "" {
+		agentOutput = tmux.CleanPaneOutput(pane)
+		agentANSI, _ = tmux.CaptureScreenANSI(r.cfg.Session)
+	}
+	r.publish(dashboard.IterationEvent{
+		Type:       "iteration_end",
+		Iteration:  i,
+		MaxIter:    r.cfg.MaxIterations,
+		Timestamp:  time.Now().Format(time.RFC3339),
+		DurationMs: time.Since(start).Milliseconds(),
+		Tokens: &dashboard.TokenUsage{
+			Prompt:     usage.PromptTokens,
+			Completion: usage.CompletionTokens,
+			Total:      usage.TotalTokens,
+		},
+		Orchestrator: orchestrator,
+		AgentOutput:  agentOutput,
+		AgentANSI:    agentANSI,
+		Error:        errMsg,
+		Model:        r.model,
+		Checkpoint:   checkpoint,
+		Cost:         r.spend.Cost,
+	})
+}
+
+// snapshot commits the working directory to the run's git checkpoint ref for
+// iteration i and returns the commit, or "" when git checkpoints are off.
+// A failure is logged and disables further snapshots for the run.
+func (r *runner) snapshot(i int) string {
+	if !r.cfg.GitCheckpoints {
+		return ""
+	}
+	snap, err := git.SaveSnapshot(r.cfg.WorkDir, r.cfg.RunID, i, fmt.Sprintf("Iteration %d of run %s", i, r.cfg.RunID))
+	if err != nil {
+		fmt.Fprintf(r.stderr, "│ warning: git checkpoints disabled: %v\n", err)
+		r.cfg.GitCheckpoints = false
+		return ""
+	}
+	fmt.Fprintf(r.stdout, "│ Git checkpoint: %s (%.12s)\n", snap.Ref, snap.Commit)
+	return snap.Commit
+}
+
+// finish logs task completion after iteration i and publishes the complete event.
+func (r *runner) finish(i int) {
+	r.saveCheckpoint(i, StatusComplete)
+	fmt.Fprintln(r.stdout, "│")
+	fmt.Fprintln(r.stdout, "│ *** TASK COMPLETE ***")
+	fmt.Fprintf(r.stdout, "└─── Finished after %d iterations ────────\n", i)
+	r.publish(dashboard.IterationEvent{
+		Type:      "complete",
+		Iteration: i,
+		Timestamp: time.Now().Format(time.RFC3339),
+		Task:      r.cfg.Task,
+		Cost:      r.spend.Cost,
+	})
+}
+
+// publish sends evt to the dashboard and records it in the transcript.
+func (r *runner) publish(evt dashboard.IterationEvent) {
+	r.cfg.Broker.Publish(evt)
+	if r.cfg.OnEvent != nil {
+		r.cfg.OnEvent(evt)
+	}
+	// Streaming deltas and live panes are repeated in full by the
+	// iteration_end that follows, so the transcript skips them.
+	if evt.Type != "orchestrator_delta" && evt.Type != "pane_update" {
+		r.record(transcript.Record{Kind: transcript.KindEvent, Iteration: evt.Iteration, Event: &evt})
+	}
+}
+
+// record appends rec to the run transcript, if any. Failures are logged but
+// never stop the run.
+func (r *runner) record(rec transcript.Record) {
+	if err := r.transcript.Write(rec); err != nil {
+		fmt.Fprintf(r.stderr, "│ warning: failed to write transcript: %v\n", err)
+	}
+}
+
+// recordPane records a raw pane capture and its cleaned form.
+func (r *runner) recordPane(raw, cleaned string) {
+	if r.transcript == nil {
+		return
+	}
+	r.record(transcript.Record{Kind: transcript.KindPane, Iteration: r.iteration, RawPane: raw, CleanPane: cleaned})
+}
+
+// callLLM sends req to the provider, falling back to cfg.FallbackModels in
+// order when a model fails with an error another model might not have. The
+// answering model is returned in Completion.Model.
+func (r *runner) callLLM(purpose string, req Request) (Completion, bool, error) {
+	models := append([]string{req.Model}, r.cfg.FallbackModels...)
+	var completion Completion
+	var streamed bool
+	var err error
+	for n, model := range models {
+		req.Model = model
+		completion, streamed, err = r.callModel(purpose, req)
+		if err == nil {
+			completion.Model = model
+			return completion, streamed, nil
+		}
+		if n == len(models)-1 || !canFallBack(err) {
+			break
+		}
+		if streamed {
+			fmt.Fprintln(r.stdout)
+		}
+		fmt.Fprintf(r.stderr, "│ %s failed: %v\n│ Falling back to %s\n", model, err, models[n+1])
+	}
+	return completion, streamed, err
+}
+
+// callModel makes one LLM call and records the exchange in the transcript.
+// Turn requests go through completeWithStream; summaries and memory
+// compaction are plain completions.
+func (r *runner) callModel(purpose string, req Request) (Completion, bool, error) {
+	start := time.Now()
+	var completion Completion
+	var streamed bool
+	var err error
+	if purpose == transcript.PurposeTurn {
+		completion, streamed, err = r.completeWithStream(req)
+	} else {
+		completion, err = r.cfg.Provider.Complete(req)
+	}
+	r.charge(req.Model, completion.Usage)
+	if r.transcript != nil {
+		rec := transcript.Record{Kind: transcript.KindLLM, Iteration: r.iteration, Purpose: purpose, DurationMs: time.Since(start).Milliseconds()}
+		rec.Request, _ = json.Marshal(req)
+		rec.Response, _ = json.Marshal(completion)
+		if err != nil {
+			rec.Error = err.Error()
+			var apiErr *APIError
+			if errors.As(err, &apiErr) {
+				rec.ErrorStatus = apiErr.StatusCode
+			}
+		}
+		r.record(rec)
+	}
+	return completion, streamed, err
+}
+
+// completeWithStream calls the orchestrator LLM. When cfg.Stream is set and the
+// provider supports streaming, partial tokens are echoed into the terminal log
+// box as they arrive and published as orchestrator_delta events; streamed
+// reports whether the reply was already printed that way.
+func (r *runner) completeWithStream(req Request) (completion Completion, streamed bool, err error) {
+	cfg, iteration := r.cfg, r.iteration
+	sp, ok := cfg.Provider.(StreamingProvider)
+	if !cfg.Stream || !ok {
+		completion, err = cfg.Provider.Complete(req)
+		return completion, false, err
+	}
+
+	fmt.Fprintln(r.stdout, "│")
+	fmt.Fprintf(r.stdout, "│ ╔══ ORCHESTRATOR → %s ══════════\n", strings.ToUpper(cfg.AgentName))
+	atLineStart := true
+	completion, err = sp.CompleteStream(req, func(delta string) {
+		for i, part := range strings.Split(delta, "\n") {
+			if i > 0 {
+				fmt.Fprintln(r.stdout)
+				atLineStart = true
+			}
+			if part == "" {
+				continue
+			}
+			if atLineStart {
+				fmt.Fprint(r.stdout, "│ ║ ")
+				atLineStart = false
+			}
+			fmt.Fprint(r.stdout, part)
+		}
+		r.publish(dashboard.IterationEvent{
+			Type:      "orchestrator_delta",
+			Iteration: iteration,
+			Timestamp: time.Now().Format(time.RFC3339),
+			Delta:     delta,
+		})
+	})
+	if !atLineStart {
+		fmt.Fprintln(r.stdout)
+	}
+	fmt.Fprintln(r.stdout, "│ ╚════════════════════════════════════════")
+	return completion, true, err
 }
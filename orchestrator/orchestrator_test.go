@@ -5,8 +5,13 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"regexp"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/dlee6018/agent-orchestrator/memory"
 )
 
 // System prompt contains the completion marker instruction.
@@ -31,6 +36,26 @@ func TestBuildSystemPrompt_WithMemories(t *testing.T) {
 	}
 }
 
+// The runner's system prompt holds only the memory.TopK facts most relevant
+// to the task, and only those are marked as used.
+func TestRunner_SystemPromptRecallsRelevantMemories(t *testing.T) {
+	defer func(k int) { memory.TopK = k }(memory.TopK)
+	memory.TopK = 1
+	facts := []memory.Record{
+		memory.NewRecord("deploys go through terraform", "r1", "m"),
+		memory.NewRecord("migrations live in db/migrations", "r1", "m"),
+	}
+	facts[0].LastUsedAt, facts[1].LastUsedAt = time.Unix(0, 0), time.Unix(0, 0)
+	r := newRunner(LoopConfig{Task: "add a database migration", Memories: facts})
+	prompt := r.systemPrompt()
+	if !strings.Contains(prompt, "["+facts[1].ID+"] "+facts[1].Text) || strings.Contains(prompt, facts[0].Text) {
+		t.Fatalf("prompt should only recall the migration fact:\n%s", prompt)
+	}
+	if !r.memories[0].LastUsedAt.Equal(facts[0].LastUsedAt) || !r.memories[1].LastUsedAt.After(facts[1].LastUsedAt) {
+		t.Fatalf("only the recalled fact should be marked used: %+v", r.memories)
+	}
+}
+
 // System prompt without memories has no memory section.
 func TestBuildSystemPrompt_NoMemories(t *testing.T) {
 	prompt := BuildSystemPrompt("Claude Code", nil)
@@ -45,6 +70,9 @@ func TestBuildSystemPrompt_MemorySaveInstruction(t *testing.T) {
 	if !strings.Contains(prompt, "MEMORY_SAVE:") {
 		t.Fatal("prompt should include MEMORY_SAVE instruction")
 	}
+	if !strings.Contains(prompt, "MEMORY_FORGET:") || !strings.Contains(prompt, "MEMORY_UPDATE:") {
+		t.Fatal("prompt should include MEMORY_FORGET and MEMORY_UPDATE instructions")
+	}
 }
 
 // System prompt uses the provided agent name throughout.
@@ -197,3 +225,41 @@ func TestCallOpenRouter_RequestStructure(t *testing.T) {
 		t.Fatalf("temperature: got %v want 0.7", receivedReq.Temperature)
 	}
 }
+
+// With pane deltas, the captures left after the context manager elides older
+// ones always start from a full capture, so they rebuild the current screen.
+func TestRunner_PaneDeltasSurviveElision(t *testing.T) {
+	r := newRunner(LoopConfig{AgentName: "Claude Code", KeepPanes: 3})
+	unchanged := regexp.MustCompile(`^\[(\d+) earlier lines unchanged\]\n`)
+	msgs := []Message{{Role: "system", Content: "sys"}, {Role: "user", Content: "task"}}
+	var lines []string
+	for i := 1; i <= 8; i++ {
+		lines = append(lines, fmt.Sprintf("$ step %d", i), fmt.Sprintf("output %d", i))
+		screen := strings.Join(lines, "\n")
+		msgs = append(msgs, Message{Role: "assistant", Content: "next"}, Message{Role: "user", Content: r.paneMessage(screen)})
+
+		var rebuilt []string
+		full := false
+		for _, m := range r.context.View(msgs)[2:] {
+			body, ok := strings.CutPrefix(m.Content, r.context.PanePrefix)
+			if !ok || strings.HasSuffix(body, "elided]") {
+				continue
+			}
+			if sub := unchanged.FindStringSubmatch(body); sub != nil {
+				n, _ := strconv.Atoi(sub[1])
+				if !full {
+					continue // superseded by the full capture after it
+				}
+				if n > len(rebuilt) {
+					t.Fatalf("iteration %d: delta without the lines it builds on:\n%s", i, body)
+				}
+				rebuilt = append(rebuilt[:n], strings.Split(body[len(sub[0]):], "\n")...)
+				continue
+			}
+			rebuilt, full = strings.Split(body, "\n"), true
+		}
+		if got := strings.Join(rebuilt, "\n"); got != screen {
+			t.Fatalf("iteration %d: rebuilt screen\n%s\nwant\n%s", i, got, screen)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package orchestrator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dlee6018/agent-orchestrator/tmux"
+)
+
+// Provider names accepted by NewProvider (and the LLM_PROVIDER env var).
+const (
+	ProviderOpenRouter = "openrouter"
+	ProviderAnthropic  = "anthropic"
+	ProviderOpenAI     = "openai"
+	ProviderOllama     = "ollama"
+)
+
+// Default API roots for providers that don't use a package-level endpoint var.
+const (
+	DefaultOpenAIBaseURL    = "https://api.openai.com/v1"
+	DefaultAnthropicBaseURL = "https://api.anthropic.com"
+	DefaultOllamaBaseURL    = "http://localhost:11434"
+)
+
+// RequestTimeout bounds a single non-streaming completion request.
+var RequestTimeout = 120 * time.Second
+
+// Provider is a chat-completion backend for the orchestrator LLM.
+type Provider interface {
+	// Name returns the provider identifier used in logs and errors.
+	Name() string
+	// Complete sends the conversation and returns the assistant reply and token usage.
+	Complete(req Request) (Completion, error)
+}
+
+// Completion is a provider-agnostic chat completion result.
+type Completion struct {
+	Content   string     `json:"content"`
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	Usage     Usage      `json:"usage"`
+	Model     string     `json:"model,omitempty"` // model that answered; set by the loop
+}
+
+// NewProvider returns the provider registered under name. apiKey may be empty
+// for providers that don't authenticate (e.g. a local Ollama server); an empty
+// baseURL selects the provider's default API root.
+func NewProvider(name, apiKey, baseURL string) (Provider, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", ProviderOpenRouter:
+		return &OpenRouterProvider{APIKey: apiKey, BaseURL: baseURL}, nil
+	case ProviderAnthropic:
+		return &AnthropicProvider{APIKey: apiKey, BaseURL: baseURL}, nil
+	case ProviderOpenAI:
+		return &OpenAIProvider{APIKey: apiKey, BaseURL: baseURL}, nil
+	case ProviderOllama:
+		return &OllamaProvider{BaseURL: baseURL}, nil
+	default:
+		return nil, fmt.Errorf("NewProvider: unknown provider %q (want %s, %s, %s or %s)",
+			name, ProviderOpenRouter, ProviderAnthropic, ProviderOpenAI, ProviderOllama)
+	}
+}
+
+// DefaultModelFor returns the default orchestrator model for a provider name.
+func DefaultModelFor(name string) string {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case ProviderAnthropic:
+		return "claude-opus-4-6"
+	case ProviderOpenAI:
+		return "gpt-4o"
+	case ProviderOllama:
+		return "llama3.1"
+	default:
+		return DefaultModel
+	}
+}
+
+// ProviderNeedsAPIKey reports whether the named provider requires an API key
+// when it talks to baseURL. Ollama never does, and neither does the openai
+// provider pointed at a custom base URL, since local OpenAI-compatible
+// servers (llama.cpp, vLLM, LM Studio) usually take no key.
+func ProviderNeedsAPIKey(name, baseURL string) bool {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case ProviderOllama:
+		return false
+	case ProviderOpenAI:
+		return strings.TrimSpace(baseURL) == ""
+	}
+	return true
+}
+
+// OpenRouterProvider talks to the OpenRouter chat completion API.
+// An empty BaseURL uses the package-level Endpoint.
+type OpenRouterProvider struct {
+	APIKey  string
+	BaseURL string
+}
+
+// Name implements Provider.
+func (p *OpenRouterProvider) Name() string { return ProviderOpenRouter }
+
+// Complete implements Provider.
+func (p *OpenRouterProvider) Complete(req Request) (Completion, error) {
+	endpoint := Endpoint
+	if p.BaseURL != "" {
+		endpoint = joinURL(p.BaseURL, "/chat/completions")
+	}
+	req.Usage = &UsageOptions{Include: true}
+	return completeChat(p.Name(), endpoint, bearerHeaders(p.APIKey), req)
+}
+
+// OpenAIProvider talks to any OpenAI-compatible /chat/completions endpoint
+// (OpenAI itself, vLLM, LM Studio, llama.cpp server, etc.).
+type OpenAIProvider struct {
+	APIKey  string
+	BaseURL string // API root including the version prefix, e.g. https://api.openai.com/v1
+}
+
+// Name implements Provider.
+func (p *OpenAIProvider) Name() string { return ProviderOpenAI }
+
+// Complete implements Provider.
+func (p *OpenAIProvider) Complete(req Request) (Completion, error) {
+	base := p.BaseURL
+	if base == "" {
+		base = DefaultOpenAIBaseURL
+	}
+	return completeChat(p.Name(), joinURL(base, "/chat/completions"), bearerHeaders(p.APIKey), req)
+}
+
+// completeChat posts an OpenAI-style chat completion request and parses the reply.
+func completeChat(name, endpoint string, headers map[string]string, req Request) (Completion, error) {
+	body, err := postJSON(name, endpoint, headers, req)
+	if err != nil {
+		return Completion{}, err
+	}
+	var result Response
+	if err := json.Unmarshal(body, &result); err != nil {
+		return Completion{}, fmt.Errorf("%s: unmarshal response: %w", name, err)
+	}
+	if len(result.Choices) == 0 {
+		return Completion{Usage: result.Usage}, fmt.Errorf("%s: empty choices in response", name)
+	}
+	msg := result.Choices[0].Message
+	return Completion{Content: msg.Content, ToolCalls: msg.ToolCalls, Usage: result.Usage}, nil
+}
+
+// bearerHeaders returns an Authorization header for key, or none when key is empty.
+func bearerHeaders(key string) map[string]string {
+	if key == "" {
+		return nil
+	}
+	return map[string]string{"Authorization": "Bearer " + key}
+}
+
+// postJSON marshals payload, POSTs it to url with the given headers and returns
+// the response body. Non-200 responses are turned into descriptive errors.
+func postJSON(name, url string, headers map[string]string, payload any) ([]byte, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("%s: marshal request: %w", name, err)
+	}
+
+	httpReq, err := http.NewRequest("POST", url, bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: create request: %w", name, err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		httpReq.Header.Set(k, v)
+	}
+
+	client := &http.Client{Timeout: RequestTimeout}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return nil, &NetworkError{Provider: name, Op: "HTTP request", Err: err}
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, &NetworkError{Provider: name, Op: "read response", Err: err}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, httpError(name, resp, body)
+	}
+	return body, nil
+}
+
+// httpError builds an *APIError from a non-200 response, preferring the API's
+// own message.
+func httpError(name string, resp *http.Response, body []byte) error {
+	err := &APIError{
+		Provider:   name,
+		StatusCode: resp.StatusCode,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+	}
+	if msg := apiErrorMessage(body); msg != "" {
+		err.Message, err.structured = msg, true
+	} else {
+		err.Message = tmux.TruncateForLog(string(body), 200)
+	}
+	return err
+}
+
+// apiErrorMessage extracts the error message from the common error body shapes:
+// {"error":{"message":...}} (OpenAI, OpenRouter, Anthropic) and {"error":"..."} (Ollama).
+func apiErrorMessage(body []byte) string {
+	var structured ErrorResponse
+	if json.Unmarshal(body, &structured) == nil && structured.Error.Message != "" {
+		return structured.Error.Message
+	}
+	var plain struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(body, &plain) == nil {
+		return plain.Error
+	}
+	return ""
+}
+
+// joinURL appends path to base, avoiding a doubled slash.
+func joinURL(base, path string) string {
+	return strings.TrimRight(base, "/") + path
+}
@@ -0,0 +1,154 @@
+package orchestrator
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// NewProvider maps each known name to its implementation and rejects unknown names.
+func TestNewProvider_Names(t *testing.T) {
+	for name, want := range map[string]string{
+		"":           ProviderOpenRouter,
+		"openrouter": ProviderOpenRouter,
+		"Anthropic":  ProviderAnthropic,
+		"openai":     ProviderOpenAI,
+		"ollama":     ProviderOllama,
+	} {
+		p, err := NewProvider(name, "key", "")
+		if err != nil {
+			t.Fatalf("NewProvider(%q): %v", name, err)
+		}
+		if p.Name() != want {
+			t.Fatalf("NewProvider(%q).Name() = %q, want %q", name, p.Name(), want)
+		}
+	}
+	if _, err := NewProvider("bogus", "", ""); err == nil {
+		t.Fatal("expected error for unknown provider")
+	}
+}
+
+// Only Ollama and openai with a custom base URL may run without an API key.
+func TestProviderNeedsAPIKey(t *testing.T) {
+	for _, tc := range []struct {
+		name, baseURL string
+		want          bool
+	}{
+		{ProviderOpenRouter, "", true},
+		{ProviderAnthropic, "http://localhost:1234", true},
+		{ProviderOpenAI, "", true},
+		{ProviderOpenAI, "http://localhost:8080/v1", false},
+		{ProviderOllama, "", false},
+	} {
+		if got := ProviderNeedsAPIKey(tc.name, tc.baseURL); got != tc.want {
+			t.Fatalf("ProviderNeedsAPIKey(%q, %q) = %v", tc.name, tc.baseURL, got)
+		}
+	}
+}
+
+// OpenAIProvider posts to <base>/chat/completions with a bearer token.
+func TestOpenAIProvider_Complete(t *testing.T) {
+	var gotPath, gotAuth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotAuth = r.Header.Get("Authorization")
+		json.NewEncoder(w).Encode(Response{
+			Choices: []Choice{{Message: Message{Role: "assistant", Content: "hi"}}},
+			Usage:   Usage{PromptTokens: 3, CompletionTokens: 1, TotalTokens: 4},
+		})
+	}))
+	defer srv.Close()
+
+	p := &OpenAIProvider{APIKey: "k", BaseURL: srv.URL + "/v1/"}
+	c, err := p.Complete(Request{Model: "m", Messages: []Message{{Role: "user", Content: "x"}}})
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if gotPath != "/v1/chat/completions" {
+		t.Fatalf("path: got %q", gotPath)
+	}
+	if gotAuth != "Bearer k" {
+		t.Fatalf("auth: got %q", gotAuth)
+	}
+	if c.Content != "hi" || c.Usage.TotalTokens != 4 {
+		t.Fatalf("unexpected completion: %+v", c)
+	}
+}
+
+// AnthropicProvider lifts the system prompt, sets auth headers and maps usage.
+func TestAnthropicProvider_Complete(t *testing.T) {
+	var got anthropicRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/messages" {
+			t.Errorf("path: got %q", r.URL.Path)
+		}
+		if r.Header.Get("x-api-key") != "ak" || r.Header.Get("anthropic-version") == "" {
+			t.Errorf("missing auth headers: %v", r.Header)
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Write([]byte(`{"id":"msg_1","content":[{"type":"text","text":"echo hi"}],"usage":{"input_tokens":7,"output_tokens":2}}`))
+	}))
+	defer srv.Close()
+
+	p := &AnthropicProvider{APIKey: "ak", BaseURL: srv.URL}
+	c, err := p.Complete(Request{Model: "claude", Messages: []Message{
+		{Role: "system", Content: "sys"},
+		{Role: "user", Content: "a"},
+		{Role: "user", Content: "b"},
+	}})
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if got.System != "sys" {
+		t.Fatalf("system: got %q", got.System)
+	}
+	if len(got.Messages) != 1 || len(got.Messages[0].Content) != 2 || got.Messages[0].Content[1].Text != "b" {
+		t.Fatalf("consecutive user turns should merge: %+v", got.Messages)
+	}
+	if got.MaxTokens == 0 {
+		t.Fatal("max_tokens must be set")
+	}
+	if c.Content != "echo hi" || c.Usage.PromptTokens != 7 || c.Usage.TotalTokens != 9 {
+		t.Fatalf("unexpected completion: %+v", c)
+	}
+}
+
+// OllamaProvider posts to /api/chat without auth and reads eval counts as usage.
+func TestOllamaProvider_Complete(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" {
+			t.Errorf("path: got %q", r.URL.Path)
+		}
+		if r.Header.Get("Authorization") != "" {
+			t.Errorf("unexpected auth header")
+		}
+		w.Write([]byte(`{"model":"llama","message":{"role":"assistant","content":"ls"},"done":true,"prompt_eval_count":5,"eval_count":1}`))
+	}))
+	defer srv.Close()
+
+	p := &OllamaProvider{BaseURL: srv.URL}
+	c, err := p.Complete(Request{Model: "llama", Messages: []Message{{Role: "user", Content: "x"}}})
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if c.Content != "ls" || c.Usage.TotalTokens != 6 {
+		t.Fatalf("unexpected completion: %+v", c)
+	}
+}
+
+// A plain-string error body (Ollama style) is surfaced in the error message.
+func TestOllamaProvider_PlainError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error":"model 'nope' not found"}`))
+	}))
+	defer srv.Close()
+
+	p := &OllamaProvider{BaseURL: srv.URL}
+	_, err := p.Complete(Request{Model: "nope"})
+	if err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Fatalf("expected not-found error, got %v", err)
+	}
+}
@@ -0,0 +1,238 @@
+package orchestrator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/dlee6018/agent-orchestrator/memory"
+	"github.com/dlee6018/agent-orchestrator/transcript"
+)
+
+// ProviderReplay is the name of the transcript-backed ReplayProvider.
+const ProviderReplay = "replay"
+
+// Replay errors. They are fatal to a run: retrying cannot fix them.
+var (
+	ErrReplayDrift     = errors.New("replay drift")
+	ErrReplayExhausted = errors.New("replay exhausted")
+)
+
+// DriftError reports the first difference between a recorded request and the
+// request the loop actually sent.
+type DriftError struct {
+	Call int    // 1-based index of the LLM call that drifted
+	Diff string // human-readable description of the first difference
+}
+
+// Error implements error.
+func (e *DriftError) Error() string {
+	return fmt.Sprintf("replay drift at LLM call %d: %s", e.Call, e.Diff)
+}
+
+// Unwrap lets errors.Is match ErrReplayDrift.
+func (e *DriftError) Unwrap() error { return ErrReplayDrift }
+
+// ReplayProvider serves the LLM responses recorded in a transcript, in order,
+// so a recorded session can be re-run deterministically without network
+// access. Each incoming request is compared with the recorded one and a
+// *DriftError is returned if they differ, which turns a recorded session into
+// a golden regression test for prompt or loop changes.
+type ReplayProvider struct {
+	// Normalize, if set, is applied to message contents on both sides before
+	// comparing, e.g. to mask temp paths or timestamps.
+	Normalize func(string) string
+
+	task     string
+	model    string
+	memories []memory.Record
+	calls    []transcript.Record
+
+	mu   sync.Mutex
+	next int
+	err  error
+}
+
+// NewReplayProvider builds a ReplayProvider from transcript records.
+func NewReplayProvider(records []transcript.Record) *ReplayProvider {
+	p := &ReplayProvider{}
+	for _, rec := range records {
+		switch rec.Kind {
+		case transcript.KindLLM:
+			p.calls = append(p.calls, rec)
+		case transcript.KindEvent:
+			if rec.Event != nil && rec.Event.Type == "task_info" && p.task == "" {
+				p.task, p.model = rec.Event.Task, rec.Event.Model
+			}
+		case transcript.KindMemory:
+			if p.memories == nil {
+				p.memories = rec.Memories
+			}
+		}
+	}
+	return p
+}
+
+// LoadReplayProvider reads the transcript at path into a ReplayProvider.
+func LoadReplayProvider(path string) (*ReplayProvider, error) {
+	records, err := transcript.Read(path)
+	if err != nil {
+		return nil, fmt.Errorf("LoadReplayProvider: %w", err)
+	}
+	p := NewReplayProvider(records)
+	if len(p.calls) == 0 {
+		return nil, fmt.Errorf("LoadReplayProvider: %s has no recorded LLM calls", path)
+	}
+	return p, nil
+}
+
+// Name implements Provider.
+func (p *ReplayProvider) Name() string { return ProviderReplay }
+
+// Task returns the task of the recorded run.
+func (p *ReplayProvider) Task() string { return p.task }
+
+// Model returns the orchestrator model of the recorded run.
+func (p *ReplayProvider) Model() string { return p.model }
+
+// Memories returns the memory facts the recorded run started with, which a
+// replay must start from too to send the same prompts.
+func (p *ReplayProvider) Memories() []memory.Record { return p.memories }
+
+// Remaining returns the number of recorded calls not yet served.
+func (p *ReplayProvider) Remaining() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.calls) - p.next
+}
+
+// Err returns the first drift or exhaustion error encountered, if any.
+func (p *ReplayProvider) Err() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err
+}
+
+// Complete implements Provider by serving the next recorded response.
+func (p *ReplayProvider) Complete(req Request) (Completion, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.next >= len(p.calls) {
+		return Completion{}, p.fail(fmt.Errorf("%w: all %d recorded LLM calls were used", ErrReplayExhausted, len(p.calls)))
+	}
+	rec := p.calls[p.next]
+	p.next++
+
+	var recorded Request
+	if err := json.Unmarshal(rec.Request, &recorded); err != nil {
+		return Completion{}, p.fail(fmt.Errorf("replay: call %d: unmarshal recorded request: %w", p.next, err))
+	}
+	if diff := diffRequests(recorded, req, p.Normalize); diff != "" {
+		return Completion{}, p.fail(&DriftError{Call: p.next, Diff: diff})
+	}
+	if rec.Error != "" {
+		if rec.ErrorStatus != 0 {
+			// Keep the status so the loop classifies the error as it did live.
+			return Completion{}, &APIError{Provider: "replay", StatusCode: rec.ErrorStatus, Message: "recorded error: " + rec.Error}
+		}
+		return Completion{}, fmt.Errorf("replay: recorded error: %s", rec.Error)
+	}
+	var c Completion
+	if err := json.Unmarshal(rec.Response, &c); err != nil {
+		return Completion{}, p.fail(fmt.Errorf("replay: call %d: unmarshal recorded response: %w", p.next, err))
+	}
+	return c, nil
+}
+
+// fail records err as the first failure and returns it. p.mu must be held.
+func (p *ReplayProvider) fail(err error) error {
+	if p.err == nil {
+		p.err = err
+	}
+	return err
+}
+
+// diffRequests describes the first difference between a recorded and an
+// actual request, or returns "" if they match. Streaming flags are ignored.
+func diffRequests(want, got Request, normalize func(string) string) string {
+	norm := func(s string) string {
+		if normalize != nil {
+			return normalize(s)
+		}
+		return s
+	}
+	if want.Model != got.Model {
+		return fmt.Sprintf("model: recorded %q, got %q", want.Model, got.Model)
+	}
+	if want.Temperature != got.Temperature {
+		return fmt.Sprintf("temperature: recorded %v, got %v", want.Temperature, got.Temperature)
+	}
+	if w, g := toolNames(want.Tools), toolNames(got.Tools); w != g {
+		return fmt.Sprintf("tools: recorded [%s], got [%s]", w, g)
+	}
+	for i := 0; i < min(len(want.Messages), len(got.Messages)); i++ {
+		w, g := want.Messages[i], got.Messages[i]
+		switch {
+		case w.Role != g.Role:
+			return fmt.Sprintf("message %d role: recorded %q, got %q", i, w.Role, g.Role)
+		case norm(w.Content) != norm(g.Content):
+			return fmt.Sprintf("message %d (%s) content: %s", i, w.Role, firstDifference(norm(w.Content), norm(g.Content)))
+		case w.ToolCallID != g.ToolCallID:
+			return fmt.Sprintf("message %d tool_call_id: recorded %q, got %q", i, w.ToolCallID, g.ToolCallID)
+		case describeToolCalls(w.ToolCalls) != describeToolCalls(g.ToolCalls):
+			return fmt.Sprintf("message %d tool calls: recorded %q, got %q", i, describeToolCalls(w.ToolCalls), describeToolCalls(g.ToolCalls))
+		}
+	}
+	if len(want.Messages) != len(got.Messages) {
+		return fmt.Sprintf("message count: recorded %d, got %d", len(want.Messages), len(got.Messages))
+	}
+	return ""
+}
+
+// blankLines matches runs of empty or whitespace-only lines.
+var blankLines = regexp.MustCompile(`\n[ \t]*(\n[ \t]*)+`)
+
+// NormalizeWhitespace is a ReplayProvider.Normalize function that ignores
+// blank-line and trailing-space differences, which vary with terminal timing
+// when a recorded session is replayed against a fresh tmux pane.
+func NormalizeWhitespace(s string) string {
+	lines := strings.Split(blankLines.ReplaceAllString(s, "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// toolNames lists tool function names, comma separated.
+func toolNames(tools []Tool) string {
+	names := make([]string, len(tools))
+	for i, t := range tools {
+		names[i] = t.Function.Name
+	}
+	return strings.Join(names, ",")
+}
+
+// describeToolCalls renders tool calls (including IDs) for comparison.
+func describeToolCalls(calls []ToolCall) string {
+	parts := make([]string, len(calls))
+	for i, c := range calls {
+		parts[i] = c.ID + ":" + DescribeToolCall(c)
+	}
+	return strings.Join(parts, "; ")
+}
+
+// firstDifference shows both strings around the first byte where they differ.
+func firstDifference(want, got string) string {
+	i := 0
+	for i < len(want) && i < len(got) && want[i] == got[i] {
+		i++
+	}
+	excerpt := func(s string) string {
+		start, end := max(i-30, 0), min(i+50, len(s))
+		return s[start:end]
+	}
+	return fmt.Sprintf("differs at byte %d: recorded %q, got %q", i, excerpt(want), excerpt(got))
+}
@@ -0,0 +1,158 @@
+package orchestrator
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/dlee6018/agent-orchestrator/dashboard"
+	"github.com/dlee6018/agent-orchestrator/memory"
+	"github.com/dlee6018/agent-orchestrator/transcript"
+)
+
+// llmRecord builds a transcript record of one LLM exchange.
+func llmRecord(t *testing.T, req Request, c Completion, errMsg string) transcript.Record {
+	t.Helper()
+	reqJSON, _ := json.Marshal(req)
+	respJSON, _ := json.Marshal(c)
+	return transcript.Record{Kind: transcript.KindLLM, Purpose: transcript.PurposeTurn, Request: reqJSON, Response: respJSON, Error: errMsg}
+}
+
+// replayRequest builds a request with a system prompt and the given user turns.
+func replayRequest(user ...string) Request {
+	req := Request{Model: "m", Temperature: 0.3, Messages: []Message{{Role: "system", Content: "sys"}}}
+	for _, u := range user {
+		req.Messages = append(req.Messages, Message{Role: "user", Content: u})
+	}
+	return req
+}
+
+// Recorded responses are served in order and the run's task and model are exposed.
+func TestReplayProvider_ServesInOrder(t *testing.T) {
+	p := NewReplayProvider([]transcript.Record{
+		{Kind: transcript.KindEvent, Event: &dashboard.IterationEvent{Type: "task_info", Task: "the task", Model: "m"}},
+		llmRecord(t, replayRequest("a"), Completion{Content: "one", Usage: Usage{TotalTokens: 3}}, ""),
+		{Kind: transcript.KindPane, CleanPane: "ignored"},
+		llmRecord(t, replayRequest("a", "b"), Completion{ToolCalls: []ToolCall{toolCall(ToolCompleteTask, `{}`)}}, ""),
+	})
+	if p.Task() != "the task" || p.Model() != "m" || p.Remaining() != 2 {
+		t.Fatalf("unexpected provider state: task=%q model=%q remaining=%d", p.Task(), p.Model(), p.Remaining())
+	}
+	c, err := p.Complete(replayRequest("a"))
+	if err != nil || c.Content != "one" || c.Usage.TotalTokens != 3 {
+		t.Fatalf("call 1: %+v %v", c, err)
+	}
+	c, err = p.Complete(replayRequest("a", "b"))
+	if err != nil || len(c.ToolCalls) != 1 || c.ToolCalls[0].Function.Name != ToolCompleteTask {
+		t.Fatalf("call 2: %+v %v", c, err)
+	}
+	if p.Remaining() != 0 || p.Err() != nil {
+		t.Fatalf("remaining=%d err=%v", p.Remaining(), p.Err())
+	}
+
+	_, err = p.Complete(replayRequest("a"))
+	if !errors.Is(err, ErrReplayExhausted) || !errors.Is(p.Err(), ErrReplayExhausted) {
+		t.Fatalf("expected exhaustion, got %v", err)
+	}
+}
+
+// A replay starts from the recorded memory and saves neither memory nor checkpoints.
+func TestReplayProvider_RecordedMemory(t *testing.T) {
+	dir := t.TempDir()
+	p := NewReplayProvider([]transcript.Record{
+		{Kind: transcript.KindMemory, Memories: []memory.Record{{ID: "a1", Text: "build with make"}}},
+		llmRecord(t, replayRequest("a"), Completion{Content: "one"}, ""),
+	})
+	if got := p.Memories(); len(got) != 1 || got[0].Text != "build with make" {
+		t.Fatalf("unexpected recorded memory: %+v", got)
+	}
+
+	r := newRunner(LoopConfig{Provider: p, MemoryDir: dir, Memories: p.Memories(), RunDir: dir, GitCheckpoints: true, Stdout: io.Discard})
+	if r.cfg.RunDir != "" || r.cfg.GitCheckpoints {
+		t.Fatalf("checkpoints should be off in a replay: run dir %q, git %v", r.cfg.RunDir, r.cfg.GitCheckpoints)
+	}
+	r.executeTool(toolCall(ToolSaveMemory, `{"fact":"lint with vet"}`))
+	r.saveMemory()
+	if facts, err := memory.LoadMemory(dir); err != nil || facts != nil {
+		t.Fatalf("a replay should not save memory, got %+v %v", facts, err)
+	}
+}
+
+// A request that differs from the recording fails with a DriftError naming the difference.
+func TestReplayProvider_Drift(t *testing.T) {
+	p := NewReplayProvider([]transcript.Record{llmRecord(t, replayRequest("run make test"), Completion{Content: "x"}, "")})
+	_, err := p.Complete(replayRequest("run make build"))
+	var drift *DriftError
+	if !errors.As(err, &drift) || !errors.Is(err, ErrReplayDrift) {
+		t.Fatalf("expected DriftError, got %v", err)
+	}
+	if drift.Call != 1 || !strings.Contains(drift.Diff, "message 1 (user) content") || !strings.Contains(drift.Diff, "make build") {
+		t.Fatalf("unexpected drift: %+v", drift)
+	}
+	if p.Err() != err {
+		t.Fatalf("Err should keep the drift, got %v", p.Err())
+	}
+}
+
+// diffRequests reports model, tool, count and tool call differences and ignores streaming flags.
+func TestDiffRequests(t *testing.T) {
+	base := replayRequest("a")
+	streamed := base
+	streamed.Stream = true
+	if d := diffRequests(base, streamed, nil); d != "" {
+		t.Fatalf("stream flag should be ignored, got %q", d)
+	}
+	for name, got := range map[string]Request{
+		"model":         {Model: "other", Temperature: 0.3, Messages: base.Messages},
+		"tools":         {Model: "m", Temperature: 0.3, Messages: base.Messages, Tools: AgentTools("x")},
+		"message count": replayRequest("a", "b"),
+	} {
+		if d := diffRequests(base, got, nil); !strings.Contains(d, name) {
+			t.Fatalf("%s: diff = %q", name, d)
+		}
+	}
+	withCall := replayRequest("a")
+	withCall.Messages = append(withCall.Messages, Message{Role: "assistant", ToolCalls: []ToolCall{toolCall(ToolWait, `{"seconds":1}`)}})
+	otherCall := replayRequest("a")
+	otherCall.Messages = append(otherCall.Messages, Message{Role: "assistant", ToolCalls: []ToolCall{toolCall(ToolWait, `{"seconds":2}`)}})
+	if d := diffRequests(withCall, otherCall, nil); !strings.Contains(d, "tool calls") {
+		t.Fatalf("tool call diff = %q", d)
+	}
+}
+
+// Normalize masks volatile content on both sides before comparing.
+func TestReplayProvider_Normalize(t *testing.T) {
+	p := NewReplayProvider([]transcript.Record{llmRecord(t, replayRequest("cd /tmp/TestA123/001"), Completion{Content: "ok"}, "")})
+	tmp := regexp.MustCompile(`/tmp/\S+`)
+	p.Normalize = func(s string) string { return tmp.ReplaceAllString(s, "<tmp>") }
+	if _, err := p.Complete(replayRequest("cd /tmp/TestB456/002")); err != nil {
+		t.Fatalf("normalized request should match: %v", err)
+	}
+}
+
+// NormalizeWhitespace ignores blank-line and trailing-space differences only.
+func TestNormalizeWhitespace(t *testing.T) {
+	a := NormalizeWhitespace("out:\n$ ls  \n\n\nfile\n")
+	b := NormalizeWhitespace("out:\n$ ls\n   \nfile")
+	if a != b || a != "out:\n$ ls\nfile" {
+		t.Fatalf("got %q and %q", a, b)
+	}
+	if NormalizeWhitespace("a b") == NormalizeWhitespace("ab") {
+		t.Fatal("inner spaces must still matter")
+	}
+}
+
+// A recorded API error is replayed as a plain (retryable) error.
+func TestReplayProvider_RecordedError(t *testing.T) {
+	p := NewReplayProvider([]transcript.Record{llmRecord(t, replayRequest("a"), Completion{}, "openrouter: API error 500: boom")})
+	_, err := p.Complete(replayRequest("a"))
+	if err == nil || !strings.Contains(err.Error(), "boom") || errors.Is(err, ErrReplayDrift) {
+		t.Fatalf("expected recorded error, got %v", err)
+	}
+	if p.Err() != nil {
+		t.Fatalf("a recorded error is not a replay failure: %v", p.Err())
+	}
+}
@@ -0,0 +1,179 @@
+package orchestrator
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// APIError is a non-200 response from an LLM API.
+type APIError struct {
+	Provider   string
+	StatusCode int
+	Message    string        // the API's own error message, or a snippet of the body
+	RetryAfter time.Duration // from the Retry-After header; 0 if absent
+	structured bool          // Message came from a JSON error body
+}
+
+func (e *APIError) Error() string {
+	if e.structured {
+		return fmt.Sprintf("%s: API error %d: %s", e.Provider, e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("%s: HTTP %d: %s", e.Provider, e.StatusCode, e.Message)
+}
+
+// Retryable reports whether the same request may succeed later: rate limits,
+// timeouts and server errors are transient, other client errors are not.
+func (e *APIError) Retryable() bool {
+	switch e.StatusCode {
+	case http.StatusRequestTimeout, http.StatusConflict, http.StatusTooEarly, http.StatusTooManyRequests:
+		return true
+	case http.StatusNotImplemented, http.StatusHTTPVersionNotSupported:
+		return false
+	}
+	return e.StatusCode >= 500
+}
+
+// Hint suggests a fix for a permanent error, or returns "".
+func (e *APIError) Hint() string {
+	switch {
+	case e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden:
+		return "check the API key (LLM_API_KEY or the provider's key variable)"
+	case e.StatusCode == http.StatusPaymentRequired:
+		return "the account is out of credits"
+	case e.StatusCode == http.StatusNotFound || strings.Contains(strings.ToLower(e.Message), "model"):
+		return "check the model name (LLM_MODEL) and LLM_BASE_URL"
+	}
+	return ""
+}
+
+// NetworkError is a failed LLM request that never got an HTTP response, such
+// as a refused connection, a timeout or a cut-off stream. It is always
+// retryable.
+type NetworkError struct {
+	Provider string
+	Op       string // what failed, e.g. "HTTP request" or "read stream"
+	Err      error
+}
+
+func (e *NetworkError) Error() string {
+	return fmt.Sprintf("%s: %s: %v", e.Provider, e.Op, e.Err)
+}
+
+func (e *NetworkError) Unwrap() error { return e.Err }
+
+// IsRetryable classifies an LLM call error. API errors are retryable by
+// status code, replay failures never are, and anything else (network errors,
+// malformed or empty responses) is assumed to be transient.
+func IsRetryable(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Retryable()
+	}
+	return !errors.Is(err, ErrReplayDrift) && !errors.Is(err, ErrReplayExhausted)
+}
+
+// parseRetryAfter parses a Retry-After header given in seconds or as an HTTP
+// date; it returns 0 when the header is absent or invalid.
+func parseRetryAfter(v string, now time.Time) time.Duration {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// RetryPolicy decides how often and how long the loop waits after a failed
+// orchestrator LLM call.
+type RetryPolicy struct {
+	// MaxAttempts is the number of consecutive failed calls after which the
+	// run aborts. Permanent errors abort at once.
+	MaxAttempts int
+	// BaseDelay is the wait after the first failure; it doubles with every
+	// further failure up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// Jitter is the fraction of each delay that is randomized (0 to 1), so
+	// concurrent runs do not retry in lockstep.
+	Jitter float64
+	// MaxRetryAfter caps how long a server's Retry-After is honoured.
+	MaxRetryAfter time.Duration
+}
+
+// DefaultRetry is the retry policy of runs that do not set LoopConfig.Retry.
+var DefaultRetry = RetryPolicy{
+	MaxAttempts:   5,
+	BaseDelay:     2 * time.Second,
+	MaxDelay:      60 * time.Second,
+	Jitter:        0.5,
+	MaxRetryAfter: 5 * time.Minute,
+}
+
+// Delay returns how long to wait after the failure-th consecutive failure
+// (starting at 1) with error err. A Retry-After longer than the backoff
+// delay wins, up to MaxRetryAfter.
+func (p RetryPolicy) Delay(failure int, err error) time.Duration {
+	d := p.BaseDelay
+	for n := 1; n < failure && d < p.MaxDelay; n++ {
+		d *= 2
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if p.Jitter > 0 && d > 0 {
+		d -= time.Duration(rand.Float64() * min(p.Jitter, 1) * float64(d))
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > d {
+		d = apiErr.RetryAfter
+		if p.MaxRetryAfter > 0 && d > p.MaxRetryAfter {
+			d = p.MaxRetryAfter
+		}
+	}
+	return d
+}
+
+// abortReason returns why the loop must give up after its failure-th
+// consecutive failed call with err, or "" to retry.
+func abortReason(err error, failure int, p RetryPolicy) string {
+	if !IsRetryable(err) {
+		reason := fmt.Sprintf("aborted: permanent API error: %v", err)
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.Hint() != "" {
+			reason += " (" + apiErr.Hint() + ")"
+		}
+		return reason
+	}
+	if failure >= p.MaxAttempts {
+		return fmt.Sprintf("aborted after %d consecutive API errors", failure)
+	}
+	return ""
+}
+
+// canFallBack reports whether another model might succeed where one failed
+// with err: transient errors and model-specific client errors qualify, while
+// authentication and billing errors fail for every model alike.
+func canFallBack(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusUnauthorized, http.StatusPaymentRequired, http.StatusForbidden:
+			return false
+		}
+		return true
+	}
+	return IsRetryable(err)
+}
@@ -0,0 +1,165 @@
+package orchestrator
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dlee6018/agent-orchestrator/dashboard"
+)
+
+// Provider errors are typed: status code, Retry-After and retryability survive.
+func TestCallOpenRouter_TypedErrors(t *testing.T) {
+	status, retryAfter := 0, ""
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if retryAfter != "" {
+			w.Header().Set("Retry-After", retryAfter)
+		}
+		w.WriteHeader(status)
+		resp := ErrorResponse{}
+		resp.Error.Message = "nope"
+		json.NewEncoder(w).Encode(resp)
+	}))
+	oldEndpoint := Endpoint
+	Endpoint = srv.URL
+	t.Cleanup(func() { Endpoint = oldEndpoint })
+	msgs := []Message{{Role: "user", Content: "hello"}}
+
+	status, retryAfter = http.StatusTooManyRequests, "7"
+	_, _, err := CallOpenRouter("key", "model", msgs, 0)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != 429 || apiErr.RetryAfter != 7*time.Second || !IsRetryable(err) {
+		t.Fatalf("429: got %#v", err)
+	}
+	if err.Error() != "openrouter: API error 429: nope" {
+		t.Fatalf("429 message = %q", err.Error())
+	}
+
+	status, retryAfter = http.StatusUnauthorized, ""
+	_, _, err = CallOpenRouter("bad-key", "model", msgs, 0)
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != 401 || IsRetryable(err) || !strings.Contains(apiErr.Hint(), "API key") {
+		t.Fatalf("401: got %#v", err)
+	}
+
+	srv.Close()
+	_, _, err = CallOpenRouter("key", "model", msgs, 0)
+	var netErr *NetworkError
+	if !errors.As(err, &netErr) || !IsRetryable(err) {
+		t.Fatalf("closed server: got %#v", err)
+	}
+}
+
+// Backoff doubles up to the cap, jitter only shortens it, and Retry-After wins within limits.
+func TestRetryPolicy_Delay(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 5, BaseDelay: time.Second, MaxDelay: 5 * time.Second, MaxRetryAfter: time.Minute}
+	for failure, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 4: 5 * time.Second, 9: 5 * time.Second} {
+		if got := p.Delay(failure, errors.New("x")); got != want {
+			t.Errorf("Delay(%d) = %s, want %s", failure, got, want)
+		}
+	}
+	if got := p.Delay(1, &APIError{StatusCode: 429, RetryAfter: 30 * time.Second}); got != 30*time.Second {
+		t.Errorf("Retry-After ignored: %s", got)
+	}
+	if got := p.Delay(1, &APIError{StatusCode: 429, RetryAfter: time.Hour}); got != time.Minute {
+		t.Errorf("Retry-After not capped: %s", got)
+	}
+	p.Jitter = 0.5
+	for range 50 {
+		if got := p.Delay(2, nil); got < time.Second || got > 2*time.Second {
+			t.Fatalf("jittered delay %s outside [1s, 2s]", got)
+		}
+	}
+}
+
+// Retry-After is accepted in seconds and as an HTTP date.
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 2, 15, 0, 0, 0, time.UTC)
+	if got := parseRetryAfter("120", now); got != 2*time.Minute {
+		t.Errorf("seconds: %s", got)
+	}
+	if got := parseRetryAfter(now.Add(time.Minute).Format(http.TimeFormat), now); got != time.Minute {
+		t.Errorf("HTTP date: %s", got)
+	}
+	if got := parseRetryAfter("soon", now); got != 0 {
+		t.Errorf("invalid: %s", got)
+	}
+}
+
+// A permanent API error aborts the run on the first failure with a hint.
+func TestRunLoop_PermanentErrorFailsFast(t *testing.T) {
+	calls := 0
+	cfg := quietConfig("perm", func(Request) (Completion, error) {
+		calls++
+		return Completion{}, &APIError{Provider: "openrouter", StatusCode: 404, Message: "no such model", structured: true}
+	})
+	var reason string
+	cfg.OnEvent = func(evt dashboard.IterationEvent) {
+		if evt.Type == "complete" {
+			reason = evt.Error
+		}
+	}
+	if status := RunLoop(cfg); status != StatusAborted || calls != 1 {
+		t.Fatalf("RunLoop = %s after %d calls, want aborted after 1", status, calls)
+	}
+	if !strings.Contains(reason, "permanent API error") || !strings.Contains(reason, "LLM_MODEL") {
+		t.Fatalf("abort reason = %q", reason)
+	}
+}
+
+// Transient errors are retried with the run's policy until MaxAttempts.
+func TestRunLoop_RetriesTransientErrors(t *testing.T) {
+	calls := 0
+	cfg := quietConfig("transient", func(Request) (Completion, error) {
+		calls++
+		return Completion{}, &APIError{Provider: "openrouter", StatusCode: 503}
+	})
+	cfg.Retry = RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+	if status := RunLoop(cfg); status != StatusAborted || calls != 3 {
+		t.Fatalf("RunLoop = %s after %d calls, want aborted after 3", status, calls)
+	}
+}
+
+// A failing primary model falls back to the next one, and the event names the answering model.
+func TestRunLoop_FallbackModels(t *testing.T) {
+	var tried []string
+	cfg := quietConfig("fallback", func(req Request) (Completion, error) {
+		tried = append(tried, req.Model)
+		switch req.Model {
+		case "primary":
+			return Completion{}, &APIError{Provider: "openrouter", StatusCode: 529, Message: "overloaded"}
+		case "unknown":
+			return Completion{}, &APIError{Provider: "openrouter", StatusCode: 404, Message: "no such model"}
+		}
+		return Completion{Content: TaskCompleteMarker}, nil
+	})
+	cfg.Model, cfg.FallbackModels = "primary", []string{"unknown", "backup"}
+	var answered string
+	cfg.OnEvent = func(evt dashboard.IterationEvent) {
+		if evt.Type == "iteration_end" {
+			answered = evt.Model
+		}
+	}
+	if status := RunLoop(cfg); status != StatusComplete {
+		t.Fatalf("RunLoop = %s, want %s", status, StatusComplete)
+	}
+	if strings.Join(tried, ",") != "primary,unknown,backup" || answered != "backup" {
+		t.Fatalf("tried %v, answered by %q", tried, answered)
+	}
+}
+
+// Authentication errors fail for every model, so they do not fall back.
+func TestRunLoop_NoFallbackOnAuthError(t *testing.T) {
+	var tried []string
+	cfg := quietConfig("auth", func(req Request) (Completion, error) {
+		tried = append(tried, req.Model)
+		return Completion{}, &APIError{Provider: "openrouter", StatusCode: 401, Message: "bad key"}
+	})
+	cfg.Model, cfg.FallbackModels = "primary", []string{"backup"}
+	if status := RunLoop(cfg); status != StatusAborted || len(tried) != 1 {
+		t.Fatalf("RunLoop = %s after trying %v", status, tried)
+	}
+}
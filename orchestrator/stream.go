@@ -0,0 +1,227 @@
+package orchestrator
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// StreamIdleTimeout aborts a streaming request when no data arrives for this long.
+// Unlike RequestTimeout it does not bound the total reply time.
+var StreamIdleTimeout = 90 * time.Second
+
+// maxStreamLine is the largest single SSE/NDJSON line accepted from a provider.
+const maxStreamLine = 4 * 1024 * 1024
+
+// DeltaFunc receives partial reply text as it streams in.
+type DeltaFunc func(delta string)
+
+// StreamingProvider is implemented by providers that can stream completions.
+type StreamingProvider interface {
+	Provider
+	// CompleteStream is like Complete but calls onDelta with each partial
+	// token chunk before returning the reassembled reply and usage.
+	CompleteStream(req Request, onDelta DeltaFunc) (Completion, error)
+}
+
+// StreamOptions asks OpenAI-compatible APIs to include usage in the final chunk.
+type StreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// streamChunk is one SSE chunk from an OpenAI-compatible streaming response.
+type streamChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content   string `json:"content"`
+			ToolCalls []struct {
+				Index    int              `json:"index"`
+				ID       string           `json:"id"`
+				Function ToolCallFunction `json:"function"`
+			} `json:"tool_calls"`
+		} `json:"delta"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *Usage `json:"usage"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// CompleteStream implements StreamingProvider.
+func (p *OpenRouterProvider) CompleteStream(req Request, onDelta DeltaFunc) (Completion, error) {
+	endpoint := Endpoint
+	if p.BaseURL != "" {
+		endpoint = joinURL(p.BaseURL, "/chat/completions")
+	}
+	req.Usage = &UsageOptions{Include: true}
+	return streamChat(p.Name(), endpoint, bearerHeaders(p.APIKey), req, onDelta)
+}
+
+// CompleteStream implements StreamingProvider.
+func (p *OpenAIProvider) CompleteStream(req Request, onDelta DeltaFunc) (Completion, error) {
+	base := p.BaseURL
+	if base == "" {
+		base = DefaultOpenAIBaseURL
+	}
+	return streamChat(p.Name(), joinURL(base, "/chat/completions"), bearerHeaders(p.APIKey), req, onDelta)
+}
+
+// streamChat sends an OpenAI-style request with stream=true and reassembles
+// the SSE deltas. Servers that ignore the stream flag and answer with plain
+// JSON are handled transparently.
+func streamChat(name, endpoint string, headers map[string]string, req Request, onDelta DeltaFunc) (Completion, error) {
+	req.Stream = true
+	req.StreamOptions = &StreamOptions{IncludeUsage: true}
+
+	var out Completion
+	var sb strings.Builder
+	var calls []ToolCall // indexed by the chunk's tool call index
+	err := postStream(name, endpoint, headers, req, func(body []byte) error {
+		var result Response
+		if err := json.Unmarshal(body, &result); err != nil {
+			return fmt.Errorf("%s: unmarshal response: %w", name, err)
+		}
+		if len(result.Choices) == 0 {
+			out.Usage = result.Usage
+			return fmt.Errorf("%s: empty choices in response", name)
+		}
+		msg := result.Choices[0].Message
+		out = Completion{Content: msg.Content, ToolCalls: msg.ToolCalls, Usage: result.Usage}
+		emit(onDelta, out.Content)
+		return nil
+	}, func(data []byte) (bool, error) {
+		if string(data) == "[DONE]" {
+			return true, nil
+		}
+		var chunk streamChunk
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return false, fmt.Errorf("%s: unmarshal stream chunk: %w", name, err)
+		}
+		if chunk.Error != nil {
+			return false, fmt.Errorf("%s: stream error: %s", name, chunk.Error.Message)
+		}
+		if chunk.Usage != nil {
+			out.Usage = *chunk.Usage
+		}
+		for _, c := range chunk.Choices {
+			if c.Delta.Content != "" {
+				sb.WriteString(c.Delta.Content)
+				emit(onDelta, c.Delta.Content)
+			}
+			// Tool calls arrive as fragments: the first carries id and name,
+			// later ones append to the JSON arguments string.
+			for _, tc := range c.Delta.ToolCalls {
+				for len(calls) <= tc.Index {
+					calls = append(calls, ToolCall{Type: "function"})
+				}
+				call := &calls[tc.Index]
+				if tc.ID != "" {
+					call.ID = tc.ID
+				}
+				call.Function.Name += tc.Function.Name
+				call.Function.Arguments += tc.Function.Arguments
+			}
+		}
+		return false, nil
+	})
+	if err != nil {
+		return out, err
+	}
+	if sb.Len() > 0 {
+		out.Content = sb.String()
+	}
+	if len(calls) > 0 {
+		out.ToolCalls = calls
+	}
+	return out, nil
+}
+
+// postStream POSTs payload and dispatches the reply: a JSON body goes to
+// onJSON, while an event stream is split into SSE "data:" payloads (or
+// NDJSON lines) passed to onData until it reports done. A stream that ends
+// before that is a NetworkError.
+func postStream(name, url string, headers map[string]string, payload any, onJSON func([]byte) error, onData func([]byte) (bool, error)) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("%s: marshal request: %w", name, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("%s: create request: %w", name, err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "text/event-stream")
+	for k, v := range headers {
+		httpReq.Header.Set(k, v)
+	}
+
+	// No overall client timeout: long replies are fine as long as data keeps flowing.
+	idle := time.AfterFunc(RequestTimeout, cancel)
+	defer idle.Stop()
+
+	resp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		return &NetworkError{Provider: name, Op: "HTTP request", Err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return httpError(name, resp, body)
+	}
+
+	ct := resp.Header.Get("Content-Type")
+	if strings.HasPrefix(ct, "application/json") {
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return &NetworkError{Provider: name, Op: "read response", Err: err}
+		}
+		return onJSON(body)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxStreamLine)
+	for scanner.Scan() {
+		idle.Reset(StreamIdleTimeout)
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, ":") || strings.HasPrefix(line, "event:") {
+			continue
+		}
+		if after, ok := strings.CutPrefix(line, "data:"); ok {
+			line = strings.TrimSpace(after)
+		}
+		done, err := onData([]byte(line))
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		if ctx.Err() != nil {
+			return &NetworkError{Provider: name, Op: fmt.Sprintf("stream idle for %s", StreamIdleTimeout), Err: err}
+		}
+		return &NetworkError{Provider: name, Op: "read stream", Err: err}
+	}
+	// The provider closed the stream without its final event: the reply is
+	// incomplete.
+	return &NetworkError{Provider: name, Op: "read stream", Err: io.ErrUnexpectedEOF}
+}
+
+// emit calls onDelta with s when both are non-empty.
+func emit(onDelta DeltaFunc, s string) {
+	if onDelta != nil && s != "" {
+		onDelta(s)
+	}
+}
@@ -0,0 +1,173 @@
+package orchestrator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// OpenAI-style SSE chunks are reassembled and each delta is reported in order.
+func TestStreamChat_ReassemblesDeltas(t *testing.T) {
+	var gotReq Request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&gotReq)
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, part := range []string{"echo ", "hel", "lo"} {
+			fmt.Fprintf(w, "data: {\"choices\":[{\"delta\":{\"content\":%q}}]}\n\n", part)
+		}
+		fmt.Fprint(w, ": keep-alive comment\n\n")
+		fmt.Fprint(w, "data: {\"choices\":[],\"usage\":{\"prompt_tokens\":8,\"completion_tokens\":3,\"total_tokens\":11}}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer srv.Close()
+
+	var deltas []string
+	p := &OpenAIProvider{BaseURL: srv.URL}
+	c, err := p.CompleteStream(Request{Model: "m"}, func(d string) { deltas = append(deltas, d) })
+	if err != nil {
+		t.Fatalf("CompleteStream: %v", err)
+	}
+	if !gotReq.Stream || gotReq.StreamOptions == nil || !gotReq.StreamOptions.IncludeUsage {
+		t.Fatalf("request should ask for a stream with usage: %+v", gotReq)
+	}
+	if c.Content != "echo hello" {
+		t.Fatalf("content: got %q", c.Content)
+	}
+	if strings.Join(deltas, "|") != "echo |hel|lo" {
+		t.Fatalf("deltas: got %v", deltas)
+	}
+	if c.Usage.TotalTokens != 11 {
+		t.Fatalf("usage: got %+v", c.Usage)
+	}
+}
+
+// A server that ignores stream=true and answers with JSON still works.
+func TestStreamChat_JSONFallback(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(Response{
+			Choices: []Choice{{Message: Message{Role: "assistant", Content: "whole reply"}}},
+			Usage:   Usage{TotalTokens: 5},
+		})
+	}))
+	defer srv.Close()
+
+	oldEndpoint := Endpoint
+	Endpoint = srv.URL
+	t.Cleanup(func() { Endpoint = oldEndpoint })
+
+	var deltas []string
+	p := &OpenRouterProvider{APIKey: "k"}
+	c, err := p.CompleteStream(Request{Model: "m"}, func(d string) { deltas = append(deltas, d) })
+	if err != nil {
+		t.Fatalf("CompleteStream: %v", err)
+	}
+	if c.Content != "whole reply" || c.Usage.TotalTokens != 5 {
+		t.Fatalf("unexpected completion: %+v", c)
+	}
+	if len(deltas) != 1 || deltas[0] != "whole reply" {
+		t.Fatalf("expected the full reply as a single delta, got %v", deltas)
+	}
+}
+
+// An error event mid-stream is returned as an error.
+func TestStreamChat_ErrorChunk(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"par\"}}]}\n\n")
+		fmt.Fprint(w, "data: {\"error\":{\"message\":\"overloaded\"}}\n\n")
+	}))
+	defer srv.Close()
+
+	p := &OpenAIProvider{BaseURL: srv.URL}
+	_, err := p.CompleteStream(Request{Model: "m"}, nil)
+	if err == nil || !strings.Contains(err.Error(), "overloaded") {
+		t.Fatalf("expected overloaded error, got %v", err)
+	}
+}
+
+// A stream cut off before [DONE] is a retryable network error.
+func TestStreamChat_TruncatedStream(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"git st\"}}]}\n\n")
+	}))
+	defer srv.Close()
+
+	p := &OpenAIProvider{BaseURL: srv.URL}
+	_, err := p.CompleteStream(Request{Model: "m"}, nil)
+	var netErr *NetworkError
+	if !errors.As(err, &netErr) || !IsRetryable(err) {
+		t.Fatalf("expected retryable NetworkError, got %v", err)
+	}
+}
+
+// A plain JSON answer to a stream request that is cut off is a retryable network error.
+func TestStreamChat_TruncatedJSON(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Length", "1000")
+		fmt.Fprint(w, `{"choices":[{"message":{"content":"git st`)
+	}))
+	defer srv.Close()
+
+	p := &OpenAIProvider{BaseURL: srv.URL}
+	_, err := p.CompleteStream(Request{Model: "m"}, nil)
+	var netErr *NetworkError
+	if !errors.As(err, &netErr) || !IsRetryable(err) {
+		t.Fatalf("expected retryable NetworkError, got %v", err)
+	}
+}
+
+// Anthropic stream events are reassembled with input and output token counts.
+func TestAnthropicProvider_CompleteStream(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"usage\":{\"input_tokens\":12}}}\n\n")
+		fmt.Fprint(w, "event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"git \"}}\n\n")
+		fmt.Fprint(w, "event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"status\"}}\n\n")
+		fmt.Fprint(w, "event: message_delta\ndata: {\"type\":\"message_delta\",\"usage\":{\"output_tokens\":4}}\n\n")
+		fmt.Fprint(w, "event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n")
+	}))
+	defer srv.Close()
+
+	p := &AnthropicProvider{APIKey: "k", BaseURL: srv.URL}
+	c, err := p.CompleteStream(Request{Model: "claude"}, nil)
+	if err != nil {
+		t.Fatalf("CompleteStream: %v", err)
+	}
+	if c.Content != "git status" {
+		t.Fatalf("content: got %q", c.Content)
+	}
+	if c.Usage.PromptTokens != 12 || c.Usage.CompletionTokens != 4 || c.Usage.TotalTokens != 16 {
+		t.Fatalf("usage: got %+v", c.Usage)
+	}
+}
+
+// Ollama NDJSON chunks are reassembled and the final chunk supplies usage.
+func TestOllamaProvider_CompleteStream(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		fmt.Fprintln(w, `{"message":{"role":"assistant","content":"make "},"done":false}`)
+		fmt.Fprintln(w, `{"message":{"role":"assistant","content":"test"},"done":false}`)
+		fmt.Fprintln(w, `{"message":{"role":"assistant","content":""},"done":true,"prompt_eval_count":9,"eval_count":2}`)
+	}))
+	defer srv.Close()
+
+	var deltas int
+	p := &OllamaProvider{BaseURL: srv.URL}
+	c, err := p.CompleteStream(Request{Model: "llama"}, func(string) { deltas++ })
+	if err != nil {
+		t.Fatalf("CompleteStream: %v", err)
+	}
+	if c.Content != "make test" || c.Usage.TotalTokens != 11 {
+		t.Fatalf("unexpected completion: %+v", c)
+	}
+	if deltas != 2 {
+		t.Fatalf("expected 2 non-empty deltas, got %d", deltas)
+	}
+}
@@ -0,0 +1,150 @@
+package orchestrator
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dlee6018/agent-orchestrator/dashboard"
+)
+
+// RunStatus is a snapshot of one supervised run.
+type RunStatus struct {
+	ID         string    `json:"id"`
+	Session    string    `json:"session"`
+	WorkDir    string    `json:"work_dir"`
+	Task       string    `json:"task"`
+	Status     string    `json:"status"` // StatusRunning until the run ends, then its final status
+	Iteration  int       `json:"iteration"`
+	Error      string    `json:"error,omitempty"` // last error reported by the run
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at,omitempty"`
+}
+
+// Supervisor runs several RunLoop sessions concurrently and tracks their
+// status. Each run needs its own tmux session and working directory; give
+// each its own Broker so dashboard streams stay separate.
+type Supervisor struct {
+	// OnChange, if set, is called with a run's new status whenever it changes.
+	// It may be called from several goroutines at once.
+	OnChange func(RunStatus)
+
+	mu   sync.Mutex
+	runs []*RunStatus
+	wg   sync.WaitGroup
+}
+
+// NewSupervisor creates an empty Supervisor.
+func NewSupervisor() *Supervisor {
+	return &Supervisor{}
+}
+
+// Start launches RunLoop(cfg) in a new goroutine and returns the run's ID
+// (cfg.RunID, generated if empty). A panic in the run is recovered and
+// reported as an aborted run instead of taking down the other runs.
+func (s *Supervisor) Start(cfg LoopConfig) string {
+	if cfg.RunID == "" {
+		cfg.RunID = NewRunID()
+	}
+	st := &RunStatus{
+		ID:        cfg.RunID,
+		Session:   cfg.Session,
+		WorkDir:   cfg.WorkDir,
+		Task:      cfg.Task,
+		Status:    StatusRunning,
+		StartedAt: time.Now(),
+	}
+	s.mu.Lock()
+	s.runs = append(s.runs, st)
+	s.mu.Unlock()
+	s.changed(st)
+
+	onEvent := cfg.OnEvent
+	cfg.OnEvent = func(evt dashboard.IterationEvent) {
+		if onEvent != nil {
+			onEvent(evt)
+		}
+		s.update(st, func() bool {
+			changed := false
+			if evt.Iteration > st.Iteration {
+				st.Iteration, changed = evt.Iteration, true
+			}
+			if evt.Error != "" && evt.Error != st.Error {
+				st.Error, changed = evt.Error, true
+			}
+			return changed
+		})
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		status := StatusAborted
+		defer func() {
+			if p := recover(); p != nil {
+				s.update(st, func() bool {
+					st.Error = fmt.Sprintf("panic: %v", p)
+					return true
+				})
+			}
+			s.update(st, func() bool {
+				st.Status, st.FinishedAt = status, time.Now()
+				return true
+			})
+		}()
+		status = RunLoop(cfg)
+	}()
+	return cfg.RunID
+}
+
+// Status returns a snapshot of every run, in start order.
+func (s *Supervisor) Status() []RunStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]RunStatus, len(s.runs))
+	for i, st := range s.runs {
+		out[i] = *st
+	}
+	return out
+}
+
+// Running returns the number of runs that have not finished yet.
+func (s *Supervisor) Running() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, st := range s.runs {
+		if st.Status == StatusRunning {
+			n++
+		}
+	}
+	return n
+}
+
+// Wait blocks until every started run has finished and returns their final status.
+func (s *Supervisor) Wait() []RunStatus {
+	s.wg.Wait()
+	return s.Status()
+}
+
+// update applies fn to st under the lock and reports the change if fn
+// returns true.
+func (s *Supervisor) update(st *RunStatus, fn func() bool) {
+	s.mu.Lock()
+	changed := fn()
+	s.mu.Unlock()
+	if changed {
+		s.changed(st)
+	}
+}
+
+// changed calls OnChange with a snapshot of st.
+func (s *Supervisor) changed(st *RunStatus) {
+	if s.OnChange == nil {
+		return
+	}
+	s.mu.Lock()
+	snapshot := *st
+	s.mu.Unlock()
+	s.OnChange(snapshot)
+}
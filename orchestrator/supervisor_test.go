@@ -0,0 +1,89 @@
+package orchestrator
+
+import (
+	"io"
+	"sync"
+	"testing"
+)
+
+// funcProvider answers every request with reply.
+type funcProvider struct {
+	reply func(Request) (Completion, error)
+}
+
+func (p *funcProvider) Name() string                             { return "func" }
+func (p *funcProvider) Complete(req Request) (Completion, error) { return p.reply(req) }
+
+// quietConfig returns a LoopConfig for a run that never touches tmux.
+func quietConfig(id string, reply func(Request) (Completion, error)) LoopConfig {
+	return LoopConfig{
+		RunID:     id,
+		Session:   "session-" + id,
+		WorkDir:   "/nonexistent/" + id,
+		AgentName: "Claude Code",
+		Task:      "task " + id,
+		Provider:  &funcProvider{reply: reply},
+		Model:     "m",
+		Stdout:    io.Discard,
+		Stderr:    io.Discard,
+	}
+}
+
+// Concurrent runs are tracked separately, with per-run iteration caps and panics contained.
+func TestSupervisor_ConcurrentRuns(t *testing.T) {
+	s := NewSupervisor()
+	var mu sync.Mutex
+	changes := map[string]int{}
+	s.OnChange = func(st RunStatus) {
+		mu.Lock()
+		changes[st.ID]++
+		mu.Unlock()
+	}
+
+	s.Start(quietConfig("done", func(Request) (Completion, error) {
+		return Completion{Content: TaskCompleteMarker}, nil
+	}))
+	capped := quietConfig("capped", func(Request) (Completion, error) {
+		return Completion{}, nil // empty tool-mode reply: the loop just asks again
+	})
+	capped.ToolCalling, capped.MaxIterations = true, 2
+	s.Start(capped)
+	s.Start(quietConfig("panics", func(Request) (Completion, error) {
+		panic("provider bug")
+	}))
+
+	got := map[string]RunStatus{}
+	for _, st := range s.Wait() {
+		got[st.ID] = st
+	}
+	if st := got["done"]; st.Status != StatusComplete || st.Iteration != 1 || st.Task != "task done" {
+		t.Fatalf("done: %+v", st)
+	}
+	if st := got["capped"]; st.Status != StatusMaxIterations || st.Iteration != 2 {
+		t.Fatalf("capped: %+v", st)
+	}
+	if st := got["panics"]; st.Status != StatusAborted || st.Error != "panic: provider bug" || st.FinishedAt.IsZero() {
+		t.Fatalf("panics: %+v", st)
+	}
+	if s.Running() != 0 {
+		t.Fatalf("Running() = %d after Wait", s.Running())
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for _, id := range []string{"done", "capped", "panics"} {
+		if changes[id] < 2 {
+			t.Fatalf("%s: expected start and finish changes, got %d", id, changes[id])
+		}
+	}
+}
+
+// Start generates a run ID when none is given.
+func TestSupervisor_GeneratesRunID(t *testing.T) {
+	s := NewSupervisor()
+	id := s.Start(quietConfig("", func(Request) (Completion, error) {
+		return Completion{Content: TaskCompleteMarker}, nil
+	}))
+	if id == "" || s.Wait()[0].ID != id {
+		t.Fatalf("unexpected run ID %q", id)
+	}
+}
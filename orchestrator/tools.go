@@ -0,0 +1,343 @@
+package orchestrator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dlee6018/agent-orchestrator/memory"
+	"github.com/dlee6018/agent-orchestrator/tmux"
+)
+
+// Tool names offered to the orchestrator LLM in tool-calling mode.
+const (
+	ToolTypeText     = "type_text"
+	ToolPressKeys    = "press_keys"
+	ToolWait         = "wait"
+	ToolSaveMemory   = "save_memory"
+	ToolForgetMemory = "forget_memory"
+	ToolUpdateMemory = "update_memory"
+	ToolCompleteTask = "complete_task"
+	ToolAskHuman     = "ask_human"
+)
+
+// MaxWaitSeconds caps the duration of a single wait tool call.
+const MaxWaitSeconds = 300
+
+// TypeTextArgs are the arguments of the type_text tool.
+type TypeTextArgs struct {
+	Text   string `json:"text"`
+	Submit *bool  `json:"submit,omitempty"` // defaults to true (press Enter)
+}
+
+// PressKeysArgs are the arguments of the press_keys tool.
+type PressKeysArgs struct {
+	Keys []string `json:"keys"`
+}
+
+// WaitArgs are the arguments of the wait tool.
+type WaitArgs struct {
+	Seconds int `json:"seconds"`
+}
+
+// SaveMemoryArgs are the arguments of the save_memory tool.
+type SaveMemoryArgs struct {
+	Fact  string   `json:"fact"`
+	Tags  []string `json:"tags,omitempty"`
+	Scope string   `json:"scope,omitempty"` // user, project (default) or branch
+}
+
+// ForgetMemoryArgs are the arguments of the forget_memory tool.
+type ForgetMemoryArgs struct {
+	Target string `json:"target"` // fact id or text
+}
+
+// UpdateMemoryArgs are the arguments of the update_memory tool.
+type UpdateMemoryArgs struct {
+	Target string `json:"target"` // fact id or text
+	Fact   string `json:"fact"`
+}
+
+// CompleteTaskArgs are the arguments of the complete_task tool.
+type CompleteTaskArgs struct {
+	Summary string `json:"summary"`
+}
+
+// AskHumanArgs are the arguments of the ask_human tool.
+type AskHumanArgs struct {
+	Question string `json:"question"`
+}
+
+// AgentTools returns the tool definitions for driving the named coding agent.
+func AgentTools(agentName string) []Tool {
+	return []Tool{
+		newTool(ToolTypeText,
+			fmt.Sprintf("Type text into the %s prompt. The text is submitted with Enter unless submit is false.", agentName),
+			`{"type":"object","properties":{"text":{"type":"string","description":"Text to type"},"submit":{"type":"boolean","description":"Press Enter after typing (default true)"}},"required":["text"]}`),
+		newTool(ToolPressKeys,
+			"Press special keys in order, e.g. Escape to interrupt, C-c to cancel, Up/Down to navigate menus, Enter to confirm, Tab to autocomplete.",
+			`{"type":"object","properties":{"keys":{"type":"array","items":{"type":"string"},"description":"tmux key names such as Escape, Enter, Tab, Up, Down, Left, Right, C-c, y"}},"required":["keys"]}`),
+		newTool(ToolWait,
+			fmt.Sprintf("Wait for %s to produce more output (up to the given number of seconds) and return the updated pane.", agentName),
+			fmt.Sprintf(`{"type":"object","properties":{"seconds":{"type":"integer","minimum":1,"maximum":%d}},"required":["seconds"]}`, MaxWaitSeconds)),
+		newTool(ToolSaveMemory,
+			"Save a fact for future sessions: project conventions, pitfalls, user preferences, or anything useful across sessions.",
+			`{"type":"object","properties":{"fact":{"type":"string"},"tags":{"type":"array","items":{"type":"string"},"description":"Optional short topics such as build, tests or style"},"scope":{"type":"string","enum":["user","project","branch"],"description":"user for preferences across all projects, branch for the current git branch only (default project)"}},"required":["fact"]}`),
+		newTool(ToolForgetMemory,
+			"Remove a saved fact that is wrong or stale, given its id from the memory list or its text.",
+			`{"type":"object","properties":{"target":{"type":"string","description":"Fact id or text"}},"required":["target"]}`),
+		newTool(ToolUpdateMemory,
+			"Replace a saved fact that is wrong or stale with a corrected one, given its id from the memory list or its text.",
+			`{"type":"object","properties":{"target":{"type":"string","description":"Fact id or text"},"fact":{"type":"string","description":"Corrected fact"}},"required":["target","fact"]}`),
+		newTool(ToolCompleteTask,
+			"Signal that the task is fully complete and verified. Only call this when you are confident the task is done.",
+			`{"type":"object","properties":{"summary":{"type":"string","description":"What was done and how it was verified"}},"required":["summary"]}`),
+		newTool(ToolAskHuman,
+			"Ask the human operator a question when you are blocked or need a decision only they can make. Returns their answer.",
+			`{"type":"object","properties":{"question":{"type":"string"}},"required":["question"]}`),
+	}
+}
+
+// newTool builds a function Tool from a name, description and JSON schema.
+func newTool(name, description, schema string) Tool {
+	return Tool{Type: "function", Function: ToolFunction{
+		Name:        name,
+		Description: description,
+		Parameters:  json.RawMessage(schema),
+	}}
+}
+
+// ParseToolArgs decodes a tool call's JSON arguments into v.
+// An empty argument string is treated as an empty object.
+func ParseToolArgs(call ToolCall, v any) error {
+	args := strings.TrimSpace(call.Function.Arguments)
+	if args == "" {
+		args = "{}"
+	}
+	if err := json.Unmarshal([]byte(args), v); err != nil {
+		return fmt.Errorf("invalid arguments for %s: %w", call.Function.Name, err)
+	}
+	return nil
+}
+
+// DescribeToolCall renders a tool call as a single log line, e.g. `type_text {"text":"ls"}`.
+func DescribeToolCall(call ToolCall) string {
+	args := strings.TrimSpace(call.Function.Arguments)
+	if args == "" || args == "{}" {
+		return call.Function.Name
+	}
+	return call.Function.Name + " " + args
+}
+
+// BuildToolSystemPrompt returns the system prompt for tool-calling mode, where
+// the orchestrator acts through AgentTools instead of raw keystroke replies.
+func BuildToolSystemPrompt(agentName string, memories []string) string {
+	base := fmt.Sprintf(`You are an autonomous agent driving a %s CLI session via tmux.
+
+You act only through tool calls. Any plain text you write is treated as private notes and is not sent to %s; a reply without a tool call does nothing.
+
+Tools:
+- type_text: type input into the %s prompt (submitted with Enter unless submit is false).
+- press_keys: press special keys such as Escape, Enter, C-c, Tab, Up, Down.
+- wait: give %s more time when it is still working, then see the updated pane.
+- save_memory: save a fact for future sessions (project conventions, pitfalls, user preferences); scope "user" shares it with every project, "branch" keeps it to the current git branch.
+- forget_memory / update_memory: remove or correct a saved fact that turned out wrong or stale, by its [id].
+- ask_human: ask the human operator when you are blocked or need a decision only they can make.
+- complete_task: finish the run once the task is fully complete and verified.
+
+Rules:
+- After each action you will see the tmux pane output showing %s's reaction.
+- Analyze the output carefully before deciding your next action.
+- If %s asks a question or needs confirmation, respond appropriately.
+- If an approach fails, try a different strategy — do not repeat the same failed command.
+- If %s shows an error, read it carefully and adapt.
+- Keep your inputs concise and focused on the task.
+- After each action, decide the next step so there is always forward progress.
+- Saved facts marked (branch) override project facts, which override facts marked (user).
+
+Only call complete_task when you are confident the task is done. Do not call it prematurely.`,
+		agentName, agentName, agentName, agentName, agentName, agentName, agentName)
+	return appendMemories(base, memories)
+}
+
+// ToolSettleTimeout bounds how long press_keys and unsubmitted type_text wait
+// for the pane to react. Keys often have no visible effect, so an unchanged
+// pane after this long is reported as-is rather than as an error.
+var ToolSettleTimeout = 10 * time.Second
+
+// runToolCalls executes the tool calls of one LLM completion in order, feeding
+// each result back as a tool message. It reports whether complete_task ended the run.
+func (r *runner) runToolCalls(i int, iterStart time.Time, completion Completion) bool {
+	r.messages = append(r.messages, Message{Role: "assistant", Content: completion.Content, ToolCalls: completion.ToolCalls})
+
+	var actions []string
+	if completion.Content != "" {
+		actions = append(actions, completion.Content)
+	}
+	pane := ""
+	done := false
+	for _, call := range completion.ToolCalls {
+		desc := DescribeToolCall(call)
+		actions = append(actions, "→ "+desc)
+		fmt.Fprintf(r.stdout, "│ ⚙ %s\n", tmux.TruncateForLog(desc, 200))
+
+		result, output, finished := r.executeTool(call)
+		if output != "" {
+			pane = output
+		}
+		r.messages = append(r.messages, Message{Role: "tool", ToolCallID: call.ID, Content: result})
+		if finished {
+			done = true
+			break
+		}
+	}
+
+	if pane != "" {
+		r.logAgentOutput(pane)
+	}
+	orchestrator := strings.Join(actions, "\n")
+	r.publishIterationEnd(i, iterStart, completion.Usage, orchestrator, pane, "")
+	if done {
+		r.finish(i)
+		return true
+	}
+	fmt.Fprintf(r.stdout, "└─────────────────────────────────────────\n")
+	return false
+}
+
+// executeTool runs a single tool call. It returns the result to report to the
+// LLM, the raw pane captured by the action (empty when the tool did not touch
+// tmux), and whether the call completed the task. Invalid calls produce an
+// error result instead of aborting, so the LLM can correct itself.
+func (r *runner) executeTool(call ToolCall) (result, pane string, done bool) {
+	cfg := r.cfg
+	switch call.Function.Name {
+	case ToolTypeText:
+		var args TypeTextArgs
+		if err := ParseToolArgs(call, &args); err != nil {
+			return "Error: " + err.Error(), "", false
+		}
+		if args.Submit == nil || *args.Submit {
+			pane, err := r.sendAndCapture(args.Text)
+			if err != nil {
+				fmt.Fprintf(r.stderr, "│ TMUX ERROR: %v\n", err)
+				return fmt.Sprintf("Error sending to %s: %v", cfg.AgentName, err), "", false
+			}
+			return r.observe(pane), pane, false
+		}
+		if err := tmux.SendText(cfg.Session, args.Text); err != nil {
+			return fmt.Sprintf("Error sending to %s: %v", cfg.AgentName, err), "", false
+		}
+		return r.settle(ToolSettleTimeout)
+
+	case ToolPressKeys:
+		var args PressKeysArgs
+		if err := ParseToolArgs(call, &args); err != nil {
+			return "Error: " + err.Error(), "", false
+		}
+		if err := tmux.SendKeys(cfg.Session, args.Keys...); err != nil {
+			return fmt.Sprintf("Error pressing keys: %v", err), "", false
+		}
+		return r.settle(ToolSettleTimeout)
+
+	case ToolWait:
+		var args WaitArgs
+		if err := ParseToolArgs(call, &args); err != nil {
+			return "Error: " + err.Error(), "", false
+		}
+		seconds := min(max(args.Seconds, 1), MaxWaitSeconds)
+		return r.settle(time.Duration(seconds) * time.Second)
+
+	case ToolSaveMemory:
+		var args SaveMemoryArgs
+		if err := ParseToolArgs(call, &args); err != nil {
+			return "Error: " + err.Error(), "", false
+		}
+		fact := strings.TrimSpace(args.Fact)
+		if fact == "" {
+			return "Error: fact must not be empty", "", false
+		}
+		scope, err := memory.ParseScope(args.Scope)
+		if err != nil {
+			return "Error: " + err.Error(), "", false
+		}
+		r.addMemories([]memory.Fact{{Text: fact, Scope: scope}}, args.Tags...)
+		return "Saved to memory.", "", false
+
+	case ToolForgetMemory:
+		var args ForgetMemoryArgs
+		if err := ParseToolArgs(call, &args); err != nil {
+			return "Error: " + err.Error(), "", false
+		}
+		if err := r.editMemory(memory.Edit{Target: args.Target}); err != nil {
+			return "Error: " + err.Error(), "", false
+		}
+		return "Removed from memory.", "", false
+
+	case ToolUpdateMemory:
+		var args UpdateMemoryArgs
+		if err := ParseToolArgs(call, &args); err != nil {
+			return "Error: " + err.Error(), "", false
+		}
+		fact := strings.TrimSpace(args.Fact)
+		if fact == "" {
+			return "Error: fact must not be empty", "", false
+		}
+		if err := r.editMemory(memory.Edit{Target: args.Target, Text: fact}); err != nil {
+			return "Error: " + err.Error(), "", false
+		}
+		return "Memory updated.", "", false
+
+	case ToolCompleteTask:
+		var args CompleteTaskArgs
+		if err := ParseToolArgs(call, &args); err != nil {
+			return "Error: " + err.Error(), "", false
+		}
+		if args.Summary != "" {
+			fmt.Fprintf(r.stdout, "│ Summary: %s\n", args.Summary)
+		}
+		if feedback := r.verify(); feedback != "" {
+			return feedback, "", false
+		}
+		return "Task marked complete.", "", true
+
+	case ToolAskHuman:
+		var args AskHumanArgs
+		if err := ParseToolArgs(call, &args); err != nil {
+			return "Error: " + err.Error(), "", false
+		}
+		if cfg.AskHuman == nil {
+			return "No human is available; proceed autonomously.", "", false
+		}
+		fmt.Fprintf(r.stdout, "│ ? %s\n", args.Question)
+		answer, err := cfg.AskHuman(args.Question)
+		if err != nil {
+			return fmt.Sprintf("Could not reach the human: %v. Proceed autonomously.", err), "", false
+		}
+		return "Human answered: " + answer, "", false
+
+	default:
+		return fmt.Sprintf("Error: unknown tool %q", call.Function.Name), "", false
+	}
+}
+
+// settle waits up to timeout for the pane to change and stabilize after an
+// action, treating an unchanged pane as a valid observation.
+func (r *runner) settle(timeout time.Duration) (result, pane string, done bool) {
+	pane, err := tmux.WaitForPaneUpdate(r.cfg.Session, r.lastPane, timeout, r.publishPane)
+	if err != nil && !errors.Is(err, tmux.ErrStillWorking) {
+		fmt.Fprintf(r.stderr, "│ TMUX ERROR: %v\n", err)
+		return fmt.Sprintf("Error reading %s output: %v", r.cfg.AgentName, err), "", false
+	}
+	return r.observe(pane), pane, false
+}
+
+// observe records pane as the latest observation and formats it for the LLM.
+func (r *runner) observe(pane string) string {
+	r.lastPane = pane
+	cleaned := tmux.CleanPaneOutput(pane)
+	r.recordPane(pane, cleaned)
+	return r.paneMessage(cleaned)
+}
@@ -0,0 +1,199 @@
+package orchestrator
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/dlee6018/agent-orchestrator/memory"
+)
+
+// toolCall builds a ToolCall for tests.
+func toolCall(name, args string) ToolCall {
+	return ToolCall{ID: "call_1", Type: "function", Function: ToolCallFunction{Name: name, Arguments: args}}
+}
+
+// Every agent tool has a unique name and a valid JSON-schema object.
+func TestAgentTools_Definitions(t *testing.T) {
+	seen := map[string]bool{}
+	for _, tool := range AgentTools("Codex") {
+		if tool.Type != "function" {
+			t.Fatalf("%s: type = %q", tool.Function.Name, tool.Type)
+		}
+		if seen[tool.Function.Name] {
+			t.Fatalf("duplicate tool %s", tool.Function.Name)
+		}
+		seen[tool.Function.Name] = true
+		var schema map[string]any
+		if err := json.Unmarshal(tool.Function.Parameters, &schema); err != nil {
+			t.Fatalf("%s: invalid schema: %v", tool.Function.Name, err)
+		}
+		if schema["type"] != "object" {
+			t.Fatalf("%s: schema type = %v", tool.Function.Name, schema["type"])
+		}
+	}
+	for _, name := range []string{ToolTypeText, ToolPressKeys, ToolWait, ToolSaveMemory, ToolForgetMemory, ToolUpdateMemory, ToolCompleteTask, ToolAskHuman} {
+		if !seen[name] {
+			t.Fatalf("missing tool %s", name)
+		}
+	}
+}
+
+// Empty arguments decode as an empty object; malformed JSON is an error naming the tool.
+func TestParseToolArgs(t *testing.T) {
+	var wait WaitArgs
+	if err := ParseToolArgs(toolCall(ToolWait, ""), &wait); err != nil || wait.Seconds != 0 {
+		t.Fatalf("empty args: %v %+v", err, wait)
+	}
+	var typed TypeTextArgs
+	if err := ParseToolArgs(toolCall(ToolTypeText, `{"text":"ls","submit":false}`), &typed); err != nil {
+		t.Fatalf("ParseToolArgs: %v", err)
+	}
+	if typed.Text != "ls" || typed.Submit == nil || *typed.Submit {
+		t.Fatalf("unexpected args: %+v", typed)
+	}
+	err := ParseToolArgs(toolCall(ToolPressKeys, `{"keys":`), &PressKeysArgs{})
+	if err == nil || !strings.Contains(err.Error(), ToolPressKeys) {
+		t.Fatalf("expected error naming the tool, got %v", err)
+	}
+}
+
+// Tool mode sends tool definitions and uses the tool system prompt.
+func TestRunner_RequestToolMode(t *testing.T) {
+	r := newRunner(LoopConfig{AgentName: "Claude Code", Model: "m", ToolCalling: true})
+	if strings.Contains(r.systemPrompt(), TaskCompleteMarker) {
+		t.Fatal("tool prompt should not mention the TASK_COMPLETE marker")
+	}
+	if len(r.request().Tools) == 0 {
+		t.Fatal("tool mode request should carry tools")
+	}
+	r.cfg.ToolCalling = false
+	if len(r.request().Tools) != 0 {
+		t.Fatal("text mode request should not carry tools")
+	}
+}
+
+// save_memory adds a deduplicated fact without touching tmux and records
+// the run and model that saved it.
+func TestExecuteTool_SaveMemory(t *testing.T) {
+	r := newRunner(LoopConfig{RunID: "run-1", Model: "m", Memories: []memory.Record{{Text: "uses Go"}}})
+	result, pane, done := r.executeTool(toolCall(ToolSaveMemory, `{"fact":"run make test"}`))
+	if done || pane != "" || !strings.Contains(result, "Saved") {
+		t.Fatalf("unexpected result %q pane %q done %v", result, pane, done)
+	}
+	r.executeTool(toolCall(ToolSaveMemory, `{"fact":"run make test","tags":["tests"]}`))
+	if len(r.memories) != 2 {
+		t.Fatalf("expected 2 deduplicated facts, got %v", r.memories)
+	}
+	if saved := r.memories[1]; saved.SourceRun != "run-1" || saved.Model != "m" || saved.Hits != 1 || len(saved.Tags) != 1 || saved.ID == "" {
+		t.Fatalf("unexpected saved record: %+v", saved)
+	}
+	if result, _, _ := r.executeTool(toolCall(ToolSaveMemory, `{"fact":"  "}`)); !strings.HasPrefix(result, "Error") {
+		t.Fatalf("empty fact should be rejected, got %q", result)
+	}
+}
+
+// forget_memory and update_memory edit a saved fact by id or text and
+// report targets that match nothing.
+func TestExecuteTool_ForgetAndUpdateMemory(t *testing.T) {
+	r := newRunner(LoopConfig{RunID: "run-2", Memories: []memory.Record{{ID: "a1", Text: "build with make"}, {ID: "b2", Text: "lint with golangci-lint"}}})
+	if result, _, done := r.executeTool(toolCall(ToolUpdateMemory, `{"target":"a1","fact":"build with go build"}`)); done || result != "Memory updated." {
+		t.Fatalf("update: %q", result)
+	}
+	if r.memories[0].ID != "a1" || r.memories[0].Text != "build with go build" || r.memories[0].SourceRun != "run-2" {
+		t.Fatalf("unexpected update: %+v", r.memories[0])
+	}
+	if result, _, _ := r.executeTool(toolCall(ToolForgetMemory, `{"target":"lint with golangci-lint"}`)); result != "Removed from memory." || len(r.memories) != 1 {
+		t.Fatalf("forget: %q %+v", result, r.memories)
+	}
+	if result, _, _ := r.executeTool(toolCall(ToolForgetMemory, `{"target":"deploy with terraform"}`)); !strings.HasPrefix(result, "Error") || len(r.memories) != 1 {
+		t.Fatalf("unmatched forget should fail: %q", result)
+	}
+	if result, _, _ := r.executeTool(toolCall(ToolUpdateMemory, `{"target":"a1","fact":" "}`)); !strings.HasPrefix(result, "Error") {
+		t.Fatalf("empty fact should be rejected, got %q", result)
+	}
+}
+
+// Forgetting the last fact still rewrites the memory file.
+func TestSaveMemory_AfterForgettingEverything(t *testing.T) {
+	dir := t.TempDir()
+	stored := []memory.Record{{ID: "a1", Text: "stale fact"}}
+	if err := memory.SaveMemory(dir, stored); err != nil {
+		t.Fatalf("SaveMemory: %v", err)
+	}
+	r := newRunner(LoopConfig{MemoryDir: dir, Memories: stored, Stdout: io.Discard})
+	r.executeTool(toolCall(ToolForgetMemory, `{"target":"a1"}`))
+	r.saveMemory()
+	facts, err := memory.LoadMemory(dir)
+	if err != nil || facts == nil || len(facts) != 0 {
+		t.Fatalf("expected an empty memory file, got %+v %v", facts, err)
+	}
+}
+
+// save_memory with a scope saves to that scope's file, and an unknown
+// scope is rejected.
+func TestExecuteTool_SaveMemoryScope(t *testing.T) {
+	userDir, projectDir := t.TempDir(), t.TempDir()
+	r := newRunner(LoopConfig{MemoryDir: projectDir, MemoryUserDir: userDir, Stdout: io.Discard})
+	r.executeTool(toolCall(ToolSaveMemory, `{"fact":"I use conventional commits","scope":"user"}`))
+	r.executeTool(toolCall(ToolSaveMemory, `{"fact":"this branch targets v2","scope":"branch"}`))
+	if result, _, _ := r.executeTool(toolCall(ToolSaveMemory, `{"fact":"x","scope":"team"}`)); !strings.HasPrefix(result, "Error") {
+		t.Fatalf("unknown scope should be rejected, got %q", result)
+	}
+	r.saveMemory()
+	user, err := memory.LoadMemory(userDir)
+	if err != nil || len(user) != 1 || user[0].Text != "I use conventional commits" {
+		t.Fatalf("user memory: %+v %v", user, err)
+	}
+	// Without a branch the branch scope falls back to the project.
+	project, err := memory.LoadMemory(projectDir)
+	if err != nil || len(project) != 1 || project[0].Text != "this branch targets v2" {
+		t.Fatalf("project memory: %+v %v", project, err)
+	}
+}
+
+// complete_task ends the run.
+func TestExecuteTool_CompleteTask(t *testing.T) {
+	r := newRunner(LoopConfig{})
+	if _, _, done := r.executeTool(toolCall(ToolCompleteTask, `{"summary":"done"}`)); !done {
+		t.Fatal("complete_task should report done")
+	}
+}
+
+// ask_human relays the operator's answer, or tells the LLM to proceed when nobody is there.
+func TestExecuteTool_AskHuman(t *testing.T) {
+	r := newRunner(LoopConfig{})
+	result, _, _ := r.executeTool(toolCall(ToolAskHuman, `{"question":"which db?"}`))
+	if !strings.Contains(result, "No human is available") {
+		t.Fatalf("unexpected result without handler: %q", result)
+	}
+
+	var asked string
+	r.cfg.AskHuman = func(q string) (string, error) { asked = q; return "postgres", nil }
+	result, _, _ = r.executeTool(toolCall(ToolAskHuman, `{"question":"which db?"}`))
+	if asked != "which db?" || !strings.Contains(result, "postgres") {
+		t.Fatalf("asked %q, result %q", asked, result)
+	}
+
+	r.cfg.AskHuman = func(string) (string, error) { return "", errors.New("input closed") }
+	result, _, _ = r.executeTool(toolCall(ToolAskHuman, `{"question":"?"}`))
+	if !strings.Contains(result, "input closed") {
+		t.Fatalf("expected error in result, got %q", result)
+	}
+}
+
+// Unknown tools and bad arguments produce error results instead of aborting.
+func TestExecuteTool_Errors(t *testing.T) {
+	r := newRunner(LoopConfig{})
+	if result, _, done := r.executeTool(toolCall("rm_rf", `{}`)); done || !strings.Contains(result, "unknown tool") {
+		t.Fatalf("unknown tool: %q", result)
+	}
+	if result, _, _ := r.executeTool(toolCall(ToolTypeText, `not json`)); !strings.HasPrefix(result, "Error") {
+		t.Fatalf("bad args: %q", result)
+	}
+	if result, _, _ := r.executeTool(toolCall(ToolPressKeys, `{"keys":["rm -rf /"]}`)); !strings.Contains(result, "unsupported key") {
+		t.Fatalf("invalid key: %q", result)
+	}
+}
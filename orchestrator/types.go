@@ -1,15 +1,9 @@
 package orchestrator
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
 	"strings"
-	"time"
-
-	"github.com/dlee6018/agent-orchestrator/tmux"
 )
 
 // Endpoint is the OpenRouter API URL (var so tests can override).
@@ -22,16 +16,49 @@ const DefaultModel = "anthropic/claude-opus-4.6"
 const TaskCompleteMarker = "TASK_COMPLETE"
 
 // Message represents a chat message in the OpenRouter API.
+// Assistant messages may carry ToolCalls; "tool" messages answer one call by ToolCallID.
 type Message struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role       string     `json:"role"`
+	Content    string     `json:"content"`
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string     `json:"tool_call_id,omitempty"`
+}
+
+// Tool declares a function the LLM may call (OpenAI function-calling format).
+type Tool struct {
+	Type     string       `json:"type"` // always "function"
+	Function ToolFunction `json:"function"`
+}
+
+// ToolFunction is the name, description and JSON-schema parameters of a Tool.
+type ToolFunction struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"`
+}
+
+// ToolCall is a single function call requested by the LLM.
+type ToolCall struct {
+	ID       string           `json:"id"`
+	Type     string           `json:"type"` // always "function"
+	Function ToolCallFunction `json:"function"`
+}
+
+// ToolCallFunction names the called function and carries its JSON-encoded arguments.
+type ToolCallFunction struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
 }
 
 // Request is the request body for the OpenRouter chat completion API.
 type Request struct {
-	Model       string    `json:"model"`
-	Messages    []Message `json:"messages"`
-	Temperature float64   `json:"temperature"`
+	Model         string         `json:"model"`
+	Messages      []Message      `json:"messages"`
+	Temperature   float64        `json:"temperature"`
+	Tools         []Tool         `json:"tools,omitempty"`
+	Stream        bool           `json:"stream,omitempty"`
+	StreamOptions *StreamOptions `json:"stream_options,omitempty"`
+	Usage         *UsageOptions  `json:"usage,omitempty"`
 }
 
 // Choice is a single completion choice from the OpenRouter API.
@@ -42,9 +69,15 @@ type Choice struct {
 
 // Usage tracks token counts from the OpenRouter API response.
 type Usage struct {
-	PromptTokens     int `json:"prompt_tokens"`
-	CompletionTokens int `json:"completion_tokens"`
-	TotalTokens      int `json:"total_tokens"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	TotalTokens      int     `json:"total_tokens"`
+	Cost             float64 `json:"cost,omitempty"` // USD, reported by OpenRouter usage accounting
+}
+
+// UsageOptions asks OpenRouter to report each request's cost in Usage.Cost.
+type UsageOptions struct {
+	Include bool `json:"include"`
 }
 
 // Response is the response body from the OpenRouter chat completion API.
@@ -65,51 +98,9 @@ type ErrorResponse struct {
 // CallOpenRouter sends a chat completion request to the OpenRouter API
 // and returns the assistant's reply content and token usage.
 func CallOpenRouter(apiKey, model string, messages []Message, temperature float64) (string, Usage, error) {
-	reqBody := Request{
-		Model:       model,
-		Messages:    messages,
-		Temperature: temperature,
-	}
-	payload, err := json.Marshal(reqBody)
-	if err != nil {
-		return "", Usage{}, fmt.Errorf("CallOpenRouter: marshal request: %w", err)
-	}
-
-	httpReq, err := http.NewRequest("POST", Endpoint, bytes.NewReader(payload))
-	if err != nil {
-		return "", Usage{}, fmt.Errorf("CallOpenRouter: create request: %w", err)
-	}
-	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("Authorization", "Bearer "+apiKey)
-
-	client := &http.Client{Timeout: 120 * time.Second}
-	resp, err := client.Do(httpReq)
-	if err != nil {
-		return "", Usage{}, fmt.Errorf("CallOpenRouter: HTTP request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", Usage{}, fmt.Errorf("CallOpenRouter: read response: %w", err)
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		var apiErr ErrorResponse
-		if json.Unmarshal(body, &apiErr) == nil && apiErr.Error.Message != "" {
-			return "", Usage{}, fmt.Errorf("CallOpenRouter: API error %d: %s", resp.StatusCode, apiErr.Error.Message)
-		}
-		return "", Usage{}, fmt.Errorf("CallOpenRouter: HTTP %d: %s", resp.StatusCode, tmux.TruncateForLog(string(body), 200))
-	}
-
-	var result Response
-	if err := json.Unmarshal(body, &result); err != nil {
-		return "", Usage{}, fmt.Errorf("CallOpenRouter: unmarshal response: %w", err)
-	}
-	if len(result.Choices) == 0 {
-		return "", result.Usage, fmt.Errorf("CallOpenRouter: empty choices in response")
-	}
-	return result.Choices[0].Message.Content, result.Usage, nil
+	p := &OpenRouterProvider{APIKey: apiKey}
+	c, err := p.Complete(Request{Model: model, Messages: messages, Temperature: temperature})
+	return c.Content, c.Usage, err
 }
 
 // BuildSystemPrompt returns the system prompt for the orchestrator LLM.
@@ -129,23 +120,30 @@ Rules:
 - Keep your inputs concise and focused on the task.
 - After each action, suggest the next steps so there is always forward progress. Do not wait passively — proactively identify what should be done next and continue working.
 - To save a fact for future sessions, include a line starting with "MEMORY_SAVE: " followed by the fact. These lines will be stripped before sending to %s. Use this to remember project conventions, pitfalls, user preferences, or anything useful across sessions.
+- Write "MEMORY_SAVE[user]: " for user preferences that hold in every project, or "MEMORY_SAVE[branch]: " for facts about the current git branch only.
+- Saved facts are listed below with their [id]; user and branch facts are marked. Branch facts override project facts, which override user facts. When one turns out wrong or stale, include "MEMORY_UPDATE: <id> => <corrected fact>" to correct it or "MEMORY_FORGET: <id>" to remove it. These lines are stripped too.
 
 When the task is fully complete and you have verified the results, respond with exactly:
 TASK_COMPLETE
 
 Only send TASK_COMPLETE when you are confident the task is done. Do not send it prematurely.`,
 		agentName, agentName, agentName, agentName, agentName, agentName, agentName, agentName)
+	return appendMemories(base, memories)
+}
 
-	if len(memories) > 0 {
-		var sb strings.Builder
-		sb.WriteString(base)
-		sb.WriteString("\n\n## Memory from previous sessions\n")
-		for _, fact := range memories {
-			sb.WriteString("- ")
-			sb.WriteString(fact)
-			sb.WriteByte('\n')
-		}
-		return sb.String()
+// appendMemories appends a "Memory from previous sessions" section listing
+// memories to a system prompt. The prompt is returned unchanged when empty.
+func appendMemories(prompt string, memories []string) string {
+	if len(memories) == 0 {
+		return prompt
+	}
+	var sb strings.Builder
+	sb.WriteString(prompt)
+	sb.WriteString("\n\n## Memory from previous sessions\n")
+	for _, fact := range memories {
+		sb.WriteString("- ")
+		sb.WriteString(fact)
+		sb.WriteByte('\n')
 	}
-	return base
+	return sb.String()
 }
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/dlee6018/agent-orchestrator/dashboard"
+	"github.com/dlee6018/agent-orchestrator/git"
+	"github.com/dlee6018/agent-orchestrator/helpers"
+	"github.com/dlee6018/agent-orchestrator/orchestrator"
+	"github.com/dlee6018/agent-orchestrator/tmux"
+)
+
+// parallelTask is one entry of the tasks file given to `parallel`.
+type parallelTask struct {
+	Task    string   `json:"task"`
+	WorkDir string   `json:"work_dir"`
+	Session string   `json:"session,omitempty"` // defaults to <CLAUDE_TMUX_SESSION>-<n>
+	Accept  []string `json:"accept,omitempty"`  // acceptance commands; defaults to ACCEPTANCE_COMMANDS
+}
+
+// loadParallelTasks reads a JSON array of tasks and validates it: every task
+// needs an existing working directory, and no two tasks may share a session
+// or, unless sharedDirs is set (each run gets its own worktree), a working
+// directory. Relative directories are resolved against the current directory.
+func loadParallelTasks(path, baseSession string, sharedDirs bool) ([]parallelTask, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("loadParallelTasks: %w", err)
+	}
+	var tasks []parallelTask
+	if err := json.Unmarshal(data, &tasks); err != nil {
+		return nil, fmt.Errorf("loadParallelTasks: %s: %w", path, err)
+	}
+	if len(tasks) == 0 {
+		return nil, fmt.Errorf("loadParallelTasks: %s lists no tasks", path)
+	}
+	dirs, sessions := map[string]bool{}, map[string]bool{}
+	for i := range tasks {
+		t := &tasks[i]
+		t.Task = strings.TrimSpace(t.Task)
+		if t.Task == "" {
+			return nil, fmt.Errorf("loadParallelTasks: task %d is empty", i+1)
+		}
+		if t.WorkDir == "" {
+			return nil, fmt.Errorf("loadParallelTasks: task %d has no work_dir", i+1)
+		}
+		if t.WorkDir, err = filepath.Abs(t.WorkDir); err != nil {
+			return nil, fmt.Errorf("loadParallelTasks: task %d: %w", i+1, err)
+		}
+		if info, err := os.Stat(t.WorkDir); err != nil || !info.IsDir() {
+			return nil, fmt.Errorf("loadParallelTasks: task %d: %s is not a directory", i+1, t.WorkDir)
+		}
+		if t.Session == "" {
+			t.Session = fmt.Sprintf("%s-%d", baseSession, i+1)
+		}
+		if err := helpers.ValidateSessionName(t.Session); err != nil {
+			return nil, fmt.Errorf("loadParallelTasks: task %d: %w", i+1, err)
+		}
+		if dirs[t.WorkDir] && !sharedDirs {
+			return nil, fmt.Errorf("loadParallelTasks: task %d: work_dir %s is used by another task", i+1, t.WorkDir)
+		}
+		if sessions[t.Session] {
+			return nil, fmt.Errorf("loadParallelTasks: task %d: session %s is used by another task", i+1, t.Session)
+		}
+		dirs[t.WorkDir], sessions[t.Session] = true, true
+	}
+	return tasks, nil
+}
+
+// parallelMain implements `parallel <tasks.json>`: it starts one autonomous
+// run per task, each in its own tmux session and working directory, and
+// supervises them until all have finished. Each run's log lines are prefixed
+// with its run ID and each run gets its own dashboard stream.
+func parallelMain(args []string) {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: go-orchestrator parallel <tasks.json>")
+		os.Exit(2)
+	}
+	tmux.Socket = helpers.EnvOrDefault("CLAUDE_TMUX_SOCKET", defaultSocket)
+	if err := helpers.ValidateSessionName(tmux.Socket); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid socket name: %v\n", err)
+		os.Exit(1)
+	}
+	useWorktrees := helpers.EnvBool("WORKTREE", false)
+	tasks, err := loadParallelTasks(args[0], helpers.EnvOrDefault("CLAUDE_TMUX_SESSION", defaultSession), useWorktrees)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	agentCommand, agentName := helpers.ResolveAgentConfig(helpers.EnvOrDefault("DEFAULT_MODEL", "claude"))
+	command, err := tmux.ResolveStartupCommand(helpers.EnvOrDefault("CLAUDE_CMD", agentCommand))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid startup command: %v\n", err)
+		os.Exit(1)
+	}
+	name := helpers.EnvOrDefault("LLM_PROVIDER", orchestrator.ProviderOpenRouter)
+	if strings.EqualFold(name, orchestrator.ProviderReplay) {
+		fmt.Fprintln(os.Stderr, "LLM_PROVIDER=replay replays a single run and cannot be used with parallel")
+		os.Exit(1)
+	}
+	provider, model, err := resolveProvider(name)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	applyLimitsFromEnv()
+
+	reg := dashboard.NewRegistry()
+	dashURL, dashOK := serveDashboard(func(port int) (string, error) { return dashboard.StartRegistryDashboard(reg, port) })
+
+	scanner := bufio.NewScanner(os.Stdin)
+	scanner.Buffer(make([]byte, 0, 1024*1024), 1024*1024)
+	ask := askHuman(scanner)
+	var askMu sync.Mutex
+
+	sup := orchestrator.NewSupervisor()
+	sup.OnChange = func(st orchestrator.RunStatus) {
+		if st.Status != orchestrator.StatusRunning {
+			fmt.Printf("[%s] finished: %s after %d iteration(s)\n", st.ID, st.Status, st.Iteration)
+		}
+	}
+	var sessions []string
+	var writers []*helpers.PrefixWriter
+	runIDs, workDirs := make([]string, len(tasks)), make([]string, len(tasks))
+	worktrees := make([]*git.Worktree, len(tasks))
+	// abort undoes the setup of the runs prepared so far and exits.
+	abort := func(sessions []string) {
+		for _, session := range sessions {
+			tmux.CleanupSession(session)
+		}
+		discardWorktrees(worktrees...)
+		os.Exit(1)
+	}
+	for i, t := range tasks {
+		runIDs[i], workDirs[i] = orchestrator.NewRunID(), t.WorkDir
+		if useWorktrees {
+			wt, err := prepareWorktree(t.WorkDir, runIDs[i])
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "failed to create worktree for task %d: %v\n", i+1, err)
+				abort(sessions)
+			}
+			worktrees[i], workDirs[i] = wt, wt.Path
+		}
+		if err := tmux.EnsureClaudeSession(t.Session, workDirs[i], command); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to prepare session %s: %v\n", t.Session, err)
+			abort(append(sessions, t.Session))
+		}
+		sessions = append(sessions, t.Session)
+	}
+	runDirs := make(map[string]string, len(tasks))
+	for i, id := range runIDs {
+		runDirs[id] = workDirs[i]
+	}
+	showGitCheckpoints(func(run string) string {
+		if run == "" {
+			return workDirs[0]
+		}
+		return runDirs[run]
+	})
+
+	runWithCleanup(sessions, helpers.EnvBool("TERMINATE_WHEN_QUIT", false), func() {
+		for i, t := range tasks {
+			// Memory belongs to the original checkout, also for worktree
+			// runs, but branch memory to the run's branch.
+			store := memoryStore(t.WorkDir, worktrees[i])
+			memories, memErr := store.Load()
+			if memErr != nil {
+				fmt.Fprintf(os.Stderr, "warning: failed to load memory for %s: %v\n", t.WorkDir, memErr)
+			}
+
+			cfg := envLoopConfig(scanner)
+			cfg.RunID = runIDs[i]
+			prefix := "[" + cfg.RunID + "] "
+			stdout, stderr := helpers.NewPrefixWriter(os.Stdout, prefix), helpers.NewPrefixWriter(os.Stderr, prefix)
+			writers = append(writers, stdout, stderr)
+			cfg.Session, cfg.WorkDir, cfg.Command, cfg.AgentName = t.Session, workDirs[i], command, agentName
+			cfg.Task, cfg.Provider, cfg.Model, cfg.Memories = t.Task, provider, model, memories
+			cfg.Worktree, cfg.MemoryDir = worktrees[i], t.WorkDir
+			cfg.MemoryUserDir, cfg.MemoryBranch = store.UserDir, store.Branch
+			if len(t.Accept) > 0 {
+				cfg.AcceptanceCommands = t.Accept
+			}
+			cfg.Stdout, cfg.Stderr = stdout, stderr
+			// Runs share stdin: ask one question at a time and say who is asking.
+			cfg.AskHuman = func(question string) (string, error) {
+				askMu.Lock()
+				defer askMu.Unlock()
+				return ask(prefix + question)
+			}
+			if dashOK {
+				cfg.Broker = reg.Broker(cfg.RunID)
+				cfg.Control = cfg.Broker.Control()
+			}
+			if helpers.EnvBool("CHECKPOINTS", true) {
+				cfg.RunDir = filepath.Join(runsDir(), cfg.RunID)
+			}
+			sup.Start(cfg)
+			if dashOK {
+				fmt.Printf("%s%s in %s (session %s): %s/?run=%s\n", prefix, t.Task, workDirs[i], t.Session, dashURL, cfg.RunID)
+			} else {
+				fmt.Printf("%s%s in %s (session %s)\n", prefix, t.Task, workDirs[i], t.Session)
+			}
+		}
+		sup.Wait()
+	})
+	for _, w := range writers {
+		w.Flush()
+	}
+	cleanup := helpers.EnvBool("WORKTREE_CLEANUP", false)
+	for i, wt := range worktrees {
+		if wt != nil {
+			w := helpers.NewPrefixWriter(os.Stdout, "["+runIDs[i]+"] ")
+			finishWorktree(w, wt, runIDs[i], cleanup)
+			w.Flush()
+		}
+	}
+
+	failed := 0
+	fmt.Println("\n========================================")
+	for _, st := range sup.Status() {
+		line := fmt.Sprintf("%s  %-14s  %3d iter  %s", st.ID, st.Status, st.Iteration, st.Task)
+		if st.Status != orchestrator.StatusComplete {
+			failed++
+			if st.Error != "" {
+				line += "  (" + st.Error + ")"
+			}
+		}
+		fmt.Println(line)
+	}
+	fmt.Println("========================================")
+	if failed > 0 {
+		fmt.Fprintf(os.Stderr, "%d of %d runs did not complete\n", failed, len(tasks))
+		os.Exit(1)
+	}
+}
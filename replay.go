@@ -0,0 +1,81 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/dlee6018/agent-orchestrator/dashboard"
+	"github.com/dlee6018/agent-orchestrator/helpers"
+	"github.com/dlee6018/agent-orchestrator/transcript"
+)
+
+// replayWaitForClient is how long replay waits for a dashboard to connect
+// before it starts publishing events.
+const replayWaitForClient = 30 * time.Second
+
+// replayMain implements `replay [-speed N] <run-id|run-dir|transcript.jsonl>`:
+// it streams a recorded transcript back through the dashboard so a past run
+// can be watched as if it were live.
+func replayMain(args []string) {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	speed := fs.Float64("speed", 1, "playback speed multiplier (0 publishes all events at once)")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: go-orchestrator replay [-speed N] <run-id|run-dir|transcript.jsonl>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	path := resolveTranscript(fs.Arg(0))
+	records, err := transcript.Read(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	broker := dashboard.NewSSEBroker()
+	showRunHistory(runsDir())
+	addr, err := dashboard.StartDashboard(broker, helpers.EnvInt("DASHBOARD_PORT", 0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to start dashboard: %v\n", err)
+		os.Exit(1)
+	}
+	dashURL := fmt.Sprintf("http://%s", addr)
+	fmt.Printf("Dashboard: %s\n", dashURL)
+	if helpers.EnvBool("DASHBOARD_OPEN", true) {
+		dashboard.OpenBrowser(dashURL)
+	}
+
+	fmt.Println("Waiting for the dashboard to connect...")
+	deadline := time.Now().Add(replayWaitForClient)
+	for broker.Clients() == 0 && time.Now().Before(deadline) {
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	fmt.Printf("Replaying %s at %gx\n", path, *speed)
+	n := transcript.Replay(records, broker, *speed)
+	fmt.Printf("Replayed %d events. Press Ctrl-C to exit.\n", n)
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	<-sigCh
+}
+
+// resolveTranscript maps a run ID, run directory or file path to a transcript file.
+func resolveTranscript(ref string) string {
+	if info, err := os.Stat(ref); err == nil {
+		if info.IsDir() {
+			return filepath.Join(ref, transcript.FileName)
+		}
+		return ref
+	}
+	return filepath.Join(runsDir(), ref, transcript.FileName)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/dlee6018/agent-orchestrator/helpers"
+	"github.com/dlee6018/agent-orchestrator/orchestrator"
+	"github.com/dlee6018/agent-orchestrator/tmux"
+)
+
+// resumeMain implements `resume [-max-iterations N] [-max-cost USD]
+// [-max-tokens N] [run-id|run-dir]`: it loads the run's checkpoint (the latest
+// interrupted run when no argument is given), reattaches to its tmux session
+// and continues the loop from the next iteration. The flags raise the limits
+// of a run that hit its iteration cap or budget.
+func resumeMain(args []string) {
+	fs := flag.NewFlagSet("resume", flag.ExitOnError)
+	maxIterations := fs.Int("max-iterations", 0, "total iteration cap of the run (default MAX_ITERATIONS)")
+	maxCost := fs.Float64("max-cost", 0, "total cost budget in USD (default BUDGET_MAX_COST)")
+	maxTokens := fs.Int("max-tokens", 0, "total token budget (default BUDGET_MAX_TOKENS)")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: go-orchestrator resume [-max-iterations N] [-max-cost USD] [-max-tokens N] [run-id|run-dir]")
+		fmt.Fprintln(os.Stderr, "\nA run that reached its iteration cap or budget continues only once the flags or env raise that limit.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() > 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	dir, err := orchestrator.FindRunDir(runsDir(), fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	cp, err := orchestrator.LoadCheckpoint(dir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	applyLimitsFromEnv()
+	if *maxIterations > 0 {
+		orchestrator.MaxIterations = *maxIterations
+	}
+	scanner := bufio.NewScanner(os.Stdin)
+	scanner.Buffer(make([]byte, 0, 1024*1024), 1024*1024)
+	cfg := envLoopConfig(scanner)
+	if *maxCost > 0 {
+		cfg.Budget.MaxCost = *maxCost
+	}
+	if *maxTokens > 0 {
+		cfg.Budget.MaxTokens = *maxTokens
+	}
+	switch {
+	case cp.Status == orchestrator.StatusComplete:
+		fmt.Fprintf(os.Stderr, "run %s is already complete\n", cp.RunID)
+		os.Exit(1)
+	case !cp.ResumableWith(orchestrator.MaxIterations, cfg.Budget):
+		fmt.Fprintf(os.Stderr, "run %s ended with status %s at iteration %d; raise the limit with -max-iterations, -max-cost or -max-tokens to continue it\n", cp.RunID, cp.Status, cp.Iteration)
+		os.Exit(1)
+	}
+
+	if cp.Socket != "" {
+		tmux.Socket = cp.Socket
+	}
+	// Keep the recorded provider and model unless explicitly overridden.
+	name := helpers.EnvOrDefault("LLM_PROVIDER", cp.Provider)
+	provider, model, err := resolveProvider(name)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if os.Getenv("LLM_MODEL") == "" && name == cp.Provider {
+		model = cp.Model
+	}
+
+	if _, err := os.Stat(cp.WorkDir); err != nil {
+		fmt.Fprintf(os.Stderr, "working directory of run %s is gone: %v\n", cp.RunID, err)
+		os.Exit(1)
+	}
+	// Reattach to the run's session, recreating it if the agent is gone.
+	if err := tmux.EnsureClaudeSession(cp.Session, cp.WorkDir, cp.Command); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to prepare session: %v\n", err)
+		os.Exit(1)
+	}
+
+	broker := startDashboard()
+	showGitCheckpoints(func(string) string { return cp.WorkDir })
+
+	cfg.Session, cfg.WorkDir, cfg.Command, cfg.AgentName = cp.Session, cp.WorkDir, cp.Command, cp.AgentName
+	cfg.Task, cfg.Provider, cfg.Model = cp.Task, provider, model
+	cfg.Broker, cfg.Control = broker, broker.Control()
+	// The conversation was built for one action mode; keep it.
+	cfg.ToolCalling = cp.ToolCalling
+	cfg.RunID, cfg.RunDir, cfg.Resume = cp.RunID, dir, cp
+	cfg.Worktree, cfg.MemoryDir = cp.Worktree, cp.MemoryDir
+	cfg.MemoryUserDir, cfg.MemoryBranch = cp.MemoryUserDir, cp.MemoryBranch
+	if os.Getenv("LLM_FALLBACK_MODELS") == "" && name == cp.Provider {
+		cfg.FallbackModels = cp.FallbackModels
+	}
+	// The restored system prompt names the run's acceptance commands.
+	if len(cp.Acceptance) > 0 {
+		cfg.AcceptanceCommands = cp.Acceptance
+	}
+
+	runWithCleanup([]string{cp.Session}, helpers.EnvBool("TERMINATE_WHEN_QUIT", false), func() {
+		orchestrator.RunLoop(cfg)
+	})
+	if cp.Worktree != nil {
+		finishWorktree(os.Stdout, cp.Worktree, cp.RunID, helpers.EnvBool("WORKTREE_CLEANUP", false))
+	}
+}
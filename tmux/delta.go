@@ -0,0 +1,104 @@
+package tmux
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DeltaContextLines is the number of unchanged lines shown above a pane delta.
+const DeltaContextLines = 3
+
+// PaneDelta is the part of a pane capture that changed since a previous one.
+type PaneDelta struct {
+	Text      string // changed lines, preceded by up to the requested context lines
+	Unchanged int    // earlier lines omitted because they are identical in both captures
+	Same      bool   // current is identical to previous
+}
+
+// DiffPane compares two cleaned pane captures (see CleanPaneOutput) and returns
+// the lines of current after those it shares with previous, plus up to
+// contextLines unchanged lines before them. Output from a CLI mostly appends
+// to the scrollback, so this isolates what the last action produced while
+// still including redrawn footers and prompts. The captures are aligned on
+// their longest run of shared lines rather than on their first line, so
+// scrollback trimmed by the history limit or a redrawn header does not make
+// the whole pane look new. With an empty previous the whole pane is
+// returned; identical captures yield only the context.
+func DiffPane(previous, current string, contextLines int) PaneDelta {
+	if previous == "" {
+		return PaneDelta{Text: current}
+	}
+	prev := strings.Split(previous, "\n")
+	cur := strings.Split(current, "\n")
+
+	shift := alignPanes(prev, cur)
+	// Within that alignment, the delta starts at the first line that differs
+	// after the shared lines begin; differing lines above them were redrawn.
+	i := max(-shift, 0)
+	for i < len(cur) && i+shift < len(prev) && cur[i] != prev[i+shift] {
+		i++
+	}
+	common := i
+	for common < len(cur) && common+shift < len(prev) && cur[common] == prev[common+shift] {
+		common++
+	}
+	if common == i {
+		common = 0 // nothing shared
+	}
+	start := max(common-contextLines, 0)
+	return PaneDelta{
+		Text:      strings.Join(cur[start:], "\n"),
+		Unchanged: start,
+		Same:      common == len(cur) && common+shift == len(prev),
+	}
+}
+
+// alignPanes returns the offset s for which cur[i] == prev[i+s] holds for the
+// longest run of consecutive lines. Ties go to the smallest offset, so an
+// unscrolled pane stays aligned with itself.
+func alignPanes(prev, cur []string) int {
+	best, bestRun := 0, 0
+	for _, shift := range shifts(len(prev), len(cur)) {
+		lo, hi := max(-shift, 0), min(len(cur), len(prev)-shift)
+		if hi-lo <= bestRun {
+			continue
+		}
+		run := 0
+		for i := lo; i < hi; i++ {
+			if cur[i] != prev[i+shift] {
+				run = 0
+				continue
+			}
+			if run++; run > bestRun {
+				best, bestRun = shift, run
+			}
+		}
+	}
+	return best
+}
+
+// shifts lists the possible offsets between captures of prevLen and curLen
+// lines, nearest to zero first.
+func shifts(prevLen, curLen int) []int {
+	out := []int{0}
+	for d := 1; d < max(prevLen, curLen); d++ {
+		if d < prevLen {
+			out = append(out, d)
+		}
+		if d < curLen {
+			out = append(out, -d)
+		}
+	}
+	return out
+}
+
+// String renders the delta for an LLM, noting how many earlier lines were omitted.
+func (d PaneDelta) String() string {
+	if d.Same {
+		return fmt.Sprintf("[no new output; %d earlier lines unchanged]\n%s", d.Unchanged, d.Text)
+	}
+	if d.Unchanged == 0 {
+		return d.Text
+	}
+	return fmt.Sprintf("[%d earlier lines unchanged]\n%s", d.Unchanged, d.Text)
+}
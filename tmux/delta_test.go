@@ -0,0 +1,77 @@
+package tmux
+
+import (
+	"strings"
+	"testing"
+)
+
+// Without a previous capture the whole pane is the delta.
+func TestDiffPane_NoPrevious(t *testing.T) {
+	d := DiffPane("", "a\nb", 3)
+	if d.Text != "a\nb" || d.Unchanged != 0 || d.String() != "a\nb" {
+		t.Fatalf("unexpected delta: %+v", d)
+	}
+}
+
+// Appended lines are returned with the requested context and an unchanged count.
+func TestDiffPane_Appended(t *testing.T) {
+	prev := "l1\nl2\nl3\nl4\n$ "
+	cur := "l1\nl2\nl3\nl4\n$ echo hi\nhi\n$ "
+	d := DiffPane(prev, cur, 2)
+	if d.Text != "l3\nl4\n$ echo hi\nhi\n$ " {
+		t.Fatalf("text: %q", d.Text)
+	}
+	if d.Unchanged != 2 || d.Same {
+		t.Fatalf("unexpected delta: %+v", d)
+	}
+	if !strings.HasPrefix(d.String(), "[2 earlier lines unchanged]\n") {
+		t.Fatalf("string: %q", d.String())
+	}
+}
+
+// A redrawn line in the middle makes everything after it part of the delta.
+func TestDiffPane_Redraw(t *testing.T) {
+	d := DiffPane("a\nb\nc\nd", "a\nB\nc\nd", 0)
+	if d.Text != "B\nc\nd" || d.Unchanged != 1 {
+		t.Fatalf("unexpected delta: %+v", d)
+	}
+}
+
+// Lines trimmed off the top by the history limit do not make the rest new.
+func TestDiffPane_ScrolledOff(t *testing.T) {
+	prev := "l1\nl2\nl3\nl4\n$ "
+	cur := "l3\nl4\n$ make\nok\n$ "
+	d := DiffPane(prev, cur, 1)
+	if d.Text != "l4\n$ make\nok\n$ " || d.Unchanged != 1 || d.Same {
+		t.Fatalf("unexpected delta: %+v", d)
+	}
+}
+
+// A redrawn header above the shared lines is not part of the delta.
+func TestDiffPane_HeaderRedraw(t *testing.T) {
+	prev := "status 1\nl1\nl2\n$ "
+	cur := "status 2\nl1\nl2\n$ ls\nfile"
+	d := DiffPane(prev, cur, 0)
+	if d.Text != "$ ls\nfile" || d.Unchanged != 3 {
+		t.Fatalf("unexpected delta: %+v", d)
+	}
+}
+
+// Captures sharing no line are returned whole.
+func TestDiffPane_Unrelated(t *testing.T) {
+	d := DiffPane("a\nb", "c\nd", 2)
+	if d.Text != "c\nd" || d.Unchanged != 0 || d.Same {
+		t.Fatalf("unexpected delta: %+v", d)
+	}
+}
+
+// Identical captures are flagged and only the context lines are returned.
+func TestDiffPane_Same(t *testing.T) {
+	d := DiffPane("a\nb\nc\nd", "a\nb\nc\nd", 1)
+	if !d.Same || d.Text != "d" || d.Unchanged != 3 {
+		t.Fatalf("unexpected delta: %+v", d)
+	}
+	if !strings.Contains(d.String(), "no new output") {
+		t.Fatalf("string: %q", d.String())
+	}
+}
@@ -23,6 +23,10 @@ var PollInterval = 500 * time.Millisecond // 0.5s
 // StableWindow is how long pane content must be unchanged to be considered stable.
 var StableWindow = 2 * time.Second // 2s
 
+// PaneUpdateInterval is the minimum time between two live pane updates
+// reported while waiting for the pane to stabilize.
+var PaneUpdateInterval = 1 * time.Second // 1s
+
 // StartupSettleWindow is how long to wait after startup to confirm session is alive.
 var StartupSettleWindow = 1500 * time.Millisecond // 1.5s
 
@@ -32,8 +36,12 @@ var KeystrokeSleep = 500 * time.Millisecond // pause between text and Enter
 // MaxSendRetries is the number of attempts for send-and-capture (1 initial + retries).
 var MaxSendRetries = 2 // 1 initial attempt + 1 retry
 
+// ErrStillWorking reports that the pane did not change within the timeout
+// while the agent process is alive, so the agent is most likely still busy.
+var ErrStillWorking = errors.New("agent is still working")
+
 // ansiPattern matches ANSI escape sequences (CSI sequences and OSC sequences).
-var ansiPattern = regexp.MustCompile(`\x1b\[[0-9;]*[a-zA-Z]|\x1b\][^\x1b]*\x1b\\|\x1b\][^\x07]*\x07`)
+var ansiPattern = regexp.MustCompile(`\x1b\[[0-9;:?<=>]*[ -/]*[@-~]|\x1b\][^\x1b]*\x1b\\|\x1b\][^\x07]*\x07`)
 
 // blankRunPattern matches 3+ consecutive blank lines.
 var blankRunPattern = regexp.MustCompile(`(\n\s*){3,}`)
@@ -85,7 +93,7 @@ func configureSession(session string) error {
 }
 
 // SendAndCaptureWithRecovery sends a message and captures the response, retrying once on recoverable failures.
-func SendAndCaptureWithRecovery(session, workDir, command, message, lastPane string) (string, error) {
+func SendAndCaptureWithRecovery(session, workDir, command, message, lastPane string, onChange func(pane string)) (string, error) {
 	var lastErr error
 
 	for attempt := 1; attempt <= MaxSendRetries; attempt++ {
@@ -113,7 +121,7 @@ func SendAndCaptureWithRecovery(session, workDir, command, message, lastPane str
 			return "", lastErr
 		}
 
-		pane, err := WaitForPaneUpdate(session, lastPane, 90*time.Second)
+		pane, err := WaitForPaneUpdate(session, lastPane, 90*time.Second, onChange)
 		if err != nil {
 			lastErr = fmt.Errorf("SendAndCaptureWithRecovery: capture pane: %w", err)
 			if ShouldRecoverSession(err) && attempt == 1 {
@@ -137,8 +145,8 @@ func SendAndCaptureWithRecovery(session, workDir, command, message, lastPane str
 
 // SendMessage sends text to the tmux pane as literal keystrokes followed by Enter.
 func SendMessage(session, message string) error {
-	if err := RunTmux("send-keys", "-t", session, "-l", message); err != nil {
-		return fmt.Errorf("SendMessage: send-keys literal: %w", err)
+	if err := SendText(session, message); err != nil {
+		return fmt.Errorf("SendMessage: %w", err)
 	}
 	time.Sleep(KeystrokeSleep)
 	if err := RunTmux("send-keys", "-t", session, "C-m"); err != nil {
@@ -147,9 +155,48 @@ func SendMessage(session, message string) error {
 	return nil
 }
 
-// WaitForPaneUpdate polls the tmux pane until its content changes and stabilizes.
-func WaitForPaneUpdate(session, previous string, timeout time.Duration) (string, error) {
-	return WaitForPaneUpdateWithCapture(previous, timeout, func() (string, error) {
+// SendText types text into the tmux pane as literal keystrokes without pressing Enter.
+func SendText(session, text string) error {
+	if err := RunTmux("send-keys", "-t", session, "-l", text); err != nil {
+		return fmt.Errorf("SendText: send-keys literal: %w", err)
+	}
+	return nil
+}
+
+// SendKeys presses the named keys in order (e.g. "Escape", "C-c", "Up", "Enter").
+// Every name is validated with ValidKeyName before anything is sent.
+func SendKeys(session string, keys ...string) error {
+	if len(keys) == 0 {
+		return errors.New("SendKeys: no keys given")
+	}
+	for _, k := range keys {
+		if !ValidKeyName(k) {
+			return fmt.Errorf("SendKeys: unsupported key %q", k)
+		}
+	}
+	for _, k := range keys {
+		if err := RunTmux("send-keys", "-t", session, k); err != nil {
+			return fmt.Errorf("SendKeys: send-keys %s: %w", k, err)
+		}
+	}
+	return nil
+}
+
+// keyNamePattern matches tmux key names: an optional C-/M-/S- modifier prefix
+// followed by a single character or a named special key.
+var keyNamePattern = regexp.MustCompile(`^((C|M|S)-){0,3}([A-Za-z0-9]|Escape|Enter|Tab|BTab|BSpace|Space|Up|Down|Left|Right|Home|End|PageUp|PageDown|PPage|NPage|DC|IC|F[1-9]|F1[0-2])$`)
+
+// ValidKeyName reports whether key is a tmux key name that SendKeys accepts.
+func ValidKeyName(key string) bool {
+	return keyNamePattern.MatchString(key)
+}
+
+// WaitForPaneUpdate polls the tmux pane until its content changes and
+// stabilizes. The pane is captured without ANSI escapes, so color-only
+// redraws do not count as changes. If onChange is non-nil it receives the
+// changing pane while polling, at most once per PaneUpdateInterval.
+func WaitForPaneUpdate(session, previous string, timeout time.Duration, onChange func(pane string)) (string, error) {
+	return WaitForPaneUpdateWithCapture(previous, timeout, onChange, func() (string, error) {
 		return CapturePane(session)
 	}, func() (bool, error) {
 		dead, _, _, err := tmuxPaneState(session)
@@ -161,10 +208,17 @@ func WaitForPaneUpdate(session, previous string, timeout time.Duration) (string,
 }
 
 // WaitForPaneUpdateWithCapture is the testable core of WaitForPaneUpdate using injectable capture and checkAlive funcs.
-func WaitForPaneUpdateWithCapture(previous string, timeout time.Duration, capture func() (string, error), checkAlive func() (bool, error)) (string, error) {
+func WaitForPaneUpdateWithCapture(previous string, timeout time.Duration, onChange func(pane string), capture func() (string, error), checkAlive func() (bool, error)) (string, error) {
 	deadline := time.Now().Add(timeout)
 	last := previous
 	stableSince := time.Now()
+	reported, reportedAt := previous, time.Time{}
+	report := func(pane string) {
+		if onChange != nil && pane != reported {
+			onChange(pane)
+			reported, reportedAt = pane, time.Now()
+		}
+	}
 
 	for time.Now().Before(deadline) {
 		pane, err := capture()
@@ -174,7 +228,11 @@ func WaitForPaneUpdateWithCapture(previous string, timeout time.Duration, captur
 		if pane != last {
 			last = pane
 			stableSince = time.Now()
+			if time.Since(reportedAt) >= PaneUpdateInterval {
+				report(pane)
+			}
 		} else if pane != previous && time.Since(stableSince) >= StableWindow {
+			report(pane) // the last change may have been throttled
 			return pane, nil
 		}
 
@@ -189,7 +247,7 @@ func WaitForPaneUpdateWithCapture(previous string, timeout time.Duration, captur
 		if !alive {
 			return last, fmt.Errorf("WaitForPaneUpdateWithCapture: agent process is dead, no pane changes within %s", timeout)
 		}
-		return last, fmt.Errorf("WaitForPaneUpdateWithCapture: agent is still working, no pane changes within %s", timeout)
+		return last, fmt.Errorf("WaitForPaneUpdateWithCapture: %w, no pane changes within %s", ErrStillWorking, timeout)
 	}
 	return last, fmt.Errorf("WaitForPaneUpdateWithCapture: timeout (%s) reached, content changed but did not stabilize", timeout)
 }
@@ -406,6 +464,18 @@ func CapturePane(session string) (string, error) {
 	return string(out), nil
 }
 
+// CaptureScreenANSI returns the visible screen of the tmux pane, without the
+// scrollback, with the text attributes and colors kept as ANSI escape
+// sequences, for rendering the pane in the dashboard.
+func CaptureScreenANSI(session string) (string, error) {
+	cmd := exec.Command("tmux", TmuxArgs("capture-pane", "-p", "-e", "-t", session)...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("CaptureScreenANSI: capture-pane: %w (%s)", err, strings.TrimSpace(string(out)))
+	}
+	return string(out), nil
+}
+
 // RunTmux executes a tmux command with the configured socket and returns any error.
 func RunTmux(args ...string) error {
 	cmd := exec.Command("tmux", TmuxArgs(args...)...)
@@ -125,7 +125,7 @@ func TestWaitForPaneUpdateWithCapture_ReturnsStablePane(t *testing.T) {
 	}
 
 	alwaysAlive := func() (bool, error) { return true, nil }
-	got, err := WaitForPaneUpdateWithCapture("same", 100*time.Millisecond, capture, alwaysAlive)
+	got, err := WaitForPaneUpdateWithCapture("same", 100*time.Millisecond, nil, capture, alwaysAlive)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -134,6 +134,40 @@ func TestWaitForPaneUpdateWithCapture_ReturnsStablePane(t *testing.T) {
 	}
 }
 
+// Live updates are throttled, but the first change and the settled pane are always reported.
+func TestWaitForPaneUpdateWithCapture_ReportsChanges(t *testing.T) {
+	OverrideTimers(t)
+
+	for _, tc := range []struct {
+		interval time.Duration
+		want     []string
+	}{
+		{0, []string{"a", "b", "c"}},
+		{time.Hour, []string{"a", "c"}},
+	} {
+		old := PaneUpdateInterval
+		PaneUpdateInterval = tc.interval
+		panes := []string{"same", "a", "b", "c"}
+		i := 0
+		capture := func() (string, error) {
+			v := panes[min(i, len(panes)-1)]
+			i++
+			return v, nil
+		}
+		var got []string
+		onChange := func(pane string) { got = append(got, pane) }
+		alwaysAlive := func() (bool, error) { return true, nil }
+		pane, err := WaitForPaneUpdateWithCapture("same", 100*time.Millisecond, onChange, capture, alwaysAlive)
+		PaneUpdateInterval = old
+		if err != nil || pane != "c" {
+			t.Fatalf("interval %s: got %q, %v; want c", tc.interval, pane, err)
+		}
+		if strings.Join(got, ",") != strings.Join(tc.want, ",") {
+			t.Errorf("interval %s: updates %q, want %q", tc.interval, got, tc.want)
+		}
+	}
+}
+
 // Times out with an error when the pane never changes from the previous value.
 func TestWaitForPaneUpdateWithCapture_TimeoutNoChanges(t *testing.T) {
 	OverrideTimers(t)
@@ -143,12 +177,12 @@ func TestWaitForPaneUpdateWithCapture_TimeoutNoChanges(t *testing.T) {
 	}
 
 	alwaysAlive := func() (bool, error) { return true, nil }
-	got, err := WaitForPaneUpdateWithCapture("same", 5*time.Millisecond, capture, alwaysAlive)
+	got, err := WaitForPaneUpdateWithCapture("same", 5*time.Millisecond, nil, capture, alwaysAlive)
 	if err == nil {
 		t.Fatal("expected timeout error")
 	}
-	if !strings.Contains(err.Error(), "agent is still working") {
-		t.Fatalf("expected 'agent is still working' error, got: %v", err)
+	if !errors.Is(err, ErrStillWorking) {
+		t.Fatalf("expected ErrStillWorking, got: %v", err)
 	}
 	if got != "same" {
 		t.Fatalf("got %q, want same", got)
@@ -162,7 +196,7 @@ func TestWaitForPaneUpdateWithCapture_CaptureError(t *testing.T) {
 	}
 
 	alwaysAlive := func() (bool, error) { return true, nil }
-	_, err := WaitForPaneUpdateWithCapture("same", 50*time.Millisecond, capture, alwaysAlive)
+	_, err := WaitForPaneUpdateWithCapture("same", 50*time.Millisecond, nil, capture, alwaysAlive)
 	if err == nil {
 		t.Fatal("expected error")
 	}
@@ -180,7 +214,7 @@ func TestWaitForPaneUpdateWithCapture_TimeoutProcessDead(t *testing.T) {
 	}
 	alwaysDead := func() (bool, error) { return false, nil }
 
-	got, err := WaitForPaneUpdateWithCapture("same", 5*time.Millisecond, capture, alwaysDead)
+	got, err := WaitForPaneUpdateWithCapture("same", 5*time.Millisecond, nil, capture, alwaysDead)
 	if err == nil {
 		t.Fatal("expected timeout error")
 	}
@@ -201,6 +235,15 @@ func TestCleanPaneOutput_StripsANSI(t *testing.T) {
 	}
 }
 
+// The escapes capture-pane -e emits, including colon-separated colors, are stripped.
+func TestCleanPaneOutput_StripsCapturedEscapes(t *testing.T) {
+	input := "\x1b[38;2;255;0;0m-old\x1b[39m\n\x1b[38:5:2m+new\x1b[0m\n\x1b]8;;https://example.com\x1b\\link\x1b]8;;\x1b\\"
+	got := CleanPaneOutput(input)
+	if want := "-old\n+new\nlink"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
 // Runs of 3+ blank lines are collapsed to 2.
 func TestCleanPaneOutput_CollapsesBlankLines(t *testing.T) {
 	input := "line1\n\n\n\n\nline2"
@@ -222,6 +265,20 @@ func TestCleanPaneOutput_TrimsTrailingWhitespace(t *testing.T) {
 	}
 }
 
+// Special keys and modifier combos are accepted; free text and shell syntax are not.
+func TestValidKeyName(t *testing.T) {
+	for _, k := range []string{"Escape", "Enter", "C-c", "Up", "M-x", "y", "F5", "BSpace"} {
+		if !ValidKeyName(k) {
+			t.Fatalf("expected %q to be valid", k)
+		}
+	}
+	for _, k := range []string{"", "hello", "C-", "Esc ape", "; rm -rf /", "F13"} {
+		if ValidKeyName(k) {
+			t.Fatalf("expected %q to be rejected", k)
+		}
+	}
+}
+
 // Short strings pass through unchanged.
 func TestTruncateForLog_Short(t *testing.T) {
 	got := TruncateForLog("hello", 10)
@@ -0,0 +1,178 @@
+// Package transcript records autonomous runs as JSONL audit trails and
+// replays them through the dashboard.
+package transcript
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/dlee6018/agent-orchestrator/dashboard"
+	"github.com/dlee6018/agent-orchestrator/memory"
+)
+
+// FileName is the transcript file name inside a run directory.
+const FileName = "transcript.jsonl"
+
+// Record kinds.
+const (
+	KindEvent  = "event"  // a dashboard.IterationEvent as published to the broker
+	KindLLM    = "llm"    // one LLM request and its response or error
+	KindPane   = "pane"   // a raw and cleaned pane capture
+	KindMemory = "memory" // the memory facts a run started with
+)
+
+// LLM call purposes recorded in Record.Purpose.
+const (
+	PurposeTurn             = "turn"
+	PurposeSummary          = "summary"
+	PurposeMemoryCompaction = "memory_compaction"
+)
+
+// Record is one line of a transcript.
+type Record struct {
+	Time       time.Time                 `json:"time"`
+	Kind       string                    `json:"kind"`
+	Iteration  int                       `json:"iteration,omitempty"`
+	Event      *dashboard.IterationEvent `json:"event,omitempty"`
+	Purpose    string                    `json:"purpose,omitempty"`
+	Request    json.RawMessage           `json:"request,omitempty"`
+	Response   json.RawMessage           `json:"response,omitempty"`
+	RawPane    string                    `json:"raw_pane,omitempty"`
+	CleanPane  string                    `json:"clean_pane,omitempty"`
+	Memories   []memory.Record           `json:"memories,omitempty"`
+	DurationMs int64                     `json:"duration_ms,omitempty"`
+	Error      string                    `json:"error,omitempty"`
+	// ErrorStatus is the HTTP status of a failed LLM call, if it got one.
+	ErrorStatus int `json:"error_status,omitempty"`
+}
+
+// Writer appends records to a transcript file. Each record is written with a
+// single unbuffered write, so a crash loses at most the record in flight.
+// A nil *Writer discards records.
+type Writer struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+// Open opens (or creates) the transcript at path for appending, so a resumed
+// run continues the same file.
+func Open(path string) (*Writer, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("Open: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("Open: %w", err)
+	}
+	return &Writer{f: f}, nil
+}
+
+// Write appends rec, stamping the current time if rec.Time is zero.
+func (w *Writer) Write(rec Record) error {
+	if w == nil {
+		return nil
+	}
+	if rec.Time.IsZero() {
+		rec.Time = time.Now()
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("Write: marshal: %w", err)
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, err := w.f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("Write: %w", err)
+	}
+	return nil
+}
+
+// Close closes the transcript file.
+func (w *Writer) Close() error {
+	if w == nil {
+		return nil
+	}
+	return w.f.Close()
+}
+
+// Read loads all records from the transcript at path. A truncated final line
+// (left by a crash mid-write) is ignored; corruption elsewhere is an error.
+func Read(path string) ([]Record, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Read: %w", err)
+	}
+	defer f.Close()
+
+	var records []Record
+	var badLine int
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		if badLine != 0 {
+			return nil, fmt.Errorf("Read: line %d: invalid record", badLine)
+		}
+		var rec Record
+		if err := json.Unmarshal(line, &rec); err != nil {
+			badLine = n
+			continue
+		}
+		records = append(records, rec)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Read: %w", err)
+	}
+	return records, nil
+}
+
+// Events returns the recorded dashboard events that make up a run's final
+// state, for the history page. Streaming deltas and live pane updates are
+// left out: the iteration_end events that follow them carry the full text.
+func Events(records []Record) []dashboard.IterationEvent {
+	var events []dashboard.IterationEvent
+	for _, rec := range records {
+		if rec.Kind != KindEvent || rec.Event == nil {
+			continue
+		}
+		if t := rec.Event.Type; t == "orchestrator_delta" || t == "pane_update" {
+			continue
+		}
+		events = append(events, *rec.Event)
+	}
+	return events
+}
+
+// MaxReplayGap caps the pause between two replayed events, so long agent
+// waits don't stall a replay.
+var MaxReplayGap = 5 * time.Second
+
+// Replay publishes the event records to broker in order. Pauses between events
+// follow the recorded timing divided by speed (capped at MaxReplayGap); a speed
+// of 0 or less publishes everything immediately. It returns the number of
+// events published.
+func Replay(records []Record, broker *dashboard.SSEBroker, speed float64) int {
+	var last time.Time
+	n := 0
+	for _, rec := range records {
+		if rec.Kind != KindEvent || rec.Event == nil {
+			continue
+		}
+		if speed > 0 && !last.IsZero() {
+			gap := time.Duration(float64(rec.Time.Sub(last)) / speed)
+			time.Sleep(min(max(gap, 0), MaxReplayGap))
+		}
+		last = rec.Time
+		broker.Publish(*rec.Event)
+		n++
+	}
+	return n
+}
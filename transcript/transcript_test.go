@@ -0,0 +1,138 @@
+package transcript
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dlee6018/agent-orchestrator/dashboard"
+)
+
+// Records written by a Writer are read back in order, and reopening appends.
+func TestWriterRead_RoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run", FileName)
+	w, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	w.Write(Record{Kind: KindEvent, Iteration: 1, Event: &dashboard.IterationEvent{Type: "iteration_start", Iteration: 1}})
+	w.Write(Record{Kind: KindLLM, Iteration: 1, Purpose: PurposeTurn, Request: json.RawMessage(`{"model":"m"}`), DurationMs: 12})
+	w.Close()
+
+	w, err = Open(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	w.Write(Record{Kind: KindPane, Iteration: 1, RawPane: "\x1b[1m$\x1b[0m", CleanPane: "$"})
+	w.Close()
+
+	records, err := Read(path)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("expected 3 records, got %d", len(records))
+	}
+	if records[0].Event == nil || records[0].Event.Type != "iteration_start" || records[0].Time.IsZero() {
+		t.Fatalf("unexpected event record: %+v", records[0])
+	}
+	if string(records[1].Request) != `{"model":"m"}` || records[1].DurationMs != 12 {
+		t.Fatalf("unexpected llm record: %+v", records[1])
+	}
+	if records[2].RawPane != "\x1b[1m$\x1b[0m" {
+		t.Fatalf("raw pane not preserved: %q", records[2].RawPane)
+	}
+}
+
+// A nil Writer discards records without error.
+func TestWriter_Nil(t *testing.T) {
+	var w *Writer
+	if err := w.Write(Record{Kind: KindEvent}); err != nil {
+		t.Fatalf("Write on nil: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close on nil: %v", err)
+	}
+}
+
+// A truncated final line is ignored, but corruption before the end is an error.
+func TestRead_Truncated(t *testing.T) {
+	dir := t.TempDir()
+	good := `{"time":"2026-01-02T15:04:05Z","kind":"event","event":{"type":"complete","iteration":1,"max_iter":0,"timestamp":""}}`
+
+	tail := filepath.Join(dir, "tail.jsonl")
+	os.WriteFile(tail, []byte(good+"\n"+`{"time":"2026-01`), 0o644)
+	records, err := Read(tail)
+	if err != nil || len(records) != 1 {
+		t.Fatalf("truncated tail: %v, %d records", err, len(records))
+	}
+
+	mid := filepath.Join(dir, "mid.jsonl")
+	os.WriteFile(mid, []byte(good+"\nnot json\n"+good+"\n"), 0o644)
+	if _, err := Read(mid); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("expected corruption error on line 2, got %v", err)
+	}
+}
+
+// Replay publishes only event records, in order.
+func TestReplay_PublishesEvents(t *testing.T) {
+	b := dashboard.NewSSEBroker()
+	ch, unsub := b.Subscribe()
+	defer unsub()
+
+	now := time.Now()
+	records := []Record{
+		{Time: now, Kind: KindEvent, Event: &dashboard.IterationEvent{Type: "task_info", Task: "t"}},
+		{Time: now, Kind: KindLLM, Purpose: PurposeTurn},
+		{Time: now.Add(time.Hour), Kind: KindEvent, Event: &dashboard.IterationEvent{Type: "complete", Iteration: 1}},
+	}
+	start := time.Now()
+	if n := Replay(records, b, 0); n != 2 {
+		t.Fatalf("expected 2 events replayed, got %d", n)
+	}
+	if time.Since(start) > time.Second {
+		t.Fatal("speed 0 should not pause between events")
+	}
+	for _, want := range []string{`"type":"task_info"`, `"type":"complete"`} {
+		select {
+		case msg := <-ch:
+			if !strings.Contains(msg, want) {
+				t.Fatalf("expected %s, got %s", want, msg)
+			}
+		default:
+			t.Fatalf("missing event %s", want)
+		}
+	}
+}
+
+// Events keeps the event records but drops streaming deltas and live panes.
+func TestEvents(t *testing.T) {
+	records := []Record{
+		{Kind: KindEvent, Event: &dashboard.IterationEvent{Type: "task_info", Task: "t"}},
+		{Kind: KindEvent, Event: &dashboard.IterationEvent{Type: "orchestrator_delta", Delta: "he"}},
+		{Kind: KindEvent, Event: &dashboard.IterationEvent{Type: "pane_update", Pane: "$ "}},
+		{Kind: KindPane, RawPane: "$ "},
+		{Kind: KindEvent, Event: &dashboard.IterationEvent{Type: "iteration_end", Iteration: 1}},
+	}
+	got := Events(records)
+	if len(got) != 2 || got[0].Type != "task_info" || got[1].Type != "iteration_end" {
+		t.Fatalf("Events = %+v", got)
+	}
+}
+
+// Replay scales recorded gaps by speed.
+func TestReplay_Timing(t *testing.T) {
+	now := time.Now()
+	records := []Record{
+		{Time: now, Kind: KindEvent, Event: &dashboard.IterationEvent{Type: "iteration_start"}},
+		{Time: now.Add(400 * time.Millisecond), Kind: KindEvent, Event: &dashboard.IterationEvent{Type: "iteration_end"}},
+	}
+	start := time.Now()
+	Replay(records, nil, 4)
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond || elapsed > time.Second {
+		t.Fatalf("expected ~100ms at 4x, took %s", elapsed)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/dlee6018/agent-orchestrator/git"
+	"github.com/dlee6018/agent-orchestrator/helpers"
+)
+
+// prepareWorktree creates the git worktree for run runID from the repository
+// containing dir, on branch <WORKTREE_BRANCH_PREFIX><runID>. The worktree
+// lives in the run directory so all of a run's state stays together.
+func prepareWorktree(dir, runID string) (*git.Worktree, error) {
+	branch := helpers.EnvOrDefault("WORKTREE_BRANCH_PREFIX", "agent/") + runID
+	return git.CreateWorktree(dir, filepath.Join(runsDir(), runID, "worktree"), branch)
+}
+
+// finishWorktree reports the run's branch and diff stats. With cleanup set it
+// commits any remaining changes to the branch and removes the worktree, so
+// the work is kept on the branch only.
+func finishWorktree(w io.Writer, wt *git.Worktree, runID string, cleanup bool) {
+	stat, err := wt.DiffStat()
+	if err != nil {
+		stat = fmt.Sprintf("(diff stats unavailable: %v)", err)
+	}
+	fmt.Fprintf(w, "Worktree branch %s (base %.12s):\n%s\n", wt.Branch, wt.Base, stat)
+	if !cleanup {
+		fmt.Fprintf(w, "Worktree kept at %s\n", wt.Path)
+		return
+	}
+	if _, err := wt.CommitAll("Agent run " + runID); err != nil {
+		fmt.Fprintf(w, "warning: not removing worktree %s: %v\n", wt.Path, err)
+		return
+	}
+	if err := wt.Remove(); err != nil {
+		fmt.Fprintf(w, "warning: failed to remove worktree: %v\n", err)
+		return
+	}
+	fmt.Fprintf(w, "Removed worktree %s\n", wt.Path)
+}
+
+// discardWorktrees removes the worktrees of runs that fail before starting,
+// with their branches and the run directories they leave empty.
+func discardWorktrees(wts ...*git.Worktree) {
+	for _, wt := range wts {
+		if wt == nil {
+			continue
+		}
+		if err := wt.Remove(); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: failed to remove worktree: %v\n", err)
+			continue
+		}
+		os.Remove(filepath.Dir(wt.Path))
+	}
+}